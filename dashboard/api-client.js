@@ -52,6 +52,14 @@ export default class ApiClient {
     });
   }
 
+  listReleases({ owner, repo }) {
+    return cachedApiJson({
+      cache: this._cache,
+      cacheKey: `gh:${owner}:${repo}:releases`,
+      endpoint: `/gh/${owner}/${repo}/releases`,
+    });
+  }
+
   listTopContributors({ owner, repo, date }) {
     const year = ''+date.getFullYear();
     const month = ('0' + (date.getMonth()+1)).slice(-2);
@@ -17,15 +17,23 @@ export default class StarChart extends Component {
   }
 
   componentDidMount() {
-    this.props.apiClient.listStarCounts({
-      owner: this.props.owner,
-      repo: this.props.repo,
-    })
-    .then((starCounts) => {
+    const queryProps = { owner: this.props.owner, repo: this.props.repo };
+
+    Promise.all([
+      this.props.apiClient.listStarCounts(queryProps),
+      // The release markers are only decoration, so still draw the stars if
+      // the releases fail to load.
+      this.props.apiClient.listReleases(queryProps).catch(() => { return []; }),
+    ])
+    .then(([starCounts, releases]) => {
       const formattedCounts = map(starCounts, (starCount) => {
         return { stars: starCount.stars,
                  timestamp: d3.time.format.iso.parse(starCount.timestamp) };
       });
+      const formattedReleases = map(releases, (release) => {
+        return { tag: release.tag_name,
+                 timestamp: d3.time.format.iso.parse(release.published_at) };
+      });
 
       this.refs.placeholder.removeChild(this.refs.loader);
 
@@ -87,6 +95,29 @@ export default class StarChart extends Component {
           .style('text-anchor', 'end')
           .text('Stars');
 
+      const [start, end] = t.domain();
+      const markers = svg.selectAll('.chart__release')
+        .data(formattedReleases.filter((d) => {
+          return d.timestamp && start <= d.timestamp && d.timestamp <= end;
+        }))
+        .enter()
+        .append('g')
+          .attr('class', 'chart__release')
+          .attr('transform', (d) => { return `translate(${t(d.timestamp)},0)`; });
+
+      markers.append('line')
+        .attr('class', 'chart__release-line')
+        .attr('y1', 0)
+        .attr('y2', height);
+
+      markers.append('text')
+        .attr('class', 'chart__release-text')
+        .attr('transform', 'rotate(-90)')
+        .attr('x', -6)
+        .attr('dy', '-.35em')
+        .style('text-anchor', 'end')
+        .text((d) => { return d.tag; });
+
       const path = svg.append('path')
         .datum(formattedCounts)
         .attr('class', 'chart__line chart__line--orange')
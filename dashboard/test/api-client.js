@@ -231,6 +231,32 @@ describe('ApiClient', () => {
     });
   });
 
+  describe('.listReleases', () => {
+    it('lists the release timeline', (done) => {
+      window.fetch.returns(Promise.resolve(new window.Response(
+        '[{"tag_name":"v1.0.0","published_at":"2016-03-25T19:46:41Z"}]',
+        { status: 200,
+          headers: { 'Content-Type': 'application/json' } })));
+
+      apiClient.listReleases({
+        owner: 'tester',
+        repo: 'cool-project',
+      })
+      .then((releases) => {
+        expect(window.fetch)
+          .to.have.been.calledWith('/gh/tester/cool-project/releases');
+        expect(releases).to.eql([{
+          tag_name: 'v1.0.0',
+          published_at: '2016-03-25T19:46:41Z',
+        }]);
+        done();
+      })
+      .catch((err) => {
+        throw err;
+      });
+    });
+  });
+
   describe('.listTopContributors', () => {
     it('lists the monthly top contributors', (done) => {
       window.fetch.returns(Promise.resolve(new window.Response(
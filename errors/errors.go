@@ -1,8 +1,32 @@
 package errors
 
+import (
+	"time"
+)
+
+// Machine readable error codes, so clients can tell failures apart without
+// parsing messages.
+const (
+	CodeBadRequest      = "bad_request"
+	CodeCanceled        = "canceled"
+	CodeForbidden       = "forbidden"
+	CodeInvalidResponse = "invalid_response"
+	CodeNotFound        = "not_found"
+	CodeNotReady        = "not_ready"
+	CodeRateLimited     = "rate_limited"
+	CodeTimeout         = "timeout"
+	CodeUnauthorized    = "unauthorized"
+	CodeUnavailable     = "unavailable"
+	CodeUpstream        = "upstream_error"
+)
+
 type HttpError struct {
-	Message string
-	Status  int
+	Cause          error
+	Code           string
+	Message        string
+	RetryAfter     time.Duration
+	Status         int
+	UpstreamStatus int
 }
 
 func (e *HttpError) Error() string {
@@ -0,0 +1,147 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+)
+
+// Installation tokens are valid for an hour. Refresh them a little early so
+// a token never expires while a request is in flight.
+const installationTokenLeeway time.Duration = 5 * time.Minute
+
+type appAuth struct {
+	sync.Mutex
+	appId          int64
+	expiresAt      time.Time
+	installationId int64
+	privateKey     *rsa.PrivateKey
+	token          string
+}
+
+func ParseAppPrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("Github App private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("Github App private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+func LoadAppPrivateKey(path string) (*rsa.PrivateKey, error) {
+	pemBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseAppPrivateKey(pemBytes)
+}
+
+// appJwt signs the short lived JWT a Github App uses to authenticate as
+// itself.
+func appJwt(appId int64, privateKey *rsa.PrivateKey, now time.Time) (string, error) {
+	// Suppress JSON marshaling errors because we know we can always marshal
+	// these maps.
+	header, _ := json.Marshal(map[string]interface{}{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		// Backdate the token to allow for clock drift between us and Github.
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": appId,
+	})
+	unsigned := fmt.Sprintf(
+		"%s.%s",
+		base64.RawURLEncoding.EncodeToString(header),
+		base64.RawURLEncoding.EncodeToString(claims),
+	)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s.%s", unsigned, base64.RawURLEncoding.EncodeToString(signature)), nil
+}
+
+func (gh *Client) refreshInstallationToken(ctx context.Context, logger *log.Logger) *errors.HttpError {
+	jwt, err := appJwt(gh.app.appId, gh.app.privateKey, gh.clock.Now())
+	if err != nil {
+		logger.Printf("ERROR: %s\n", err.Error())
+		return &errors.HttpError{Message: "Server Error", Status: http.StatusInternalServerError}
+	}
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", gh.baseUrl, gh.app.installationId)
+	// Suppress errors from http.NewRequest. This can only error if the URL or
+	// HTTP method are invalid, and we control both in this module.
+	rr, _ := http.NewRequest("POST", url, nil)
+	rr = rr.WithContext(ctx)
+	rr.Header.Add("Authorization", fmt.Sprintf("Bearer %s", jwt))
+	rr.Header.Add("Accept", "application/vnd.github.v3+json")
+	startTime := time.Now()
+	resp, err := gh.httpClient.Do(rr)
+	logger.Printf("send POST %s %s\n", url, time.Since(startTime).String())
+	if err != nil {
+		logger.Printf("ERROR: %s\n", err.Error())
+		return requestError(ctx, err)
+	}
+	defer resp.Body.Close()
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		logger.Printf("ERROR: %s\n", err.Error())
+		return &errors.HttpError{Message: "Server Error", Status: http.StatusInternalServerError}
+	}
+	if resp.StatusCode != http.StatusCreated {
+		logger.Printf("ERROR: Github App token exchange failed with status %d\n", resp.StatusCode)
+		return &errors.HttpError{Message: "Github Authentication Error", Status: http.StatusBadGateway}
+	}
+	var body struct {
+		ExpiresAt time.Time `json:"expires_at"`
+		Token     string    `json:"token"`
+	}
+	if err := json.Unmarshal(contents, &body); err != nil || body.Token == "" {
+		logger.Printf("ERROR: Github App token exchange returned an invalid token\n")
+		return &errors.HttpError{Message: "Github API Error", Status: http.StatusBadGateway}
+	}
+	gh.app.token = body.Token
+	gh.app.expiresAt = body.ExpiresAt
+	logger.Printf("Refreshed the Github App installation token, valid until %s.\n", body.ExpiresAt.String())
+	return nil
+}
+
+// authorization returns the value of the Authorization header for requests to
+// the Github API, exchanging the app's JWT for a new installation token when
+// the current one is about to expire.
+func (gh *Client) authorization(ctx context.Context, logger *log.Logger) (string, *errors.HttpError) {
+	if gh.app == nil {
+		return fmt.Sprintf("token %s", gh.token), nil
+	}
+	gh.app.Lock()
+	defer gh.app.Unlock()
+	if gh.app.token == "" || !gh.clock.Now().Add(installationTokenLeeway).Before(gh.app.expiresAt) {
+		if httpErr := gh.refreshInstallationToken(ctx, logger); httpErr != nil {
+			return "", httpErr
+		}
+	}
+	return fmt.Sprintf("token %s", gh.app.token), nil
+}
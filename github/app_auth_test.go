@@ -0,0 +1,152 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/mocks"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func generateAppKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+	return key
+}
+
+func verifyAppJwt(t *testing.T, key *rsa.PrivateKey, authorization string) map[string]interface{} {
+	assert.True(t, strings.HasPrefix(authorization, "Bearer "))
+	parts := strings.Split(strings.TrimPrefix(authorization, "Bearer "), ".")
+	assert.Len(t, parts, 3)
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.NoError(t, err)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature))
+	claimsJson, err := base64.RawURLEncoding.DecodeString(parts[1])
+	assert.NoError(t, err)
+	var claims map[string]interface{}
+	assert.NoError(t, json.Unmarshal(claimsJson, &claims))
+	return claims
+}
+
+func TestAppInstallationToken(t *testing.T) {
+	t.Parallel()
+
+	key := generateAppKey(t)
+	clock := clockwork.NewFakeClockAt(time.Unix(1458966366, 0))
+	var mutex sync.Mutex
+	exchanges := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/app/installations/99/access_tokens" {
+			assert.Equal(t, "POST", r.Method)
+			claims := verifyAppJwt(t, key, r.Header.Get("Authorization"))
+			assert.Equal(t, 1234.0, claims["iss"])
+			assert.Equal(t, float64(clock.Now().Add(-time.Minute).Unix()), claims["iat"])
+			mutex.Lock()
+			exchanges++
+			token := fmt.Sprintf("v1.token%d", exchanges)
+			mutex.Unlock()
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token":"%s","expires_at":"%s"}`,
+				token,
+				clock.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+			)
+			return
+		}
+		mutex.Lock()
+		assert.Equal(t, fmt.Sprintf("token v1.token%d", exchanges), r.Header.Get("Authorization"))
+		mutex.Unlock()
+		fmt.Fprintln(w, starsJson)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		AppId:          1234,
+		AppPrivateKey:  key,
+		BaseUrl:        ts.URL,
+		Clock:          clock,
+		InstallationId: 99,
+	})
+	_, err := gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+	assert.NoError(t, err)
+	_, err = gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, exchanges)
+
+	clock.Advance(56 * time.Minute)
+	_, err = gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, exchanges)
+}
+
+func TestAppInstallationTokenError(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/app/installations/99/access_tokens", r.URL.Path)
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintln(w, `{"message":"A JSON web token could not be decoded"}`)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		AppId:          1234,
+		AppPrivateKey:  generateAppKey(t),
+		BaseUrl:        ts.URL,
+		InstallationId: 99,
+	})
+	_, err := gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusBadGateway, err.Status)
+}
+
+func TestParseAppPrivateKey(t *testing.T) {
+	t.Parallel()
+
+	key := generateAppKey(t)
+	pkcs1 := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	parsed, err := ParseAppPrivateKey(pkcs1)
+	assert.NoError(t, err)
+	assert.Equal(t, key.N, parsed.N)
+
+	pkcs8Bytes, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	parsed, err = ParseAppPrivateKey(pem.EncodeToMemory(&pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: pkcs8Bytes,
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, key.N, parsed.N)
+
+	_, err = ParseAppPrivateKey([]byte("chicken"))
+	assert.Error(t, err)
+}
+
+func TestEnterpriseGraphQLUrl(t *testing.T) {
+	t.Parallel()
+
+	gql := NewGraphQLClient(&Options{BaseUrl: "https://github.example.com/api/v3/"})
+	assert.Equal(t, "https://github.example.com/api/graphql", gql.graphQLUrl)
+
+	gql = NewGraphQLClient(&Options{})
+	assert.Equal(t, "https://api.github.com/graphql", gql.graphQLUrl)
+}
@@ -0,0 +1,178 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+)
+
+// Commit is a commit on the default branch of a repo. Author is the Github
+// login of the commit's author, or their git name when the commit is not
+// linked to a Github account.
+type Commit struct {
+	Author      string
+	CommittedAt time.Time
+	Headline    string
+	Sha         string
+}
+
+func (commit *Commit) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"author":       commit.Author,
+		"committed_at": commit.CommittedAt,
+		"headline":     commit.Headline,
+		"sha":          commit.Sha,
+	})
+}
+
+type ListCommitser interface {
+	ListCommits(context.Context, *log.Logger, string, string) ([]Commit, *errors.HttpError)
+}
+
+func commitsKey(owner, repo string) string {
+	return fmt.Sprintf("github:repo:%s:%s:commits", owner, repo)
+}
+
+func messageHeadline(message string) string {
+	if idx := strings.Index(message, "\n"); idx != -1 {
+		return message[:idx]
+	}
+	return message
+}
+
+func cleanCommitJsons(commits []map[string]interface{}) {
+	for _, commit := range commits {
+		keepKeys(commit, "author", "commit", "sha")
+		keepKeys(commit["author"], "login")
+		keepKeys(commit["commit"], "author", "committer", "message")
+		if gitCommit, ok := commit["commit"].(map[string]interface{}); ok {
+			keepKeys(gitCommit["author"], "name")
+			keepKeys(gitCommit["committer"], "date")
+			// Only the headline is interesting, and keeping just that saves a
+			// lot of space in the cache.
+			if message, ok := gitCommit["message"].(string); ok {
+				gitCommit["message"] = messageHeadline(message)
+			}
+		}
+	}
+}
+
+func commitDate(commit map[string]interface{}) time.Time {
+	gitCommit, _ := commit["commit"].(map[string]interface{})
+	committer, _ := gitCommit["committer"].(map[string]interface{})
+	rawDate, _ := committer["date"].(string)
+	// Commits without a date fail to parse later on, so it does not matter
+	// where they end up.
+	date, _ := time.Parse(time.RFC3339, rawDate)
+	return date
+}
+
+// newCommitsSince collects commits from a newest first listing that are not
+// cached yet, and merges them into the cached ones. The listing is ordered by
+// date, so commits from a newly merged branch can show up after cached ones;
+// it keeps paging until a whole page is cached, or the listing is older than
+// every cached commit. If none of the cached commits show up, for example
+// because the branch was force pushed, the listing replaces the cache.
+func newCommitsSince(
+	cached []map[string]interface{},
+	fetchPage func() ([]map[string]interface{}, bool, *errors.HttpError),
+) ([]map[string]interface{}, *errors.HttpError) {
+	known := make(map[string]bool)
+	var oldestCachedAt time.Time
+	for _, commit := range cached {
+		if sha, ok := commit["sha"].(string); ok {
+			known[sha] = true
+		}
+		if date := commitDate(commit); oldestCachedAt.IsZero() || date.Before(oldestCachedAt) {
+			oldestCachedAt = date
+		}
+	}
+	newCommits := make([]map[string]interface{}, 0)
+	foundCached := false
+	for {
+		page, hasNextPage, httpErr := fetchPage()
+		if httpErr != nil {
+			return nil, httpErr
+		}
+		pageIsKnown := true
+		for _, commit := range page {
+			sha, _ := commit["sha"].(string)
+			if known[sha] {
+				foundCached = true
+				continue
+			}
+			pageIsKnown = false
+			known[sha] = true
+			newCommits = append(newCommits, commit)
+		}
+		if !hasNextPage {
+			break
+		}
+		if foundCached && (pageIsKnown || commitDate(page[len(page)-1]).Before(oldestCachedAt)) {
+			break
+		}
+	}
+	if !foundCached {
+		return newCommits, nil
+	}
+	commits := append(newCommits, cached...)
+	sort.SliceStable(commits, func(i, j int) bool {
+		return commitDate(commits[i]).After(commitDate(commits[j]))
+	})
+	return commits, nil
+}
+
+func (gh *Client) ListCommits(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]Commit, *errors.HttpError) {
+	ctx, cancel := gh.withDeadline(ctx)
+	defer cancel()
+	rawCommits, httpErr := redisWrap(
+		ctx,
+		gh,
+		commitsKey(owner, repo),
+		"commits",
+		logger,
+		func(ctx context.Context, stale []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			url := fmt.Sprintf("%s/repos/%s/%s/commits?per_page=100", gh.baseUrl, owner, repo)
+			if len(stale) == 0 {
+				commits, httpErr := gh.paginateGithub(ctx, logger, url, "application/vnd.github.v3+json")
+				if httpErr != nil {
+					return nil, httpErr
+				}
+				cleanCommitJsons(commits)
+				return commits, nil
+			}
+			commits, httpErr := newCommitsSince(stale, func() ([]map[string]interface{}, bool, *errors.HttpError) {
+				contents, link, httpErr := gh.fetchGithubPage(ctx, logger, url, "application/vnd.github.v3+json")
+				if httpErr != nil {
+					return nil, false, httpErr
+				}
+				page, httpErr := decodeGithubPage(contents)
+				if httpErr != nil {
+					return nil, false, httpErr
+				}
+				cleanCommitJsons(page)
+				url = nextPageUrl(link)
+				return page, url != "", nil
+			})
+			if httpErr != nil {
+				return nil, httpErr
+			}
+			logger.Printf("Synced %d commits in %s/%s, %d were cached.\n", len(commits), owner, repo, len(stale))
+			return commits, nil
+		},
+	)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return parseCommits(logger, rawCommits)
+}
@@ -0,0 +1,184 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+	"github.com/ksheedlo/ghviz/mocks"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func commitJsonFixture(sha, login, date, message string) string {
+	author := "null"
+	if login != "" {
+		author = fmt.Sprintf(`{"login":%q,"id":1}`, login)
+	}
+	return fmt.Sprintf(`{
+	"sha":%q,
+	"author":%s,
+	"commit":{
+		"author":{"name":"Git Name","email":"git@example.com","date":%q},
+		"committer":{"name":"Git Name","email":"git@example.com","date":%q},
+		"message":%q
+	},
+	"html_url":"https://github.com/lodash/lodash/commit/%s"
+}`, sha, author, date, date, message, sha)
+}
+
+const lodashCommitsPath string = "/repos/lodash/lodash/commits?per_page=100"
+
+func TestListCommits(t *testing.T) {
+	t.Parallel()
+
+	var nextPage string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"next\"", nextPage))
+			fmt.Fprintf(w, "[%s]\n", commitJsonFixture("c2", "tester1", "2016-03-08T03:26:14Z", "Fix the build\n\nIt was broken."))
+		} else {
+			fmt.Fprintf(w, "[%s]\n", commitJsonFixture("c1", "", "2016-03-07T03:26:14Z", "Initial commit"))
+		}
+	}))
+	defer ts.Close()
+	nextPage = fmt.Sprintf("%s%s&page=2", ts.URL, lodashCommitsPath)
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	commits, err := gh.ListCommits(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, commits, 2)
+	assert.Equal(t, "c2", commits[0].Sha)
+	assert.Equal(t, "tester1", commits[0].Author)
+	assert.Equal(t, "Fix the build", commits[0].Headline)
+	assert.Equal(t, time.Date(2016, 3, 8, 3, 26, 14, 0, time.UTC), commits[0].CommittedAt.UTC())
+	assert.Equal(t, "Git Name", commits[1].Author)
+}
+
+func TestListCommitsStopsAtCachedCommit(t *testing.T) {
+	t.Parallel()
+
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.String())
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Add("Link", fmt.Sprintf("<%s%s&page=2>; rel=\"next\"", "http://"+r.Host, lodashCommitsPath))
+			fmt.Fprintf(
+				w,
+				"[%s,%s]\n",
+				commitJsonFixture("c3", "tester2", "2016-03-09T03:26:14Z", "Add a feature"),
+				commitJsonFixture("c2", "tester1", "2016-03-08T03:26:14Z", "Fix the build"),
+			)
+		} else {
+			w.Header().Add("Link", fmt.Sprintf("<%s%s&page=3>; rel=\"next\"", "http://"+r.Host, lodashCommitsPath))
+			fmt.Fprintf(w, "[%s]\n", commitJsonFixture("c1", "", "2016-03-07T03:26:14Z", "Initial commit"))
+		}
+	}))
+	defer ts.Close()
+
+	redisMock := &mocks.MockRediser{}
+	gh := NewClient(&Options{
+		BaseUrl:      ts.URL,
+		MaxStaleness: 5,
+		RedisClient:  redisMock,
+		Token:        "deadbeef",
+	})
+	cacheKey := commitsKey("lodash", "lodash")
+	stale := `[{"sha":"c2","author":{"login":"tester1"},
+		"commit":{"author":{"name":"Git Name"},"committer":{"date":"2016-03-08T03:26:14Z"},"message":"Fix the build"}},
+		{"sha":"c1","author":null,
+		"commit":{"author":{"name":"Git Name"},"committer":{"date":"2016-03-07T03:26:14Z"},"message":"Initial commit"}}]`
+	redisMock.On("Get", cacheKey).Return(
+		fmt.Sprintf("%d|%s", time.Now().Add(-6*time.Minute).Unix(), stale),
+		nil,
+	)
+	expectPageCacheMiss(redisMock, ts.URL, lodashCommitsPath)
+	expectPageCacheMiss(redisMock, ts.URL, lodashCommitsPath+"&page=2")
+	redisMock.On("Set", cacheKey, "", time.Duration(0)).Return(nil)
+
+	commits, err := gh.ListCommits(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	// The second page is all cached, so there is no need for a third.
+	assert.Equal(t, []string{lodashCommitsPath, lodashCommitsPath + "&page=2"}, requested)
+	assert.Len(t, commits, 3)
+	assert.Equal(t, "c3", commits[0].Sha)
+	assert.Equal(t, "tester2", commits[0].Author)
+	assert.Equal(t, "c2", commits[1].Sha)
+	assert.Equal(t, "c1", commits[2].Sha)
+	assert.Equal(t, "Git Name", commits[2].Author)
+	redisMock.AssertExpectations(t)
+}
+
+func TestListCommitsMergedOlderCommits(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Add("Link", fmt.Sprintf("<%s%s&page=2>; rel=\"next\"", "http://"+r.Host, lodashCommitsPath))
+			// c2 comes from a branch that was merged after c3 was cached.
+			fmt.Fprintf(
+				w,
+				"[%s,%s,%s]\n",
+				commitJsonFixture("c4", "tester1", "2016-03-10T03:26:14Z", "Merge the branch"),
+				commitJsonFixture("c3", "tester2", "2016-03-09T03:26:14Z", "Add a feature"),
+				commitJsonFixture("c2", "tester1", "2016-03-08T03:26:14Z", "Fix the build"),
+			)
+		} else {
+			fmt.Fprintf(w, "[%s]\n", commitJsonFixture("c1", "", "2016-03-07T03:26:14Z", "Initial commit"))
+		}
+	}))
+	defer ts.Close()
+
+	redisMock := &mocks.MockRediser{}
+	gh := NewClient(&Options{
+		BaseUrl:      ts.URL,
+		MaxStaleness: 5,
+		RedisClient:  redisMock,
+		Token:        "deadbeef",
+	})
+	cacheKey := commitsKey("lodash", "lodash")
+	stale := `[{"sha":"c3","author":{"login":"tester2"},
+		"commit":{"author":{"name":"Git Name"},"committer":{"date":"2016-03-09T03:26:14Z"},"message":"Add a feature"}},
+		{"sha":"c1","author":null,
+		"commit":{"author":{"name":"Git Name"},"committer":{"date":"2016-03-07T03:26:14Z"},"message":"Initial commit"}}]`
+	redisMock.On("Get", cacheKey).Return(
+		fmt.Sprintf("%d|%s", time.Now().Add(-6*time.Minute).Unix(), stale),
+		nil,
+	)
+	expectPageCacheMiss(redisMock, ts.URL, lodashCommitsPath)
+	expectPageCacheMiss(redisMock, ts.URL, lodashCommitsPath+"&page=2")
+	redisMock.On("Set", cacheKey, "", time.Duration(0)).Return(nil)
+
+	commits, err := gh.ListCommits(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, commits, 4)
+	for i, sha := range []string{"c4", "c3", "c2", "c1"} {
+		assert.Equal(t, sha, commits[i].Sha)
+	}
+	redisMock.AssertExpectations(t)
+}
+
+func TestListCommitsMissingDate(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `[{"sha":"c1","author":null,"commit":{"message":"Initial commit"}}]`)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	_, err := gh.ListCommits(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusBadGateway, err.Status)
+	assert.Equal(t, errors.CodeInvalidResponse, err.Code)
+}
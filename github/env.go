@@ -0,0 +1,104 @@
+package github
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// OptionsFromEnv configures how a service reaches Github from its
+// environment, on top of the defaults in options. It uses the personal
+// GITHUB_TOKEN unless a Github App private key is configured, in which case it
+// authenticates as that app's installation. Deadlines, retries and the
+// circuit breaker keep the defaults unless their variables are set.
+func OptionsFromEnv(options *Options) (*Options, error) {
+	return optionsFromEnv(os.Getenv, options)
+}
+
+func optionsFromEnv(getenv func(string) string, options *Options) (*Options, error) {
+	options.BaseUrl = getenv("GHVIZ_GITHUB_BASE_URL")
+	options.GraphQLUrl = getenv("GHVIZ_GITHUB_GRAPHQL_URL")
+	options.Token = getenv("GITHUB_TOKEN")
+	if codec := getenv("GHVIZ_CACHE_CODEC"); codec != "" {
+		if !IsCacheCodec(codec) {
+			return nil, fmt.Errorf("GHVIZ_CACHE_CODEC must be gzip or zstd, got %s", codec)
+		}
+		options.CacheCodec = codec
+	}
+	durations := []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"GHVIZ_GITHUB_BREAKER_COOL_OFF", &options.BreakerCoolOff},
+		{"GHVIZ_GITHUB_OVERALL_TIMEOUT", &options.OverallTimeout},
+		{"GHVIZ_GITHUB_REQUEST_TIMEOUT", &options.RequestTimeout},
+	}
+	for _, duration := range durations {
+		if err := durationFromEnv(getenv, duration.name, duration.value); err != nil {
+			return nil, err
+		}
+	}
+	counts := []struct {
+		name  string
+		value *int
+	}{
+		{"GHVIZ_GITHUB_BREAKER_THRESHOLD", &options.BreakerThreshold},
+		{"GHVIZ_GITHUB_MAX_RETRIES", &options.MaxRetries},
+	}
+	for _, count := range counts {
+		if err := countFromEnv(getenv, count.name, count.value); err != nil {
+			return nil, err
+		}
+	}
+	keyPath := getenv("GHVIZ_GITHUB_APP_KEY_PATH")
+	if keyPath == "" {
+		return options, nil
+	}
+	appId, err := strconv.ParseInt(getenv("GHVIZ_GITHUB_APP_ID"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("GHVIZ_GITHUB_APP_ID must be a number: %s", err.Error())
+	}
+	installationId, err := strconv.ParseInt(getenv("GHVIZ_GITHUB_INSTALLATION_ID"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("GHVIZ_GITHUB_INSTALLATION_ID must be a number: %s", err.Error())
+	}
+	privateKey, err := LoadAppPrivateKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	options.AppId = appId
+	options.AppPrivateKey = privateKey
+	options.InstallationId = installationId
+	return options, nil
+}
+
+// durationFromEnv overrides value with the duration (e.g. 30s) in the named
+// variable, if it is set.
+func durationFromEnv(getenv func(string) string, name string, value *time.Duration) error {
+	config := getenv(name)
+	if config == "" {
+		return nil
+	}
+	duration, err := time.ParseDuration(config)
+	if err != nil || duration < 0 {
+		return fmt.Errorf("%s must be a duration like 30s, got %s", name, config)
+	}
+	*value = duration
+	return nil
+}
+
+// countFromEnv overrides value with the number in the named variable, if it
+// is set.
+func countFromEnv(getenv func(string) string, name string, value *int) error {
+	config := getenv(name)
+	if config == "" {
+		return nil
+	}
+	count, err := strconv.Atoi(config)
+	if err != nil || count < 0 {
+		return fmt.Errorf("%s must be a number, got %s", name, config)
+	}
+	*value = count
+	return nil
+}
@@ -0,0 +1,104 @@
+package github
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeEnv(env map[string]string) func(string) string {
+	return func(key string) string {
+		return env[key]
+	}
+}
+
+func TestOptionsFromEnv(t *testing.T) {
+	t.Parallel()
+
+	options, err := optionsFromEnv(fakeEnv(map[string]string{
+		"GHVIZ_CACHE_CODEC":     "zstd",
+		"GHVIZ_GITHUB_BASE_URL": "https://github.example.com/api/v3",
+		"GITHUB_TOKEN":          "deadbeef",
+	}), &Options{MaxRetries: 2, RequestTimeout: 10 * time.Second})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.example.com/api/v3", options.BaseUrl)
+	assert.Equal(t, "deadbeef", options.Token)
+	assert.Equal(t, codecZstd, options.CacheCodec)
+	assert.Equal(t, 2, options.MaxRetries)
+	assert.Equal(t, 10*time.Second, options.RequestTimeout)
+	assert.Nil(t, options.AppPrivateKey)
+}
+
+func TestOptionsFromEnvDeadlines(t *testing.T) {
+	t.Parallel()
+
+	options, err := optionsFromEnv(fakeEnv(map[string]string{
+		"GHVIZ_GITHUB_BREAKER_COOL_OFF":  "1m",
+		"GHVIZ_GITHUB_BREAKER_THRESHOLD": "3",
+		"GHVIZ_GITHUB_MAX_RETRIES":       "0",
+		"GHVIZ_GITHUB_OVERALL_TIMEOUT":   "45s",
+	}), &Options{
+		BreakerCoolOff:   30 * time.Second,
+		BreakerThreshold: 5,
+		MaxRetries:       2,
+		OverallTimeout:   30 * time.Second,
+		RequestTimeout:   10 * time.Second,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, options.BreakerCoolOff)
+	assert.Equal(t, 3, options.BreakerThreshold)
+	assert.Equal(t, 0, options.MaxRetries)
+	assert.Equal(t, 45*time.Second, options.OverallTimeout)
+	assert.Equal(t, 10*time.Second, options.RequestTimeout)
+}
+
+func TestOptionsFromEnvApp(t *testing.T) {
+	t.Parallel()
+
+	key := generateAppKey(t)
+	keyPath := filepath.Join(t.TempDir(), "app.pem")
+	assert.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}), 0600))
+
+	options, err := optionsFromEnv(fakeEnv(map[string]string{
+		"GHVIZ_GITHUB_APP_ID":          "42",
+		"GHVIZ_GITHUB_APP_KEY_PATH":    keyPath,
+		"GHVIZ_GITHUB_INSTALLATION_ID": "7",
+	}), &Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), options.AppId)
+	assert.Equal(t, int64(7), options.InstallationId)
+	assert.Equal(t, key.N, options.AppPrivateKey.N)
+}
+
+func TestOptionsFromEnvErrors(t *testing.T) {
+	t.Parallel()
+
+	for name, env := range map[string]map[string]string{
+		"unknown codec":    {"GHVIZ_CACHE_CODEC": "brotli"},
+		"bad timeout":      {"GHVIZ_GITHUB_REQUEST_TIMEOUT": "10"},
+		"negative timeout": {"GHVIZ_GITHUB_OVERALL_TIMEOUT": "-1s"},
+		"bad retries":      {"GHVIZ_GITHUB_MAX_RETRIES": "two"},
+		"negative retries": {"GHVIZ_GITHUB_MAX_RETRIES": "-1"},
+		"bad app id": {
+			"GHVIZ_GITHUB_APP_ID":          "lodash",
+			"GHVIZ_GITHUB_APP_KEY_PATH":    "app.pem",
+			"GHVIZ_GITHUB_INSTALLATION_ID": "7",
+		},
+		"bad installation id": {
+			"GHVIZ_GITHUB_APP_ID":          "42",
+			"GHVIZ_GITHUB_APP_KEY_PATH":    "app.pem",
+			"GHVIZ_GITHUB_INSTALLATION_ID": "",
+		},
+	} {
+		_, err := optionsFromEnv(fakeEnv(env), &Options{})
+		assert.Error(t, err, name)
+	}
+}
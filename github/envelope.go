@@ -0,0 +1,178 @@
+package github
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Cached values are stored in a versioned envelope: the envelope prefix, a
+// JSON header line, and then the (possibly compressed) payload. Values
+// without the prefix are in the legacy "<unix>|<json>" format, or plain JSON
+// for cached pages.
+const (
+	cacheEnvelopePrefix  = "ghviz:"
+	cacheEnvelopeVersion = 2
+
+	codecIdentity = "identity"
+	codecGzip     = "gzip"
+	codecZstd     = "zstd"
+)
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// The zstd encoder and decoder are safe for concurrent use, and expensive
+// enough to set up that they are shared.
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+func initZstd() {
+	// Suppress errors from NewWriter and NewReader. They only fail on
+	// invalid options.
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+}
+
+// IsCacheCodec reports whether codec can be used to compress cached values.
+func IsCacheCodec(codec string) bool {
+	return codec == codecGzip || codec == codecZstd
+}
+
+// cacheEnvelope is the header of a cached value. Only cached pages have an
+// ETag; item lists are built from many pages, so they have none.
+type cacheEnvelope struct {
+	Checksum  string `json:"checksum"`
+	Codec     string `json:"codec"`
+	ETag      string `json:"etag,omitempty"`
+	FetchedAt int64  `json:"fetched_at"`
+	Version   int    `json:"version"`
+}
+
+func payloadChecksum(payload []byte) string {
+	return fmt.Sprintf("%08x", crc32.Checksum(payload, castagnoliTable))
+}
+
+func compress(codec string, payload []byte) ([]byte, error) {
+	switch codec {
+	case codecIdentity:
+		return payload, nil
+	case codecGzip:
+		var compressed bytes.Buffer
+		writer := gzip.NewWriter(&compressed)
+		if _, err := writer.Write(payload); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return compressed.Bytes(), nil
+	case codecZstd:
+		zstdOnce.Do(initZstd)
+		return zstdEncoder.EncodeAll(payload, nil), nil
+	}
+	return nil, fmt.Errorf("Unknown cache codec %s", codec)
+}
+
+func decompress(codec string, payload []byte) ([]byte, error) {
+	switch codec {
+	case codecIdentity:
+		return payload, nil
+	case codecGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	case codecZstd:
+		zstdOnce.Do(initZstd)
+		return zstdDecoder.DecodeAll(payload, nil)
+	}
+	return nil, fmt.Errorf("Unknown cache codec %s", codec)
+}
+
+// encodeCacheEnvelope compresses the payload with the codec and wraps it in an
+// envelope recording when it was fetched and the ETag it was fetched with, if
+// any.
+func encodeCacheEnvelope(codec string, fetchedAt time.Time, etag string, payload []byte) (string, error) {
+	compressed, err := compress(codec, payload)
+	if err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(&cacheEnvelope{
+		Checksum:  payloadChecksum(compressed),
+		Codec:     codec,
+		ETag:      etag,
+		FetchedAt: fetchedAt.Unix(),
+		Version:   cacheEnvelopeVersion,
+	})
+	if err != nil {
+		return "", err
+	}
+	return cacheEnvelopePrefix + string(header) + "\n" + string(compressed), nil
+}
+
+func isCacheEnvelope(value string) bool {
+	return strings.HasPrefix(value, cacheEnvelopePrefix)
+}
+
+// decodeCacheEnvelope unwraps an envelope and returns its header and the
+// decompressed payload. Unknown versions and codecs, checksum mismatches and
+// truncated values are all errors, which callers treat as cache misses.
+func decodeCacheEnvelope(cacheKey, value string) (*cacheEnvelope, []byte, error) {
+	idx := strings.Index(value, "\n")
+	if !isCacheEnvelope(value) || idx == -1 {
+		return nil, nil, fmt.Errorf("Redis query for key %s returned a malformatted value", cacheKey)
+	}
+	envelope := &cacheEnvelope{}
+	if err := json.Unmarshal([]byte(value[len(cacheEnvelopePrefix):idx]), envelope); err != nil {
+		return nil, nil, fmt.Errorf("Cached value for key %s has a corrupt header: %s", cacheKey, err.Error())
+	}
+	if envelope.Version != cacheEnvelopeVersion {
+		return nil, nil, fmt.Errorf("Cached value for key %s has unknown version %d", cacheKey, envelope.Version)
+	}
+	payload := []byte(value[(idx + 1):])
+	if payloadChecksum(payload) != envelope.Checksum {
+		return nil, nil, fmt.Errorf("Cached value for key %s failed its checksum", cacheKey)
+	}
+	decompressed, err := decompress(envelope.Codec, payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Cached value for key %s could not be decompressed: %s", cacheKey, err.Error())
+	}
+	return envelope, decompressed, nil
+}
+
+// parseRedisValues returns when the cached value was fetched and its JSON
+// payload, reading both envelopes and the legacy "<unix>|<json>" format.
+func parseRedisValues(cacheKey, cachedValues string) (time.Time, []byte, error) {
+	if isCacheEnvelope(cachedValues) {
+		envelope, jsonBytes, err := decodeCacheEnvelope(cacheKey, cachedValues)
+		if err != nil {
+			return time.Unix(0, 0), nil, err
+		}
+		return time.Unix(envelope.FetchedAt, 0), jsonBytes, nil
+	}
+	idx := strings.Index(cachedValues, "|")
+	if idx == -1 {
+		return time.Unix(0, 0), nil, fmt.Errorf(
+			"Redis query for key %s returned a malformatted value", cacheKey)
+	}
+	unixSeconds, err := strconv.ParseInt(cachedValues[:idx], 10, 64)
+	if err != nil {
+		return time.Unix(0, 0), nil, err
+	}
+	jsonBytes := []byte(cachedValues[(idx + 1):])
+	return time.Unix(unixSeconds, 0), jsonBytes, nil
+}
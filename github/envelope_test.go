@@ -0,0 +1,208 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/interfaces"
+	"github.com/ksheedlo/ghviz/mocks"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheEnvelopeRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	fetchedAt := time.Unix(1457321174, 0)
+	payload := []byte(`[{"created_at":"2016-03-07T03:26:14Z"}]`)
+	for _, codec := range []string{codecGzip, codecZstd} {
+		value, err := encodeCacheEnvelope(codec, fetchedAt, `"abc123"`, payload)
+		assert.NoError(t, err, codec)
+		assert.True(t, strings.HasPrefix(value, cacheEnvelopePrefix), codec)
+
+		envelope, decoded, err := decodeCacheEnvelope("key", value)
+		assert.NoError(t, err, codec)
+		assert.Equal(t, payload, decoded, codec)
+		assert.Equal(t, cacheEnvelopeVersion, envelope.Version, codec)
+		assert.Equal(t, codec, envelope.Codec)
+		assert.Equal(t, `"abc123"`, envelope.ETag, codec)
+		assert.Equal(t, fetchedAt.Unix(), envelope.FetchedAt, codec)
+
+		timeSubmitted, jsonBytes, err := parseRedisValues("key", value)
+		assert.NoError(t, err, codec)
+		assert.Equal(t, fetchedAt.Unix(), timeSubmitted.Unix(), codec)
+		assert.Equal(t, payload, jsonBytes, codec)
+	}
+}
+
+func TestParseRedisValuesLegacyFormat(t *testing.T) {
+	t.Parallel()
+
+	timeSubmitted, jsonBytes, err := parseRedisValues("key", `1457321174|[{"id":1}]`)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Unix(1457321174, 0), timeSubmitted)
+	assert.Equal(t, []byte(`[{"id":1}]`), jsonBytes)
+}
+
+func TestDecodeCacheEnvelopeRejectsBadValues(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte(`[{"id":1}]`)
+	identity := func(header string) string {
+		return cacheEnvelopePrefix + header + "\n" + string(payload)
+	}
+	checksum := payloadChecksum(payload)
+	valid, err := encodeCacheEnvelope(codecGzip, time.Now(), "", payload)
+	assert.NoError(t, err)
+
+	_, decoded, err := decodeCacheEnvelope("key", identity(fmt.Sprintf(
+		`{"checksum":"%s","codec":"identity","fetched_at":1,"version":2}`, checksum)))
+	assert.NoError(t, err)
+	assert.Equal(t, payload, decoded)
+
+	for name, value := range map[string]string{
+		"no header":      cacheEnvelopePrefix + `{"version":2}`,
+		"corrupt header": identity(`{"version":`),
+		"unknown version": identity(fmt.Sprintf(
+			`{"checksum":"%s","codec":"identity","fetched_at":1,"version":3}`, checksum)),
+		"bad checksum": identity(`{"checksum":"00000000","codec":"identity","fetched_at":1,"version":2}`),
+		"truncated":    valid[:len(valid)-4],
+		"corrupt zstd": identity(fmt.Sprintf(
+			`{"checksum":"%s","codec":"zstd","fetched_at":1,"version":2}`, checksum)),
+		"unknown codec": identity(fmt.Sprintf(
+			`{"checksum":"%s","codec":"brotli","fetched_at":1,"version":2}`, checksum)),
+	} {
+		_, _, err := decodeCacheEnvelope("key", value)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestRedisWrapTreatsUnknownVersionAsMiss(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, lodashForksPath, r.URL.String())
+		fmt.Fprintln(w, `[{"created_at":"2016-03-07T03:26:14Z"},{"created_at":"2016-03-08T03:26:14Z"}]`)
+	}))
+	defer ts.Close()
+
+	redisMock := &mocks.MockRediser{}
+	gh := NewClient(&Options{
+		BaseUrl:     ts.URL,
+		RedisClient: redisMock,
+		Token:       "deadbeef",
+	})
+	payload := []byte(`[{"created_at":"2016-03-07T03:26:14Z"}]`)
+	redisMock.On("Get", forksKey("lodash", "lodash")).Return(
+		fmt.Sprintf(
+			"%s{\"checksum\":\"%s\",\"codec\":\"identity\",\"fetched_at\":%d,\"version\":99}\n%s",
+			cacheEnvelopePrefix,
+			payloadChecksum(payload),
+			time.Now().Unix(),
+			payload,
+		),
+		nil,
+	)
+	expectPageCacheMiss(redisMock, ts.URL, lodashForksPath)
+	redisMock.On("Set", forksKey("lodash", "lodash"), "", time.Duration(0)).Return(nil)
+
+	forkEvents, err := gh.ListForks(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.Nil(t, err)
+	assert.Len(t, forkEvents, 2)
+	redisMock.AssertExpectations(t)
+}
+
+func TestCachedPageEnvelope(t *testing.T) {
+	t.Parallel()
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"abc123"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"abc123"`)
+		fmt.Fprintln(w, `[{"created_at":"2016-03-07T03:26:14Z"}]`)
+	}))
+	defer ts.Close()
+
+	redis := interfaces.NewMemoryRedis(clockwork.NewRealClock(), 1<<20)
+	gh := NewClient(&Options{
+		BaseUrl:     ts.URL,
+		RedisClient: redis,
+		Token:       "deadbeef",
+	})
+	ctx := context.Background()
+	logger := mocks.DummyLogger(t)
+	body, _, err := gh.fetchGithubPage(ctx, logger, ts.URL+lodashForksPath, "application/json")
+	assert.Nil(t, err)
+
+	value, redisErr := redis.Get(ctx, pageKey(ts.URL+lodashForksPath))
+	assert.NoError(t, redisErr)
+	envelope, _, decodeErr := decodeCacheEnvelope(pageKey(ts.URL+lodashForksPath), value)
+	assert.NoError(t, decodeErr)
+	assert.Equal(t, `"abc123"`, envelope.ETag)
+
+	revalidated, _, err := gh.fetchGithubPage(ctx, logger, ts.URL+lodashForksPath, "application/json")
+	assert.Nil(t, err)
+	assert.Equal(t, body, revalidated)
+	assert.Equal(t, 2, requests)
+}
+
+func TestCachedPageLegacyFormat(t *testing.T) {
+	t.Parallel()
+
+	redis := interfaces.NewMemoryRedis(clockwork.NewRealClock(), 1<<20)
+	gh := NewClient(&Options{RedisClient: redis})
+	ctx := context.Background()
+	redis.Set(ctx, pageKey("/page"), `{"body":"[]","etag":"\"abc123\"","last_modified":"","link":""}`, 0)
+
+	page := gh.loadCachedPage(ctx, mocks.DummyLogger(t), "/page")
+	assert.Equal(t, &cachedPage{Body: "[]", ETag: `"abc123"`}, page)
+}
+
+func TestCachedItemsUseConfiguredCodec(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc123"`)
+		fmt.Fprintln(w, `[{"created_at":"2016-03-07T03:26:14Z"},{"created_at":"2016-03-08T03:26:14Z"}]`)
+	}))
+	defer ts.Close()
+
+	redis := interfaces.NewMemoryRedis(clockwork.NewRealClock(), 1<<20)
+	gh := NewClient(&Options{
+		BaseUrl:     ts.URL,
+		CacheCodec:  codecZstd,
+		RedisClient: redis,
+		Token:       "deadbeef",
+	})
+	ctx := context.Background()
+	logger := mocks.DummyLogger(t)
+	_, err := gh.ListForks(ctx, logger, "lodash", "lodash")
+	assert.Nil(t, err)
+
+	value, redisErr := redis.Get(ctx, forksKey("lodash", "lodash"))
+	assert.NoError(t, redisErr)
+	envelope, payload, decodeErr := decodeCacheEnvelope(forksKey("lodash", "lodash"), value)
+	assert.NoError(t, decodeErr)
+	assert.Equal(t, codecZstd, envelope.Codec)
+	assert.Empty(t, envelope.ETag)
+	assert.Contains(t, string(payload), "2016-03-08T03:26:14Z")
+}
+
+func TestUnknownCacheCodecFallsBackToGzip(t *testing.T) {
+	t.Parallel()
+
+	gh := NewClient(&Options{CacheCodec: "brotli"})
+	assert.Equal(t, codecGzip, gh.cacheCodec)
+	assert.True(t, IsCacheCodec(codecZstd))
+	assert.False(t, IsCacheCodec("brotli"))
+}
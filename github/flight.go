@@ -0,0 +1,220 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+	"github.com/ksheedlo/ghviz/interfaces"
+)
+
+const defaultFetchLockTimeout time.Duration = 30 * time.Second
+
+// fetchLockPoll is how often we check whether another process holding the
+// fetch lock for a key has stored its items.
+const fetchLockPoll time.Duration = 100 * time.Millisecond
+
+type flightCall struct {
+	done  chan struct{}
+	err   *errors.HttpError
+	items []map[string]interface{}
+}
+
+// flightGroup tracks the fetches in flight in this process by cache key.
+type flightGroup struct {
+	sync.Mutex
+	calls map[string]*flightCall
+}
+
+// join returns the call in flight for the key, or starts a new one, in which
+// case the caller leads it and must finish it.
+func (group *flightGroup) join(key string) (*flightCall, bool) {
+	group.Lock()
+	defer group.Unlock()
+	if group.calls == nil {
+		group.calls = make(map[string]*flightCall)
+	}
+	if call, ok := group.calls[key]; ok {
+		return call, false
+	}
+	call := &flightCall{done: make(chan struct{})}
+	group.calls[key] = call
+	return call, true
+}
+
+func (group *flightGroup) finish(key string, call *flightCall) {
+	group.Lock()
+	delete(group.calls, key)
+	group.Unlock()
+	close(call.done)
+}
+
+func fetchLockKey(cacheKey string) string {
+	return cacheKey + ":lock"
+}
+
+// fetchShared calls fallback and stores what it returns, making sure that a
+// key is only fetched once at a time. Concurrent callers in this process wait
+// for the first one and share its result or error. Processes sharing Redis
+// take turns through a short lived lock, and a process that finds the key
+// locked waits for the lock holder to store the items instead.
+func fetchShared(
+	ctx context.Context,
+	gh *Client,
+	cacheKey string,
+	logger *log.Logger,
+	staleItems []map[string]interface{},
+	fallback func(context.Context, []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError),
+	onStored func(context.Context),
+) ([]map[string]interface{}, *errors.HttpError) {
+	call, leader := gh.flights.join(cacheKey)
+	if leader {
+		// The fetch is shared, so it must not fail just because the caller
+		// that started it went away.
+		go func() {
+			fetchCtx, cancel := gh.withDeadline(detach(ctx))
+			defer cancel()
+			call.items, call.err = fetchLocked(fetchCtx, gh, cacheKey, logger, staleItems, fallback, onStored)
+			gh.flights.finish(cacheKey, call)
+		}()
+	} else {
+		logger.Printf("Waiting for the fetch of %s already in flight.\n", cacheKey)
+	}
+	select {
+	case <-call.done:
+		return call.items, call.err
+	case <-ctx.Done():
+		return nil, requestError(ctx, ctx.Err())
+	}
+}
+
+func fetchLocked(
+	ctx context.Context,
+	gh *Client,
+	cacheKey string,
+	logger *log.Logger,
+	staleItems []map[string]interface{},
+	fallback func(context.Context, []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError),
+	onStored func(context.Context),
+) ([]map[string]interface{}, *errors.HttpError) {
+	locker, ok := gh.redisClient.(interfaces.Locker)
+	if !ok {
+		items, httpErr := fallback(ctx, staleItems)
+		if httpErr == nil {
+			storeFetched(ctx, gh, cacheKey, logger, items, onStored)
+		}
+		return items, httpErr
+	}
+
+	lockKey := fetchLockKey(cacheKey)
+	token, err := interfaces.RandomTag()
+	if err != nil {
+		return nil, &errors.HttpError{
+			Cause:   err,
+			Message: "Internal Server Error",
+			Status:  http.StatusInternalServerError,
+		}
+	}
+	started := gh.clock.Now()
+	locked, err := locker.SetNX(ctx, lockKey, token, gh.fetchLockTimeout)
+	if err != nil {
+		// Fetching without the lock beats not fetching at all.
+		logger.Printf("Failed to lock %s: %s\n", lockKey, err.Error())
+	} else if !locked {
+		logger.Printf("Key %s is being fetched elsewhere, waiting for it.\n", cacheKey)
+		if items, ok := waitForFetch(ctx, gh, cacheKey, logger, started); ok {
+			return items, nil
+		}
+		if ctx.Err() != nil {
+			return nil, requestError(ctx, ctx.Err())
+		}
+		logger.Printf("Gave up waiting for %s, fetching it from Github.\n", cacheKey)
+	}
+
+	items, httpErr := fallback(ctx, staleItems)
+	if httpErr == nil {
+		storeFetched(ctx, gh, cacheKey, logger, items, onStored)
+	}
+	if locked {
+		releaseFetchLock(ctx, locker, lockKey, token, logger)
+	}
+	return items, httpErr
+}
+
+// storeFetched stores the items fallback fetched, and calls onStored if that
+// worked.
+func storeFetched(
+	ctx context.Context,
+	gh *Client,
+	cacheKey string,
+	logger *log.Logger,
+	items []map[string]interface{},
+	onStored func(context.Context),
+) {
+	if storeItems(ctx, gh, cacheKey, logger, items) && onStored != nil {
+		onStored(ctx)
+	}
+}
+
+// waitForFetch waits until another process stores items for the key, or
+// until its lock goes away or expires.
+func waitForFetch(
+	ctx context.Context,
+	gh *Client,
+	cacheKey string,
+	logger *log.Logger,
+	started time.Time,
+) ([]map[string]interface{}, bool) {
+	// Stored timestamps only have a resolution of seconds.
+	since := started.Truncate(time.Second)
+	deadline := gh.clock.Now().Add(gh.fetchLockTimeout)
+	for gh.clock.Now().Before(deadline) {
+		select {
+		case <-gh.clock.After(fetchLockPoll):
+		case <-ctx.Done():
+			return nil, false
+		}
+		if items, ok := fetchedSince(ctx, gh, cacheKey, since); ok {
+			return items, true
+		}
+		if _, err := gh.redisClient.Get(ctx, fetchLockKey(cacheKey)); err == interfaces.ErrNil {
+			// The lock may have been released right after the items were
+			// stored, so look one last time.
+			return fetchedSince(ctx, gh, cacheKey, since)
+		}
+	}
+	return nil, false
+}
+
+func fetchedSince(
+	ctx context.Context,
+	gh *Client,
+	cacheKey string,
+	since time.Time,
+) ([]map[string]interface{}, bool) {
+	cachedItems, err := gh.redisClient.Get(ctx, cacheKey)
+	if err != nil || cachedItems == "" {
+		return nil, false
+	}
+	timeSubmitted, jsonBytes, err := parseRedisValues(cacheKey, cachedItems)
+	if err != nil || timeSubmitted.Before(since) {
+		return nil, false
+	}
+	var items []map[string]interface{}
+	if err := json.Unmarshal(jsonBytes, &items); err != nil {
+		return nil, false
+	}
+	return items, true
+}
+
+// releaseFetchLock deletes the lock unless it expired and someone else took
+// it in the meantime.
+func releaseFetchLock(ctx context.Context, locker interfaces.Locker, lockKey, token string, logger *log.Logger) {
+	if _, err := locker.DelIfEqual(ctx, lockKey, token); err != nil {
+		logger.Printf("Failed to unlock %s: %s\n", lockKey, err.Error())
+	}
+}
@@ -0,0 +1,239 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+	"github.com/ksheedlo/ghviz/interfaces"
+	"github.com/ksheedlo/ghviz/mocks"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchSharedCoalescesCallers(t *testing.T) {
+	t.Parallel()
+
+	gh := NewClient(&Options{})
+	logger := mocks.DummyLogger(t)
+	var mutex sync.Mutex
+	fetches := 0
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fallback := func(context.Context, []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+		mutex.Lock()
+		fetches++
+		mutex.Unlock()
+		close(started)
+		<-release
+		return []map[string]interface{}{{"name": "lodash"}}, nil
+	}
+
+	results := make([][]map[string]interface{}, 5)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], _ = fetchShared(context.Background(), gh, "key", logger, nil, fallback, nil)
+	}()
+	<-started
+	for i := 1; i < len(results); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			items, err := fetchShared(context.Background(), gh, "key", logger, nil, fallback, nil)
+			assert.Nil(t, err)
+			results[i] = items
+		}(i)
+	}
+	// Give the waiters time to join the fetch in flight.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, 1, fetches)
+	for _, items := range results {
+		assert.Equal(t, []map[string]interface{}{{"name": "lodash"}}, items)
+	}
+}
+
+func TestFetchSharedSharesErrors(t *testing.T) {
+	t.Parallel()
+
+	gh := NewClient(&Options{})
+	logger := mocks.DummyLogger(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fetchErr := &errors.HttpError{Message: "Github API Error", Status: http.StatusBadGateway}
+
+	var leaderErr *errors.HttpError
+	done := make(chan struct{})
+	go func() {
+		_, leaderErr = fetchShared(context.Background(), gh, "key", logger, nil, func(context.Context, []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			close(started)
+			<-release
+			return nil, fetchErr
+		}, nil)
+		close(done)
+	}()
+	<-started
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	_, err := fetchShared(context.Background(), gh, "key", logger, nil, func(context.Context, []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+		assert.Fail(t, "The key should only be fetched once!")
+		return nil, nil
+	}, nil)
+	<-done
+	assert.Equal(t, fetchErr, err)
+	assert.Equal(t, fetchErr, leaderErr)
+}
+
+func TestFetchSharedWaiterCanceled(t *testing.T) {
+	t.Parallel()
+
+	gh := NewClient(&Options{})
+	logger := mocks.DummyLogger(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	go fetchShared(context.Background(), gh, "key", logger, nil, func(context.Context, []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+		close(started)
+		<-release
+		return nil, nil
+	}, nil)
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := fetchShared(ctx, gh, "key", logger, nil, nil, nil)
+	assert.Equal(t, errors.CodeCanceled, err.Code)
+}
+
+func TestFetchSharedSurvivesLeaderCancel(t *testing.T) {
+	t.Parallel()
+
+	gh := NewClient(&Options{})
+	logger := mocks.DummyLogger(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	var leaderErr *errors.HttpError
+	done := make(chan struct{})
+	go func() {
+		_, leaderErr = fetchShared(leaderCtx, gh, "key", logger, nil, func(ctx context.Context, _ []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			close(started)
+			<-release
+			if ctx.Err() != nil {
+				return nil, requestError(ctx, ctx.Err())
+			}
+			return []map[string]interface{}{{"name": "lodash"}}, nil
+		}, nil)
+		close(done)
+	}()
+	<-started
+	go func() {
+		// The leader's client goes away while the waiter is still around.
+		time.Sleep(50 * time.Millisecond)
+		cancelLeader()
+		<-done
+		close(release)
+	}()
+	items, err := fetchShared(context.Background(), gh, "key", logger, nil, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]interface{}{{"name": "lodash"}}, items)
+	assert.Equal(t, errors.CodeCanceled, leaderErr.Code)
+}
+
+func TestListForksWaitsForOtherReplica(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Fail(t, "The other replica should fetch the forks!")
+	}))
+	defer ts.Close()
+
+	redis := interfaces.NewMemoryRedis(clockwork.NewRealClock(), 1<<20)
+	gh := NewClient(&Options{
+		BaseUrl:     ts.URL,
+		RedisClient: redis,
+		Token:       "deadbeef",
+	})
+	ctx := context.Background()
+	cacheKey := forksKey("lodash", "lodash")
+	locked, err := redis.SetNX(ctx, fetchLockKey(cacheKey), "other", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, locked)
+	go func() {
+		time.Sleep(250 * time.Millisecond)
+		redis.Set(ctx, cacheKey, fmt.Sprintf(`%d|[{"created_at":"2016-03-07T03:26:14Z"}]`, time.Now().Unix()), 0)
+		redis.Del(ctx, fetchLockKey(cacheKey))
+	}()
+
+	forkEvents, httpErr := gh.ListForks(ctx, mocks.DummyLogger(t), "lodash", "lodash")
+	assert.Nil(t, httpErr)
+	assert.Len(t, forkEvents, 1)
+}
+
+func TestListForksFetchesWhenLockExpires(t *testing.T) {
+	t.Parallel()
+
+	var mutex sync.Mutex
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests++
+		mutex.Unlock()
+		fmt.Fprintln(w, `[{"created_at":"2016-03-07T03:26:14Z"},{"created_at":"2016-03-08T03:26:14Z"}]`)
+	}))
+	defer ts.Close()
+
+	redis := interfaces.NewMemoryRedis(clockwork.NewRealClock(), 1<<20)
+	gh := NewClient(&Options{
+		BaseUrl:          ts.URL,
+		FetchLockTimeout: time.Second,
+		RedisClient:      redis,
+		Token:            "deadbeef",
+	})
+	ctx := context.Background()
+	cacheKey := forksKey("lodash", "lodash")
+	// The other replica died without storing anything.
+	_, err := redis.SetNX(ctx, fetchLockKey(cacheKey), "other", 200*time.Millisecond)
+	assert.NoError(t, err)
+
+	forkEvents, httpErr := gh.ListForks(ctx, mocks.DummyLogger(t), "lodash", "lodash")
+	assert.Nil(t, httpErr)
+	assert.Len(t, forkEvents, 2)
+	assert.Equal(t, 1, requests)
+}
+
+func TestListForksReleasesFetchLock(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `[{"created_at":"2016-03-07T03:26:14Z"}]`)
+	}))
+	defer ts.Close()
+
+	redis := interfaces.NewMemoryRedis(clockwork.NewRealClock(), 1<<20)
+	gh := NewClient(&Options{
+		BaseUrl:     ts.URL,
+		RedisClient: redis,
+		Token:       "deadbeef",
+	})
+	ctx := context.Background()
+	_, httpErr := gh.ListForks(ctx, mocks.DummyLogger(t), "lodash", "lodash")
+	assert.Nil(t, httpErr)
+
+	_, err := redis.Get(ctx, fetchLockKey(forksKey("lodash", "lodash")))
+	assert.Equal(t, interfaces.ErrNil, err)
+	_, err = redis.Get(ctx, forksKey("lodash", "lodash"))
+	assert.NoError(t, err)
+}
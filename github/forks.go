@@ -0,0 +1,110 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+)
+
+type ForkEvent struct {
+	ForkedAt time.Time
+}
+
+type byForkedAt []ForkEvent
+
+func (a byForkedAt) Len() int           { return len(a) }
+func (a byForkedAt) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byForkedAt) Less(i, j int) bool { return a[i].ForkedAt.Before(a[j].ForkedAt) }
+
+type ListForkser interface {
+	ListForks(context.Context, *log.Logger, string, string) ([]ForkEvent, *errors.HttpError)
+}
+
+// GetWatcherCounter reads how many people watch a repo right now. Github
+// keeps no history of watchers, so the only way to chart them is to take
+// snapshots of this count over time.
+type GetWatcherCounter interface {
+	GetWatcherCount(context.Context, *log.Logger, string, string) (int, *errors.HttpError)
+}
+
+func forksKey(owner, repo string) string {
+	return fmt.Sprintf("github:repo:%s:%s:forks", owner, repo)
+}
+
+func cleanForkJsons(forks []map[string]interface{}) {
+	for _, fork := range forks {
+		keepKeys(fork, "created_at")
+	}
+}
+
+func (gh *Client) ListForks(ctx context.Context, logger *log.Logger, owner, repo string) ([]ForkEvent, *errors.HttpError) {
+	ctx, cancel := gh.withDeadline(ctx)
+	defer cancel()
+	rawForks, httpErr := redisWrap(
+		ctx,
+		gh,
+		forksKey(owner, repo),
+		"forks",
+		logger,
+		func(ctx context.Context, _ []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			forks, httpErr := gh.paginateGithub(
+				ctx,
+				logger,
+				fmt.Sprintf("%s/repos/%s/%s/forks?per_page=100&sort=oldest", gh.baseUrl, owner, repo),
+				"application/vnd.github.v3+json",
+			)
+			if httpErr != nil {
+				return nil, httpErr
+			}
+			cleanForkJsons(forks)
+			return forks, nil
+		},
+	)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return parseForkEvents(logger, rawForks)
+}
+
+func parseForkEvents(logger *log.Logger, rawForks []map[string]interface{}) ([]ForkEvent, *errors.HttpError) {
+	forkEvents := make([]ForkEvent, len(rawForks))
+	for i, fork := range rawForks {
+		rawCreatedAt, _ := fork["created_at"].(string)
+		forkedAt, httpErr := parseTimestamp(logger, "created_at", rawCreatedAt)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+		forkEvents[i].ForkedAt = forkedAt
+	}
+	sort.Sort(byForkedAt(forkEvents))
+	return forkEvents, nil
+}
+
+func (gh *Client) GetWatcherCount(ctx context.Context, logger *log.Logger, owner, repo string) (int, *errors.HttpError) {
+	ctx, cancel := gh.withDeadline(ctx)
+	defer cancel()
+	contents, _, httpErr := gh.fetchGithubPage(
+		ctx,
+		logger,
+		fmt.Sprintf("%s/repos/%s/%s", gh.baseUrl, owner, repo),
+		"application/vnd.github.v3+json",
+	)
+	if httpErr != nil {
+		return 0, httpErr
+	}
+	var repository struct {
+		SubscribersCount *int `json:"subscribers_count"`
+	}
+	if err := json.Unmarshal(contents, &repository); err != nil {
+		return 0, schemaError(logger, err)
+	}
+	if repository.SubscribersCount == nil {
+		return 0, schemaError(logger, fmt.Errorf("repository is missing its subscribers_count"))
+	}
+	return *repository.SubscribersCount, nil
+}
@@ -0,0 +1,76 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+	"github.com/ksheedlo/ghviz/mocks"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListForks(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/lodash/lodash/forks?per_page=100&sort=oldest", r.URL.String())
+		fmt.Fprintln(w, `[
+			{"full_name":"tester1/lodash","owner":{"login":"tester1"},"created_at":"2016-03-07T03:26:14Z"},
+			{"full_name":"tester2/lodash","owner":{"login":"tester2"},"created_at":"2016-03-09T03:26:14Z"},
+			{"full_name":"tester3/lodash","owner":{"login":"tester3"},"created_at":"2016-03-08T03:26:14Z"}
+		]`)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	forkEvents, err := gh.ListForks(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Equal(t, []ForkEvent{
+		ForkEvent{ForkedAt: time.Date(2016, 3, 7, 3, 26, 14, 0, time.UTC)},
+		ForkEvent{ForkedAt: time.Date(2016, 3, 8, 3, 26, 14, 0, time.UTC)},
+		ForkEvent{ForkedAt: time.Date(2016, 3, 9, 3, 26, 14, 0, time.UTC)},
+	}, forkEvents)
+}
+
+func TestGetWatcherCount(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/lodash/lodash", r.URL.String())
+		fmt.Fprintln(w, `{"full_name":"lodash/lodash","watchers_count":50,"subscribers_count":7}`)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	watchers, err := gh.GetWatcherCount(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Equal(t, 7, watchers)
+}
+
+func TestGetWatcherCountMissing(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"full_name":"lodash/lodash","watchers_count":50}`)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	_, err := gh.GetWatcherCount(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.Error(t, err)
+	assert.Equal(t, errors.CodeInvalidResponse, err.Code)
+}
@@ -1,36 +1,83 @@
 package github
 
 import (
+	"context"
+	"crypto/rsa"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ksheedlo/ghviz/errors"
 	"github.com/ksheedlo/ghviz/interfaces"
+
+	"github.com/jonboulle/clockwork"
 )
 
 var LINK_NEXT_REGEX *regexp.Regexp = regexp.MustCompile("<([^>]+)>; rel=\"next\"")
+var LINK_LAST_REGEX *regexp.Regexp = regexp.MustCompile("<([^>]+)>; rel=\"last\"")
+
+const stargazersPerPage int = 100
 
 type Client struct {
-	baseUrl      string
-	httpClient   *http.Client
-	maxStaleness int
-	redisClient  interfaces.Rediser
-	token        string
+	app              *appAuth
+	baseUrl          string
+	breaker          circuitBreaker
+	breakerCoolOff   time.Duration
+	breakerThreshold int
+	cacheCodec       string
+	clock            clockwork.Clock
+	fetchLockTimeout time.Duration
+	flights          flightGroup
+	httpClient       *http.Client
+	maxRateLimitWait time.Duration
+	maxRetries       int
+	maxStaleAge      time.Duration
+	maxStaleness     int
+	maxStatsPolls    int
+	overallTimeout   time.Duration
+	pageConcurrency  int
+	rateLimit        rateLimitState
+	rateLimitReserve int
+	redisClient      interfaces.Rediser
+	retryBaseDelay   time.Duration
+	revalidating     revalidationState
+	token            string
 }
 
 type Options struct {
-	BaseUrl      string
-	MaxStaleness int
-	RedisClient  interfaces.Rediser
-	Token        string
+	AppId            int64
+	AppPrivateKey    *rsa.PrivateKey
+	BaseUrl          string
+	BreakerCoolOff   time.Duration
+	BreakerThreshold int
+	CacheCodec       string
+	Clock            clockwork.Clock
+	FetchLockTimeout time.Duration
+	GraphQLUrl       string
+	InstallationId   int64
+	MaxRateLimitWait time.Duration
+	MaxRetries       int
+	MaxStaleAge      time.Duration
+	MaxStaleness     int
+	MaxStatsPolls    int
+	OverallTimeout   time.Duration
+	PageConcurrency  int
+	RateLimitReserve int
+	RedisClient      interfaces.Rediser
+	RequestTimeout   time.Duration
+	RetryBaseDelay   time.Duration
+	Token            string
 }
 
 type StarEvent struct {
@@ -43,32 +90,140 @@ func (a byStarredAt) Len() int           { return len(a) }
 func (a byStarredAt) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a byStarredAt) Less(i, j int) bool { return a[i].StarredAt.Before(a[j].StarredAt) }
 
+type Label struct {
+	Color string
+	Name  string
+}
+
+func (label *Label) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"color": label.Color,
+		"name":  label.Name,
+	})
+}
+
 type Issue struct {
+	Assignees []string
 	ClosedAt  time.Time
+	Comments  int
 	CreatedAt time.Time
 	EventsUrl string
 	HtmlUrl   string
 	IsClosed  bool
 	IsPr      bool
+	Labels    []Label
+	Milestone string
 	Number    int
 	Submitter string
 	Title     string
 }
 
 func (issue *Issue) MarshalJSON() ([]byte, error) {
+	assignees := issue.Assignees
+	if assignees == nil {
+		assignees = []string{}
+	}
+	labels := issue.Labels
+	if labels == nil {
+		labels = []Label{}
+	}
 	return json.Marshal(map[string]interface{}{
+		"assignees":  assignees,
 		"closed_at":  issue.ClosedAt,
+		"comments":   issue.Comments,
 		"created_at": issue.CreatedAt,
 		"events_url": issue.EventsUrl,
 		"html_url":   issue.HtmlUrl,
 		"is_closed":  issue.IsClosed,
 		"is_pr":      issue.IsPr,
+		"labels":     labels,
+		"milestone":  issue.Milestone,
 		"number":     issue.Number,
 		"submitter":  issue.Submitter,
 		"title":      issue.Title,
 	})
 }
 
+// IssueFilter narrows a list of issues down to those with a label, an
+// assignee or a milestone. Empty fields match every issue.
+type IssueFilter struct {
+	Assignee  string
+	Label     string
+	Milestone string
+}
+
+func (filter IssueFilter) IsEmpty() bool {
+	return filter.Assignee == "" && filter.Label == "" && filter.Milestone == ""
+}
+
+func (filter IssueFilter) Matches(issue *Issue) bool {
+	if filter.Milestone != "" && filter.Milestone != issue.Milestone {
+		return false
+	}
+	if filter.Label != "" {
+		hasLabel := false
+		for _, label := range issue.Labels {
+			// Github treats label names as case insensitive.
+			hasLabel = hasLabel || strings.EqualFold(label.Name, filter.Label)
+		}
+		if !hasLabel {
+			return false
+		}
+	}
+	if filter.Assignee != "" {
+		hasAssignee := false
+		for _, assignee := range issue.Assignees {
+			hasAssignee = hasAssignee || strings.EqualFold(assignee, filter.Assignee)
+		}
+		if !hasAssignee {
+			return false
+		}
+	}
+	return true
+}
+
+func (filter IssueFilter) cacheKeySuffix() string {
+	if filter.IsEmpty() {
+		return ""
+	}
+	return fmt.Sprintf(
+		":label=%s:assignee=%s:milestone=%s",
+		url.QueryEscape(filter.Label),
+		url.QueryEscape(filter.Assignee),
+		url.QueryEscape(filter.Milestone),
+	)
+}
+
+// issuesQuery builds the /issues query parameters that have Github apply the
+// label and assignee filters. Github only filters milestones by number, so
+// the milestone, which we filter by title, is left to matchesRawMilestone.
+func (filter IssueFilter) issuesQuery() string {
+	query := url.Values{}
+	if filter.Label != "" {
+		query.Set("labels", filter.Label)
+	}
+	if filter.Assignee != "" {
+		query.Set("assignee", filter.Assignee)
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "&" + query.Encode()
+}
+
+func FilterIssues(issues []Issue, filter IssueFilter) []Issue {
+	if filter.IsEmpty() {
+		return issues
+	}
+	filtered := make([]Issue, 0)
+	for i := range issues {
+		if filter.Matches(&issues[i]) {
+			filtered = append(filtered, issues[i])
+		}
+	}
+	return filtered
+}
+
 type DetailedIssueEventType int
 
 const (
@@ -106,72 +261,490 @@ func withDefaultBaseUrl(baseUrl string) string {
 	if baseUrl == "" {
 		return "https://api.github.com"
 	}
-	return baseUrl
+	return strings.TrimSuffix(baseUrl, "/")
 }
 
 func NewClient(options *Options) *Client {
-	httpClient := &http.Client{}
+	httpClient := &http.Client{Timeout: options.RequestTimeout}
 	client := &Client{}
 	client.httpClient = httpClient
+	if options.AppPrivateKey != nil {
+		client.app = &appAuth{
+			appId:          options.AppId,
+			installationId: options.InstallationId,
+			privateKey:     options.AppPrivateKey,
+		}
+	}
 	client.baseUrl = withDefaultBaseUrl(options.BaseUrl)
+	client.breakerCoolOff = options.BreakerCoolOff
+	client.breakerThreshold = options.BreakerThreshold
+	client.cacheCodec = options.CacheCodec
+	if !IsCacheCodec(client.cacheCodec) {
+		client.cacheCodec = codecGzip
+	}
+	client.clock = options.Clock
+	if client.clock == nil {
+		client.clock = clockwork.NewRealClock()
+	}
+	client.fetchLockTimeout = options.FetchLockTimeout
+	if client.fetchLockTimeout <= 0 {
+		client.fetchLockTimeout = defaultFetchLockTimeout
+	}
+	client.maxRateLimitWait = options.MaxRateLimitWait
+	client.maxRetries = options.MaxRetries
+	client.maxStaleAge = options.MaxStaleAge
 	client.maxStaleness = options.MaxStaleness
+	client.maxStatsPolls = options.MaxStatsPolls
+	if client.maxStatsPolls <= 0 {
+		client.maxStatsPolls = defaultMaxStatsPolls
+	}
+	client.overallTimeout = options.OverallTimeout
+	client.pageConcurrency = options.PageConcurrency
+	if client.pageConcurrency <= 0 {
+		client.pageConcurrency = 4
+	}
+	client.rateLimitReserve = options.RateLimitReserve
 	client.redisClient = options.RedisClient
+	client.retryBaseDelay = options.RetryBaseDelay
+	if client.retryBaseDelay <= 0 {
+		client.retryBaseDelay = defaultRetryBaseDelay
+	}
 	client.token = options.Token
 	return client
 }
 
-func (gh *Client) sendGithubRequest(logger *log.Logger, url, mediaType string) (*http.Response, *errors.HttpError) {
+// withDeadline bounds a call to one of the public methods by the configured
+// overall timeout, on top of any deadline the caller's context already has.
+func (gh *Client) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if gh.overallTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, gh.overallTimeout)
+}
+
+// requestError maps a failed round trip to the error we report downstream,
+// telling timeouts and cancellations apart from other upstream failures.
+func requestError(ctx context.Context, err error) *errors.HttpError {
+	if ctx.Err() == context.Canceled {
+		return &errors.HttpError{
+			Cause:   err,
+			Code:    errors.CodeCanceled,
+			Message: "Request Canceled",
+			Status:  http.StatusServiceUnavailable,
+		}
+	}
+	if netErr, ok := err.(net.Error); ctx.Err() == context.DeadlineExceeded || (ok && netErr.Timeout()) {
+		return &errors.HttpError{
+			Cause:   err,
+			Code:    errors.CodeTimeout,
+			Message: "Github Request Timed Out",
+			Status:  http.StatusGatewayTimeout,
+		}
+	}
+	return &errors.HttpError{
+		Cause:   err,
+		Code:    errors.CodeUpstream,
+		Message: "Github Upstream Error",
+		Status:  http.StatusBadGateway,
+	}
+}
+
+// upstreamError maps an unsuccessful response from Github to the error we
+// report downstream. Bad credentials are a problem with our configuration
+// rather than the caller's request, so a 401 becomes a 502, while the other
+// client errors keep their meaning.
+func upstreamError(logger *log.Logger, resp *http.Response, contents []byte) *errors.HttpError {
+	var body struct {
+		Message string `json:"message"`
+	}
+	// Suppress JSON decoding errors. The message only adds detail to the
+	// cause, and Github does not always send one.
+	json.Unmarshal(contents, &body)
+	cause := fmt.Errorf("Github responded with status %d: %s", resp.StatusCode, body.Message)
+	logger.Printf("ERROR: %s\n", cause.Error())
+	httpErr := &errors.HttpError{Cause: cause, UpstreamStatus: resp.StatusCode}
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		httpErr.Code = errors.CodeNotFound
+		httpErr.Message = "Repository Not Found"
+		httpErr.Status = http.StatusNotFound
+	case http.StatusUnauthorized:
+		httpErr.Code = errors.CodeUnauthorized
+		httpErr.Message = "Github Rejected The Configured Credentials"
+		httpErr.Status = http.StatusBadGateway
+	case http.StatusForbidden:
+		httpErr.Code = errors.CodeForbidden
+		httpErr.Message = "Github Denied Access To The Repository"
+		httpErr.Status = http.StatusForbidden
+	case http.StatusUnprocessableEntity:
+		httpErr.Code = errors.CodeBadRequest
+		httpErr.Message = "Github Rejected The Request"
+		httpErr.Status = http.StatusBadRequest
+	default:
+		httpErr.Code = errors.CodeUpstream
+		httpErr.Message = "Github Upstream Error"
+		httpErr.Status = http.StatusBadGateway
+	}
+	return httpErr
+}
+
+func isSuccess(resp *http.Response) bool {
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+func (gh *Client) roundTrip(ctx context.Context, logger *log.Logger, rr *http.Request) (*http.Response, *errors.HttpError) {
+	authorization, httpErr := gh.authorization(ctx, logger)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	rr.Header.Add("Authorization", authorization)
+	startTime := time.Now()
+	resp, err := gh.httpClient.Do(rr)
+	logger.Printf("send %s %s %s\n", rr.Method, rr.URL.String(), time.Since(startTime).String())
+	if err != nil {
+		logger.Printf("ERROR: %s\n", err.Error())
+		return nil, requestError(ctx, err)
+	}
+	if rateLimit, ok := parseRateLimitHeaders(resp.Header); ok {
+		gh.recordRateLimit(rateLimit)
+	}
+	if httpErr := gh.checkRateLimitResponse(logger, resp); httpErr != nil {
+		resp.Body.Close()
+		return nil, httpErr
+	}
+	return resp, nil
+}
+
+func (gh *Client) doGithubRequest(
+	ctx context.Context,
+	logger *log.Logger,
+	url, mediaType string,
+	header http.Header,
+) (*http.Response, *errors.HttpError) {
 	// Suppress errors from http.NewRequest. This can only error if the URL or
 	// HTTP method are invalid, and we control both in this module.
 	rr, _ := http.NewRequest("GET", url, nil)
-	rr.Header.Add("Authorization", fmt.Sprintf("token %s", gh.token))
+	rr = rr.WithContext(ctx)
+	for key, values := range header {
+		for _, value := range values {
+			rr.Header.Add(key, value)
+		}
+	}
 	rr.Header.Add("Accept", mediaType)
-	startTime := time.Now()
-	resp, err := gh.httpClient.Do(rr)
-	logger.Printf("send GET %s %s\n", url, time.Since(startTime).String())
+	return gh.roundTrip(ctx, logger, rr)
+}
+
+type cachedPage struct {
+	Body         string `json:"body"`
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified"`
+	Link         string `json:"link"`
+}
+
+// defaultPageCacheTTL is how long pages are cached for when the item caches
+// never go stale.
+const defaultPageCacheTTL time.Duration = time.Hour
+
+func pageKey(url string) string {
+	return fmt.Sprintf("github:page:%s", url)
+}
+
+// pageCacheTTL keeps a cached page around long enough to revalidate the items
+// built from it once they go stale, but not forever.
+func (gh *Client) pageCacheTTL() time.Duration {
+	ttl := 2*time.Duration(gh.maxStaleness)*time.Minute + gh.maxStaleAge
+	if ttl <= 0 {
+		return defaultPageCacheTTL
+	}
+	return ttl
+}
+
+// isVolatilePage reports whether a URL has a since= parameter, which changes on
+// every sync, so its page would never be requested again.
+func isVolatilePage(rawurl string) bool {
+	urlObj, err := url.Parse(rawurl)
+	if err != nil {
+		return false
+	}
+	return urlObj.Query().Get("since") != ""
+}
+
+func (gh *Client) loadCachedPage(ctx context.Context, logger *log.Logger, url string) *cachedPage {
+	if gh.redisClient == nil || isVolatilePage(url) {
+		return nil
+	}
+	value, err := gh.redisClient.Get(ctx, pageKey(url))
+	if err != nil || value == "" {
+		return nil
+	}
+	pageJson := []byte(value)
+	if isCacheEnvelope(value) {
+		_, pageJson, err = decodeCacheEnvelope(pageKey(url), value)
+		if err != nil {
+			logger.Printf("Cached page for %s could not be decoded: %s\n", url, err.Error())
+			return nil
+		}
+	}
+	page := &cachedPage{}
+	if err := json.Unmarshal(pageJson, page); err != nil {
+		logger.Printf("Cached page for %s could not be decoded: %s\n", url, err.Error())
+		return nil
+	}
+	return page
+}
+
+func (gh *Client) storeCachedPage(ctx context.Context, logger *log.Logger, url string, page *cachedPage) {
+	if gh.redisClient == nil || isVolatilePage(url) {
+		return
+	}
+	// Suppress JSON marshaling errors because we know we can always
+	// marshal `cachedPage`s.
+	jsonBlob, _ := json.Marshal(page)
+	value, err := encodeCacheEnvelope(gh.cacheCodec, gh.clock.Now(), page.ETag, jsonBlob)
+	if err != nil {
+		logger.Printf("Cache encoding error occurred: %s\n", err.Error())
+		return
+	}
+	if err := gh.redisClient.Set(ctx, pageKey(url), value, gh.pageCacheTTL()); err != nil {
+		logger.Printf("Redis store error occurred: %s\n", err.Error())
+	}
+}
+
+// fetchGithubPage downloads a single page, revalidating it against the cached
+// ETag and Last-Modified values when we have them. Github does not count 304
+// responses against the rate limit, so refreshing unchanged pages is cheap.
+// It returns the page body and the value of its Link header.
+func (gh *Client) fetchGithubPage(
+	ctx context.Context,
+	logger *log.Logger,
+	url, mediaType string,
+) ([]byte, string, *errors.HttpError) {
+	cached := gh.loadCachedPage(ctx, logger, url)
+	header := make(http.Header)
+	if cached != nil {
+		if cached.ETag != "" {
+			header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+	resp, httpErr := gh.sendGithubRequest(ctx, logger, url, mediaType, header)
+	if httpErr != nil {
+		return nil, "", httpErr
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		logger.Printf("Page %s was not modified, using the cached copy.\n", url)
+		return []byte(cached.Body), cached.Link, nil
+	}
+	contents, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		logger.Printf("ERROR: %s\n", err.Error())
-		return nil, &errors.HttpError{Message: "Github Upstream Error", Status: http.StatusBadGateway}
+		return nil, "", &errors.HttpError{Message: "Server Error", Status: http.StatusInternalServerError}
 	}
-	return resp, nil
+	if !isSuccess(resp) {
+		return nil, "", upstreamError(logger, resp, contents)
+	}
+	link := resp.Header.Get("Link")
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	if resp.StatusCode == http.StatusOK && (etag != "" || lastModified != "") {
+		gh.storeCachedPage(ctx, logger, url, &cachedPage{
+			Body:         string(contents),
+			ETag:         etag,
+			LastModified: lastModified,
+			Link:         link,
+		})
+	}
+	return contents, link, nil
+}
+
+func nextPageUrl(link string) string {
+	match := LINK_NEXT_REGEX.FindStringSubmatch(link)
+	if match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+func pageNumber(rawurl string) int {
+	urlObj, err := url.Parse(rawurl)
+	if err != nil {
+		return 0
+	}
+	page, err := strconv.Atoi(urlObj.Query().Get("page"))
+	if err != nil {
+		return 0
+	}
+	return page
+}
+
+func lastPageNumber(link string) int {
+	match := LINK_LAST_REGEX.FindStringSubmatch(link)
+	if match == nil {
+		return 0
+	}
+	return pageNumber(match[1])
+}
+
+func withPage(rawurl string, page int) string {
+	// Suppress errors from url.Parse. We only build page URLs from URLs we
+	// have already requested successfully.
+	urlObj, _ := url.Parse(rawurl)
+	query := urlObj.Query()
+	query.Set("page", strconv.Itoa(page))
+	urlObj.RawQuery = query.Encode()
+	return urlObj.String()
+}
+
+func decodeGithubPage(contents []byte) ([]map[string]interface{}, *errors.HttpError) {
+	var items []map[string]interface{}
+	if err := json.Unmarshal(contents, &items); err != nil {
+		return nil, &errors.HttpError{
+			Message: "Github API Error",
+			Status:  http.StatusBadGateway,
+		}
+	}
+	return items, nil
+}
+
+// ForEach calls handle with each index from 0 to n-1, running at most
+// concurrency calls at a time, and at least one. Once a call fails or ctx is
+// done, no further calls are made.
+func ForEach(
+	ctx context.Context,
+	n, concurrency int,
+	handle func(int) *errors.HttpError,
+) *errors.HttpError {
+	if concurrency > n {
+		concurrency = n
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	handleErrs := make([]*errors.HttpError, n)
+	var failed int32
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for worker := 0; worker < concurrency; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if atomic.LoadInt32(&failed) != 0 || ctx.Err() != nil {
+					continue
+				}
+				if httpErr := handle(i); httpErr != nil {
+					handleErrs[i] = httpErr
+					atomic.StoreInt32(&failed, 1)
+				}
+			}
+		}()
+	}
+dispatch:
+	for i := 0; i < n; i++ {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, httpErr := range handleErrs {
+		if httpErr != nil {
+			return httpErr
+		}
+	}
+	if ctx.Err() != nil {
+		return requestError(ctx, ctx.Err())
+	}
+	return nil
 }
 
-func (gh *Client) sendGithubV3Request(logger *log.Logger, url string) (*http.Response, *errors.HttpError) {
-	return gh.sendGithubRequest(logger, url, "application/vnd.github.v3+json")
+// fetchEach downloads the given URLs concurrently, using at most
+// pageConcurrency requests at a time, and hands each response body to handle
+// along with the index of its URL. Once a request fails, no further requests
+// are sent.
+func (gh *Client) fetchEach(
+	ctx context.Context,
+	logger *log.Logger,
+	urls []string,
+	mediaType string,
+	handle func(int, []byte) *errors.HttpError,
+) *errors.HttpError {
+	return ForEach(ctx, len(urls), gh.pageConcurrency, func(i int) *errors.HttpError {
+		contents, _, httpErr := gh.fetchGithubPage(ctx, logger, urls[i], mediaType)
+		if httpErr != nil {
+			return httpErr
+		}
+		return handle(i, contents)
+	})
+}
+
+// fetchPages downloads and decodes the given pages concurrently. The pages
+// are returned in the same order as their URLs.
+func (gh *Client) fetchPages(
+	ctx context.Context,
+	logger *log.Logger,
+	urls []string,
+	mediaType string,
+) ([][]map[string]interface{}, *errors.HttpError) {
+	pages := make([][]map[string]interface{}, len(urls))
+	httpErr := gh.fetchEach(ctx, logger, urls, mediaType, func(i int, contents []byte) *errors.HttpError {
+		var httpErr *errors.HttpError
+		pages[i], httpErr = decodeGithubPage(contents)
+		return httpErr
+	})
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return pages, nil
+}
+
+// pageRange builds the URLs for pages first through last, using pageUrl as
+// the template for the other query parameters.
+func pageRange(pageUrl string, first, last int) []string {
+	var urls []string
+	for page := first; page <= last; page++ {
+		urls = append(urls, withPage(pageUrl, page))
+	}
+	return urls
 }
 
 func (gh *Client) paginateGithub(
+	ctx context.Context,
 	logger *log.Logger,
 	urll, mediaType string,
 ) ([]map[string]interface{}, *errors.HttpError) {
-	items := make([]map[string]interface{}, 0)
 	allItems := make([]map[string]interface{}, 0)
 
 	for url := urll; url != ""; {
-		resp, httpErr := gh.sendGithubRequest(logger, url, mediaType)
+		contents, link, httpErr := gh.fetchGithubPage(ctx, logger, url, mediaType)
 		if httpErr != nil {
 			return nil, httpErr
 		}
-		defer resp.Body.Close()
-		contents, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, &errors.HttpError{Message: "Server Error", Status: http.StatusInternalServerError}
-		}
-		if err := json.Unmarshal(contents, &items); err != nil {
-			return nil, &errors.HttpError{
-				Message: "Github API Error",
-				Status:  http.StatusBadGateway,
-			}
+		items, httpErr := decodeGithubPage(contents)
+		if httpErr != nil {
+			return nil, httpErr
 		}
 		allItems = append(allItems, items...)
-		for i := 0; i < len(items); i++ {
-			items[i] = nil
-		}
-		match := LINK_NEXT_REGEX.FindStringSubmatch(resp.Header.Get("Link"))
-		if match != nil {
-			url = match[1]
-		} else {
-			url = ""
+		url = nextPageUrl(link)
+
+		// When Github tells us where the last page is, the remaining page URLs
+		// are predictable, so fetch them all at once.
+		nextPage := pageNumber(url)
+		if lastPage := lastPageNumber(link); nextPage > 0 && lastPage >= nextPage {
+			pages, httpErr := gh.fetchPages(ctx, logger, pageRange(url, nextPage, lastPage), mediaType)
+			if httpErr != nil {
+				return nil, httpErr
+			}
+			for _, page := range pages {
+				allItems = append(allItems, page...)
+			}
+			break
 		}
 	}
 
@@ -182,33 +755,42 @@ func stargazersKey(owner, repo string) string {
 	return fmt.Sprintf("github:repo:%s:%s:stargazers", owner, repo)
 }
 
-func parseRedisValues(cacheKey, cachedValues string) (time.Time, []byte, error) {
-	idx := strings.Index(cachedValues, "|")
-	if idx == -1 {
-		return time.Unix(0, 0), nil, fmt.Errorf(
-			"Redis query for key %s returned a malformatted value", cacheKey)
-	}
-	unixSeconds, err := strconv.ParseInt(cachedValues[:idx], 10, 64)
-	if err != nil {
-		return time.Unix(0, 0), nil, err
-	}
-	jsonBytes := []byte(cachedValues[(idx + 1):])
-	return time.Unix(unixSeconds, 0), jsonBytes, nil
-}
-
 func isStale(gh *Client, timeSubmitted time.Time) bool {
-	return time.Since(timeSubmitted) > time.Duration(gh.maxStaleness)*time.Minute
+	return gh.clock.Now().Sub(timeSubmitted) > time.Duration(gh.maxStaleness)*time.Minute
 }
 
+// redisWrap serves items from the cache while they are fresh and otherwise
+// calls fallback to fetch them from Github. When the cache holds a stale copy
+// of the items, it is passed to fallback so it can be updated incrementally.
+// Stale copies younger than the configured maximum stale age are served right
+// away instead, and refreshed in the background.
 func redisWrap(
+	ctx context.Context,
 	gh *Client,
 	cacheKey string,
 	pluralType string,
 	logger *log.Logger,
-	fallback func() ([]map[string]interface{}, *errors.HttpError),
+	fallback func(context.Context, []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError),
 ) ([]map[string]interface{}, *errors.HttpError) {
+	return redisWrapOnStored(ctx, gh, cacheKey, pluralType, logger, fallback, nil)
+}
+
+// redisWrapOnStored is redisWrap, but also calls onStored once the items
+// fallback fetched have been stored, for bookkeeping that is only safe to do
+// once they are cached.
+func redisWrapOnStored(
+	ctx context.Context,
+	gh *Client,
+	cacheKey string,
+	pluralType string,
+	logger *log.Logger,
+	fallback func(context.Context, []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError),
+	onStored func(context.Context),
+) ([]map[string]interface{}, *errors.HttpError) {
+	var staleItems []map[string]interface{}
+	var staleSince time.Time
 	if gh.redisClient != nil {
-		cachedItems, err := gh.redisClient.Get(cacheKey)
+		cachedItems, err := gh.redisClient.Get(ctx, cacheKey)
 		if err != nil || cachedItems == "" {
 			logger.Printf(
 				"Key %s was not found in Redis, attempting to fetch %s from Github.\n",
@@ -222,11 +804,6 @@ func redisWrap(
 					"Failed to parse Redis values because of an error: %s, attempting to fetch from Github.\n",
 					err.Error(),
 				)
-			} else if isStale(gh, timeSubmitted) {
-				logger.Printf(
-					"Key %s was found stale, attempting to fetch from Github.\n",
-					cacheKey,
-				)
 			} else {
 				var items []map[string]interface{}
 				err := json.Unmarshal(jsonBytes, &items)
@@ -236,6 +813,22 @@ func redisWrap(
 						cacheKey,
 						err.Error(),
 					)
+				} else if isStale(gh, timeSubmitted) && canServeStale(gh, timeSubmitted) {
+					logger.Printf(
+						"Key %s was found stale, serving it while refreshing %s from Github.\n",
+						cacheKey,
+						pluralType,
+					)
+					markStale(ctx, timeSubmitted)
+					gh.revalidate(ctx, cacheKey, logger, items, fallback, onStored)
+					return items, nil
+				} else if isStale(gh, timeSubmitted) {
+					logger.Printf(
+						"Key %s was found stale, attempting to fetch from Github.\n",
+						cacheKey,
+					)
+					staleItems = items
+					staleSince = timeSubmitted
 				} else {
 					logger.Printf("Found %s in Redis.\n", cacheKey)
 					return items, nil
@@ -244,69 +837,172 @@ func redisWrap(
 		}
 	}
 
-	items, err := fallback()
+	items, err := fetchShared(ctx, gh, cacheKey, logger, staleItems, fallback, onStored)
+	if err != nil && staleItems != nil && gh.isCircuitOpen() {
+		logger.Printf("Github is unavailable, serving stale %s from %s.\n", pluralType, cacheKey)
+		markStale(ctx, staleSince)
+		return staleItems, nil
+	}
 	if err != nil {
 		return nil, err
 	}
+	return items, nil
+}
 
+// storeItems caches the items and reports whether they were stored.
+func storeItems(
+	ctx context.Context,
+	gh *Client,
+	cacheKey string,
+	logger *log.Logger,
+	items []map[string]interface{},
+) bool {
 	if gh.redisClient == nil {
-		return items, nil
+		return false
 	}
 	jsonBlob, jsonErr := json.Marshal(items)
 	if jsonErr != nil {
 		logger.Printf("JSON encoding error occurred: %s\n", jsonErr.Error())
-		return items, nil
+		return false
 	}
-	if redisErr := gh.redisClient.Set(
-		cacheKey,
-		fmt.Sprintf("%d|%s", time.Now().Unix(), string(jsonBlob)),
-		time.Duration(0),
-	); redisErr != nil {
+	value, envelopeErr := encodeCacheEnvelope(gh.cacheCodec, gh.clock.Now(), "", jsonBlob)
+	if envelopeErr != nil {
+		logger.Printf("Cache encoding error occurred: %s\n", envelopeErr.Error())
+		return false
+	}
+	if redisErr := gh.redisClient.Set(ctx, cacheKey, value, time.Duration(0)); redisErr != nil {
 		logger.Printf("Redis store error occurred: %s\n", redisErr.Error())
+		return false
 	}
-	return items, nil
+	return true
 }
 
 type ListStarEventser interface {
-	ListStarEvents(*log.Logger, string, string) ([]StarEvent, *errors.HttpError)
+	ListStarEvents(context.Context, *log.Logger, string, string) ([]StarEvent, *errors.HttpError)
+}
+
+func cleanStargazerJsons(stargazers []map[string]interface{}) {
+	for _, stargazer := range stargazers {
+		for key, _ := range stargazer {
+			if key != "starred_at" {
+				delete(stargazer, key)
+			}
+		}
+	}
+}
+
+// syncStargazers extends a stale list of stargazers with the ones added since
+// it was cached. Github lists stargazers in ascending order, so new stargazers
+// are on the page holding the cached count and on the pages after it, up to
+// the page marked rel="last". It returns nil if the cached list no longer
+// lines up with Github's, e.g. because someone unstarred the repo.
+func (gh *Client) syncStargazers(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+	cached []map[string]interface{},
+) ([]map[string]interface{}, *errors.HttpError) {
+	// Start from the page holding the last cached stargazer, even when it is a
+	// full page, so we can check that the cache still lines up with Github.
+	startPage := (len(cached)-1)/stargazersPerPage + 1
+	offset := len(cached) - (startPage-1)*stargazersPerPage
+	url := fmt.Sprintf(
+		"%s/repos/%s/%s/stargazers?per_page=%d&page=%d",
+		gh.baseUrl,
+		owner,
+		repo,
+		stargazersPerPage,
+		startPage,
+	)
+	contents, link, httpErr := gh.fetchGithubPage(ctx, logger, url, "application/vnd.github.v3.star+json")
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	items, httpErr := decodeGithubPage(contents)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	if len(items) < offset || items[offset-1]["starred_at"] != cached[len(cached)-1]["starred_at"] {
+		logger.Printf("Cached stargazers for %s/%s are out of sync with Github.\n", owner, repo)
+		return nil, nil
+	}
+	stargazers := make([]map[string]interface{}, len(cached), len(cached)+len(items)-offset)
+	copy(stargazers, cached)
+	stargazers = append(stargazers, items[offset:]...)
+
+	pages, httpErr := gh.fetchPages(
+		ctx,
+		logger,
+		pageRange(url, startPage+1, lastPageNumber(link)),
+		"application/vnd.github.v3.star+json",
+	)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	for _, page := range pages {
+		stargazers = append(stargazers, page...)
+	}
+	cleanStargazerJsons(stargazers[len(cached):])
+	logger.Printf(
+		"Synced %d new stargazers for %s/%s.\n",
+		len(stargazers)-len(cached),
+		owner,
+		repo,
+	)
+	return stargazers, nil
 }
 
-func (gh *Client) ListStarEvents(logger *log.Logger, owner, repo string) ([]StarEvent, *errors.HttpError) {
+func (gh *Client) ListStarEvents(ctx context.Context, logger *log.Logger, owner, repo string) ([]StarEvent, *errors.HttpError) {
+	ctx, cancel := gh.withDeadline(ctx)
+	defer cancel()
 	untypedStargazers, httpErr := redisWrap(
+		ctx,
 		gh,
 		stargazersKey(owner, repo),
 		"stargazers",
 		logger,
-		func() ([]map[string]interface{}, *errors.HttpError) {
+		func(ctx context.Context, cached []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			if len(cached) > 0 {
+				stargazers, err := gh.syncStargazers(ctx, logger, owner, repo, cached)
+				if err != nil {
+					return nil, err
+				}
+				if stargazers != nil {
+					return stargazers, nil
+				}
+			}
 			stargazers, err := gh.paginateGithub(
+				ctx,
 				logger,
-				fmt.Sprintf("%s/repos/%s/%s/stargazers?per_page=100", gh.baseUrl, owner, repo),
+				fmt.Sprintf(
+					"%s/repos/%s/%s/stargazers?per_page=%d",
+					gh.baseUrl,
+					owner,
+					repo,
+					stargazersPerPage,
+				),
 				"application/vnd.github.v3.star+json",
 			)
 			if err != nil {
 				return nil, err
 			}
-			for _, stargazer := range stargazers {
-				for key, _ := range stargazer {
-					if key != "starred_at" {
-						delete(stargazer, key)
-					}
-				}
-			}
+			cleanStargazerJsons(stargazers)
 			return stargazers, nil
 		},
 	)
 	if httpErr != nil {
 		return nil, httpErr
 	}
+	return parseStarEvents(logger, untypedStargazers)
+}
+
+func parseStarEvents(logger *log.Logger, untypedStargazers []map[string]interface{}) ([]StarEvent, *errors.HttpError) {
 	starEvents := make([]StarEvent, len(untypedStargazers))
 	for i, stargazer := range untypedStargazers {
-		starredAt, err := time.Parse(time.RFC3339, stargazer["starred_at"].(string))
-		if err != nil {
-			return nil, &errors.HttpError{
-				Message: "Server Error",
-				Status:  http.StatusInternalServerError,
-			}
+		rawStarredAt, _ := stargazer["starred_at"].(string)
+		starredAt, httpErr := parseTimestamp(logger, "starred_at", rawStarredAt)
+		if httpErr != nil {
+			return nil, httpErr
 		}
 		starEvents[i].StarredAt = starredAt
 	}
@@ -314,25 +1010,54 @@ func (gh *Client) ListStarEvents(logger *log.Logger, owner, repo string) ([]Star
 	return starEvents, nil
 }
 
+// keepKeys deletes every key from a JSON object except the given ones. It
+// ignores values that are not objects, like a null user.
+func keepKeys(value interface{}, keys ...string) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for key, _ := range object {
+		keep := false
+		for _, keepKey := range keys {
+			keep = keep || key == keepKey
+		}
+		if !keep {
+			delete(object, key)
+		}
+	}
+}
+
 func cleanIssueJsons(issues []map[string]interface{}) {
 	for _, issue := range issues {
-		for key, _ := range issue {
-			if key != "closed_at" &&
-				key != "created_at" &&
-				key != "events_url" &&
-				key != "html_url" &&
-				key != "number" &&
-				key != "pull_request" &&
-				key != "title" &&
-				key != "user" {
-				delete(issue, key)
+		keepKeys(
+			issue,
+			"assignees",
+			"closed_at",
+			"comments",
+			"created_at",
+			"events_url",
+			"html_url",
+			"labels",
+			"milestone",
+			"number",
+			"pull_request",
+			"title",
+			"user",
+		)
+
+		// Deleted accounts come back as a null user. Leave it as it is and
+		// let parseIssue show them as the ghost.
+		keepKeys(issue["user"], "login")
+		keepKeys(issue["milestone"], "title")
+		if assignees, ok := issue["assignees"].([]interface{}); ok {
+			for _, assignee := range assignees {
+				keepKeys(assignee, "login")
 			}
 		}
-
-		userJson := issue["user"].(map[string]interface{})
-		for key, _ := range userJson {
-			if key != "login" {
-				delete(userJson, key)
+		if labels, ok := issue["labels"].([]interface{}); ok {
+			for _, label := range labels {
+				keepKeys(label, "color", "name")
 			}
 		}
 	}
@@ -343,46 +1068,23 @@ func parseIssue(
 	issue *Issue,
 	rawIssue map[string]interface{},
 ) *errors.HttpError {
-	createdAt, parseErr := time.Parse(time.RFC3339, rawIssue["created_at"].(string))
-	if parseErr != nil {
-		logger.Printf("ERROR: %s\n", parseErr.Error())
-		return &errors.HttpError{
-			Message: "Server Error", Status: http.StatusInternalServerError,
-		}
-	}
-	issue.IsClosed = false
-	var rawClosedAt interface{}
-	var hasClosedAt bool
-	if rawClosedAt, hasClosedAt = rawIssue["closed_at"]; hasClosedAt {
-		issue.IsClosed = (rawClosedAt != nil)
-	}
-	if issue.IsClosed {
-		closedAt, parseErr := time.Parse(time.RFC3339, rawClosedAt.(string))
-		if parseErr != nil {
-			logger.Printf("ERROR: %s\n", parseErr.Error())
-			return &errors.HttpError{
-				Message: "Server Error",
-				Status:  http.StatusInternalServerError,
-			}
-		}
-		issue.ClosedAt = closedAt
+	var payload issuePayload
+	if httpErr := decodePayload(logger, rawIssue, &payload); httpErr != nil {
+		return httpErr
 	}
 	_, isPr := rawIssue["pull_request"]
-
-	issue.CreatedAt = createdAt
-	issue.EventsUrl = rawIssue["events_url"].(string)
-	issue.HtmlUrl = rawIssue["html_url"].(string)
-	issue.IsPr = isPr
-	issue.Number = int(rawIssue["number"].(float64))
-	issue.Submitter = (rawIssue["user"].(map[string]interface{}))["login"].(string)
-	issue.Title = rawIssue["title"].(string)
-	return nil
+	return payload.toIssue(logger, issue, isPr)
 }
 
 func parseIssues(logger *log.Logger, rawIssues []map[string]interface{}) ([]Issue, *errors.HttpError) {
+	var payloads []issuePayload
+	if httpErr := decodePayload(logger, rawIssues, &payloads); httpErr != nil {
+		return nil, httpErr
+	}
 	issues := make([]Issue, len(rawIssues))
 	for i, rawIssue := range rawIssues {
-		if err := parseIssue(logger, &issues[i], rawIssue); err != nil {
+		_, isPr := rawIssue["pull_request"]
+		if err := payloads[i].toIssue(logger, &issues[i], isPr); err != nil {
 			return nil, err
 		}
 	}
@@ -391,32 +1093,142 @@ func parseIssues(logger *log.Logger, rawIssues []map[string]interface{}) ([]Issu
 }
 
 type ListIssueser interface {
-	ListIssues(*log.Logger, string, string) ([]Issue, *errors.HttpError)
+	ListIssues(context.Context, *log.Logger, string, string) ([]Issue, *errors.HttpError)
 }
 
-func (gh *Client) ListIssues(logger *log.Logger, owner, repo string) ([]Issue, *errors.HttpError) {
-	rawIssues, err := redisWrap(
+func issuesSyncedAtKey(owner, repo string) string {
+	return fmt.Sprintf("github:repo:%s:%s:issues_synced_at", owner, repo)
+}
+
+func (gh *Client) lastIssueSync(ctx context.Context, logger *log.Logger, owner, repo string) (time.Time, bool) {
+	if gh.redisClient == nil {
+		return time.Unix(0, 0), false
+	}
+	syncedAt, err := gh.redisClient.Get(ctx, issuesSyncedAtKey(owner, repo))
+	if err != nil || syncedAt == "" {
+		return time.Unix(0, 0), false
+	}
+	lastSync, err := time.Parse(time.RFC3339, syncedAt)
+	if err != nil {
+		logger.Printf("Failed to parse the last issue sync time %s: %s\n", syncedAt, err.Error())
+		return time.Unix(0, 0), false
+	}
+	return lastSync, true
+}
+
+func (gh *Client) recordIssueSync(ctx context.Context, logger *log.Logger, owner, repo string, syncedAt time.Time) {
+	if gh.redisClient == nil {
+		return
+	}
+	if err := gh.redisClient.Set(
+		ctx,
+		issuesSyncedAtKey(owner, repo),
+		syncedAt.UTC().Format(time.RFC3339),
+		time.Duration(0),
+	); err != nil {
+		logger.Printf("Redis store error occurred: %s\n", err.Error())
+	}
+}
+
+type byIssueNumber []map[string]interface{}
+
+func (a byIssueNumber) Len() int      { return len(a) }
+func (a byIssueNumber) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byIssueNumber) Less(i, j int) bool {
+	iNumber, _ := a[i]["number"].(float64)
+	jNumber, _ := a[j]["number"].(float64)
+	return iNumber < jNumber
+}
+
+// mergeIssueJsons replaces cached issues with their updated versions, matching
+// them by number, and adds the issues that are new since the cache was stored.
+func mergeIssueJsons(cached, updated []map[string]interface{}) []map[string]interface{} {
+	merged := make([]map[string]interface{}, len(cached), len(cached)+len(updated))
+	copy(merged, cached)
+	indexByNumber := make(map[float64]int)
+	for i, issue := range merged {
+		if number, ok := issue["number"].(float64); ok {
+			indexByNumber[number] = i
+		}
+	}
+	for _, issue := range updated {
+		number, _ := issue["number"].(float64)
+		if i, isCached := indexByNumber[number]; isCached {
+			merged[i] = issue
+		} else {
+			indexByNumber[number] = len(merged)
+			merged = append(merged, issue)
+		}
+	}
+	sort.Stable(byIssueNumber(merged))
+	return merged
+}
+
+func (gh *Client) ListIssues(ctx context.Context, logger *log.Logger, owner, repo string) ([]Issue, *errors.HttpError) {
+	ctx, cancel := gh.withDeadline(ctx)
+	defer cancel()
+	// The sync time is only recorded once the issues it covers are cached.
+	// Otherwise a failed store would make the next sync skip them.
+	var syncStartedAt time.Time
+	rawIssues, err := redisWrapOnStored(
+		ctx,
 		gh,
 		fmt.Sprintf("github:repo:%s:%s:issues", owner, repo),
 		"issues",
 		logger,
-		func() ([]map[string]interface{}, *errors.HttpError) {
-			issues, err := gh.paginateGithub(
-				logger,
-				fmt.Sprintf(
-					"%s/repos/%s/%s/issues?per_page=100&state=all&sort=created&direction=asc",
-					gh.baseUrl,
-					owner,
-					repo,
-				),
-				"application/vnd.github.v3+json",
-			)
-			if err != nil {
-				return nil, err
+		func(ctx context.Context, cached []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			syncStartedAt = gh.clock.Now()
+			lastSync, hasLastSync := time.Unix(0, 0), false
+			if len(cached) > 0 {
+				lastSync, hasLastSync = gh.lastIssueSync(ctx, logger, owner, repo)
+			}
+			var issues []map[string]interface{}
+			if hasLastSync {
+				updatedIssues, err := gh.paginateGithub(
+					ctx,
+					logger,
+					fmt.Sprintf(
+						"%s/repos/%s/%s/issues?per_page=100&state=all&sort=updated&direction=asc&since=%s",
+						gh.baseUrl,
+						owner,
+						repo,
+						lastSync.UTC().Format(time.RFC3339),
+					),
+					"application/vnd.github.v3+json",
+				)
+				if err != nil {
+					return nil, err
+				}
+				cleanIssueJsons(updatedIssues)
+				logger.Printf(
+					"Merging %d issues updated since %s into the cache.\n",
+					len(updatedIssues),
+					lastSync.UTC().Format(time.RFC3339),
+				)
+				issues = mergeIssueJsons(cached, updatedIssues)
+			} else {
+				allIssues, err := gh.paginateGithub(
+					ctx,
+					logger,
+					fmt.Sprintf(
+						"%s/repos/%s/%s/issues?per_page=100&state=all&sort=created&direction=asc",
+						gh.baseUrl,
+						owner,
+						repo,
+					),
+					"application/vnd.github.v3+json",
+				)
+				if err != nil {
+					return nil, err
+				}
+				cleanIssueJsons(allIssues)
+				issues = allIssues
 			}
-			cleanIssueJsons(issues)
 			return issues, nil
 		},
+		func(ctx context.Context) {
+			gh.recordIssueSync(ctx, logger, owner, repo, syncStartedAt)
+		},
 	)
 
 	if err != nil {
@@ -427,14 +1239,67 @@ func (gh *Client) ListIssues(logger *log.Logger, owner, repo string) ([]Issue, *
 }
 
 type ListAllPrEventser interface {
-	ListAllPrEvents(*log.Logger, string, string) ([]DetailedIssueEvent, *errors.HttpError)
+	ListAllPrEvents(context.Context, *log.Logger, string, string) ([]DetailedIssueEvent, *errors.HttpError)
+}
+
+func prCreatedEvent(issue *Issue) DetailedIssueEvent {
+	return DetailedIssueEvent{
+		ActorId:     issue.Submitter,
+		CreatedAt:   issue.CreatedAt,
+		EventType:   IssueCreated,
+		Id:          fmt.Sprintf("cr%d", issue.Number),
+		IssueNumber: issue.Number,
+	}
+}
+
+// parsePrEvent converts an event from either the repo-wide or the per-issue
+// events API. It returns nil for event types we do not score.
+func parsePrEvent(
+	logger *log.Logger,
+	issue *Issue,
+	event map[string]interface{},
+) (*DetailedIssueEvent, *errors.HttpError) {
+	var payload issueEventPayload
+	if httpErr := decodePayload(logger, event, &payload); httpErr != nil {
+		return nil, httpErr
+	}
+	eventType, eventIsKnown := issueEventTypes[payload.Event]
+	if !eventIsKnown {
+		return nil, nil
+	}
+	if payload.Id == nil {
+		return nil, schemaError(logger, fmt.Errorf("%s event on #%d is missing its id", payload.Event, issue.Number))
+	}
+	var detail interface{}
+	switch eventType {
+	case IssueClosed, IssueMerged:
+		detail = event["commit_id"]
+	case IssueLabeled, IssueUnlabeled:
+		detail = event["label"]
+	}
+	createdAt, httpErr := parseTimestamp(logger, "created_at", payload.CreatedAt)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return &DetailedIssueEvent{
+		ActorId:     payload.Actor.login(),
+		CreatedAt:   createdAt,
+		Detail:      detail,
+		EventType:   eventType,
+		Id:          fmt.Sprintf("%d", *payload.Id),
+		IssueNumber: issue.Number,
+	}, nil
 }
 
 func (gh *Client) ListAllPrEvents(
+	ctx context.Context,
 	logger *log.Logger,
 	owner, repo string,
 ) ([]DetailedIssueEvent, *errors.HttpError) {
+	ctx, cancel := gh.withDeadline(ctx)
+	defer cancel()
 	issueEvents, err := gh.paginateGithub(
+		ctx,
 		logger,
 		fmt.Sprintf("%s/repos/%s/%s/issues/events?per_page=100", gh.baseUrl, owner, repo),
 		"application/vnd.github.v3+json",
@@ -444,104 +1309,242 @@ func (gh *Client) ListAllPrEvents(
 	}
 
 	var detailedEvents []DetailedIssueEvent
+	var oldestEventAt time.Time
 	knownIssues := make(map[int]Issue)
+	seenEvents := make(map[string]bool)
 	for _, event := range issueEvents {
-		issueNumber := int((event["issue"].(map[string]interface{}))["number"].(float64))
-		issue, issueIsKnown := knownIssues[issueNumber]
+		rawCreatedAt, _ := event["created_at"].(string)
+		if createdAt, err := time.Parse(time.RFC3339, rawCreatedAt); err == nil {
+			if oldestEventAt.IsZero() || createdAt.Before(oldestEventAt) {
+				oldestEventAt = createdAt
+			}
+		}
+		rawIssue, ok := event["issue"].(map[string]interface{})
+		if !ok {
+			return nil, schemaError(logger, fmt.Errorf("issue event is missing its issue"))
+		}
+		issueNumber, _ := rawIssue["number"].(float64)
+		issue, issueIsKnown := knownIssues[int(issueNumber)]
 		if !issueIsKnown {
 			issue = Issue{}
-			parseIssue(logger, &issue, event["issue"].(map[string]interface{}))
+			if httpErr := parseIssue(logger, &issue, rawIssue); httpErr != nil {
+				return nil, httpErr
+			}
 			knownIssues[issue.Number] = issue
 			if issue.IsPr {
-				detailedEvents = append(detailedEvents, DetailedIssueEvent{
-					ActorId:     issue.Submitter,
-					CreatedAt:   issue.CreatedAt,
-					EventType:   IssueCreated,
-					Id:          fmt.Sprintf("cr%d", issue.Number),
-					IssueNumber: issue.Number,
-				})
+				detailedEvents = append(detailedEvents, prCreatedEvent(&issue))
 			}
 		}
-		if eventType, eventIsKnown := issueEventTypes[event["event"].(string)]; eventIsKnown {
-			actorId := (event["actor"].(map[string]interface{}))["login"].(string)
-			if issue.IsPr {
-				var detail interface{}
-				switch eventType {
-				case IssueClosed, IssueMerged:
-					detail = event["commit_id"]
-				case IssueLabeled, IssueUnlabeled:
-					detail = event["label"]
-				}
-				createdAt, err := time.Parse(time.RFC3339, event["created_at"].(string))
-				if err != nil {
-					logger.Printf("ERROR: %s\n", err)
-					return nil, &errors.HttpError{
-						Message: "Server Error",
-						Status:  http.StatusInternalServerError,
-					}
-				}
-				detailedEvents = append(detailedEvents, DetailedIssueEvent{
-					ActorId:     actorId,
-					CreatedAt:   createdAt,
-					Detail:      detail,
-					EventType:   eventType,
-					Id:          fmt.Sprintf("%d", int(event["id"].(float64))),
-					IssueNumber: issue.Number,
-				})
+		if !issue.IsPr {
+			continue
+		}
+		detailedEvent, err := parsePrEvent(logger, &issue, event)
+		if err != nil {
+			return nil, err
+		}
+		if detailedEvent != nil {
+			seenEvents[detailedEvent.Id] = true
+			detailedEvents = append(detailedEvents, *detailedEvent)
+		}
+	}
+
+	if len(issueEvents) < issueEventsFeedLimit {
+		return detailedEvents, nil
+	}
+	return gh.backfillPrEvents(ctx, logger, owner, repo, detailedEvents, knownIssues, seenEvents, oldestEventAt)
+}
+
+// issueEventsFeedLimit is how many events Github serves from a repo's issue
+// events feed. Once the feed holds that many, older events are cut off.
+const issueEventsFeedLimit int = 300
+
+func prBackfillsKey(owner, repo string) string {
+	return fmt.Sprintf("github:repo:%s:%s:pr_backfills", owner, repo)
+}
+
+// fetchPrBackfills downloads the events of each PR, reusing the events from
+// stale backfills. Events older than the feed never change, and newer ones
+// are in the feed, so the stale backfills are still good.
+func (gh *Client) fetchPrBackfills(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+	prNumbers []int,
+	stale []map[string]interface{},
+) ([]map[string]interface{}, *errors.HttpError) {
+	staleBackfills := make(map[int]map[string]interface{})
+	for _, backfill := range stale {
+		number, _ := backfill["number"].(float64)
+		staleBackfills[int(number)] = backfill
+	}
+	backfills := make([]map[string]interface{}, 0, len(prNumbers))
+	var missingNumbers []int
+	var urls []string
+	for _, number := range prNumbers {
+		if backfill, isBackfilled := staleBackfills[number]; isBackfilled {
+			backfills = append(backfills, backfill)
+			continue
+		}
+		missingNumbers = append(missingNumbers, number)
+		urls = append(
+			urls,
+			fmt.Sprintf("%s/repos/%s/%s/issues/%d/events?per_page=100", gh.baseUrl, owner, repo, number),
+		)
+	}
+	fetched := make([]map[string]interface{}, len(urls))
+	httpErr := gh.fetchEach(ctx, logger, urls, "application/vnd.github.v3+json", func(i int, contents []byte) *errors.HttpError {
+		prEvents, httpErr := decodeGithubPage(contents)
+		if httpErr != nil {
+			return httpErr
+		}
+		// The first page holds every event of nearly all PRs.
+		if len(prEvents) == 100 {
+			morePrEvents, httpErr := gh.paginateGithub(ctx, logger, withPage(urls[i], 2), "application/vnd.github.v3+json")
+			if httpErr != nil {
+				return httpErr
 			}
+			prEvents = append(prEvents, morePrEvents...)
+		}
+		events := make([]interface{}, len(prEvents))
+		for j, event := range prEvents {
+			events[j] = event
+		}
+		fetched[i] = map[string]interface{}{
+			"events": events,
+			"number": float64(missingNumbers[i]),
+		}
+		return nil
+	})
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return append(backfills, fetched...), nil
+}
+
+// backfillPrEvents completes the history of PRs that are older than the
+// oldest event in the repo-wide events feed. Github caps that feed, so events
+// on older PRs may be missing from it; fetch them from each PR's own events
+// instead, skipping events we have already seen.
+func (gh *Client) backfillPrEvents(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+	detailedEvents []DetailedIssueEvent,
+	knownIssues map[int]Issue,
+	seenEvents map[string]bool,
+	oldestEventAt time.Time,
+) ([]DetailedIssueEvent, *errors.HttpError) {
+	allIssues, err := gh.ListIssues(ctx, logger, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	truncatedPrs := make(map[int]Issue)
+	var prNumbers []int
+	for _, issue := range allIssues {
+		if issue.IsPr && (oldestEventAt.IsZero() || issue.CreatedAt.Before(oldestEventAt)) {
+			truncatedPrs[issue.Number] = issue
+			prNumbers = append(prNumbers, issue.Number)
 		}
 	}
+	if len(truncatedPrs) == 0 {
+		return detailedEvents, nil
+	}
+	sort.Ints(prNumbers)
+	logger.Printf(
+		"The issue events feed for %s/%s does not reach back to %d PRs, backfilling their events.\n",
+		owner,
+		repo,
+		len(truncatedPrs),
+	)
+
+	backfills, err := redisWrap(
+		ctx,
+		gh,
+		prBackfillsKey(owner, repo),
+		"PR backfills",
+		logger,
+		func(ctx context.Context, stale []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			return gh.fetchPrBackfills(ctx, logger, owner, repo, prNumbers, stale)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	backfilledEvents := make(map[int][]interface{})
+	for _, backfill := range backfills {
+		number, _ := backfill["number"].(float64)
+		events, _ := backfill["events"].([]interface{})
+		backfilledEvents[int(number)] = events
+	}
 
+	for _, number := range prNumbers {
+		pr := truncatedPrs[number]
+		if _, issueIsKnown := knownIssues[number]; !issueIsKnown {
+			knownIssues[number] = pr
+			detailedEvents = append(detailedEvents, prCreatedEvent(&pr))
+		}
+		for _, rawEvent := range backfilledEvents[number] {
+			event, ok := rawEvent.(map[string]interface{})
+			if !ok {
+				return nil, schemaError(logger, fmt.Errorf("backfilled event on #%d is not an object", number))
+			}
+			detailedEvent, err := parsePrEvent(logger, &pr, event)
+			if err != nil {
+				return nil, err
+			}
+			if detailedEvent != nil && !seenEvents[detailedEvent.Id] {
+				seenEvents[detailedEvent.Id] = true
+				detailedEvents = append(detailedEvents, *detailedEvent)
+			}
+		}
+	}
 	return detailedEvents, nil
 }
 
 func (gh *Client) filterTopIssues(
+	ctx context.Context,
 	logger *log.Logger,
 	cacheKey, pluralType, owner, repo string,
 	limit int,
+	filter IssueFilter,
 	filterFn func(map[string]interface{}) bool,
 ) ([]Issue, *errors.HttpError) {
 	rawIssues, err := redisWrap(
+		ctx,
 		gh,
 		cacheKey,
 		pluralType,
 		logger,
-		func() ([]map[string]interface{}, *errors.HttpError) {
+		func(ctx context.Context, _ []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
 			url := fmt.Sprintf(
-				"%s/repos/%s/%s/issues?per_page=100&state=open&sort=created&direction=desc",
+				"%s/repos/%s/%s/issues?per_page=100&state=open&sort=created&direction=desc%s",
 				gh.baseUrl,
 				owner,
 				repo,
+				filter.issuesQuery(),
 			)
-			items := make([]map[string]interface{}, 0)
 			allItems := make([]map[string]interface{}, 0)
 
 			for url != "" && len(allItems) < limit {
-				resp, httpErr := gh.sendGithubV3Request(logger, url)
+				contents, link, httpErr := gh.fetchGithubPage(
+					ctx,
+					logger,
+					url,
+					"application/vnd.github.v3+json",
+				)
 				if httpErr != nil {
 					return nil, httpErr
 				}
-				defer resp.Body.Close()
-				contents, err := ioutil.ReadAll(resp.Body)
-				if err != nil {
-					logger.Printf("ERROR: %s\n", err.Error())
-					return nil, &errors.HttpError{Message: "Server Error", Status: http.StatusInternalServerError}
+				var items []map[string]interface{}
+				if err := json.Unmarshal(contents, &items); err != nil {
+					return nil, schemaError(logger, err)
 				}
-				json.Unmarshal(contents, &items)
 				for i := 0; i < len(items) && len(allItems) < limit; i++ {
-					if filterFn(items[i]) {
+					if filterFn(items[i]) && matchesRawMilestone(filter, items[i]) {
 						allItems = append(allItems, items[i])
 					}
 				}
-				for i := 0; i < len(items); i++ {
-					items[i] = nil
-				}
-				match := LINK_NEXT_REGEX.FindStringSubmatch(resp.Header.Get("Link"))
-				if match != nil {
-					url = match[1]
-				} else {
-					url = ""
-				}
+				url = nextPageUrl(link)
 			}
 
 			cleanIssueJsons(allItems)
@@ -556,17 +1559,27 @@ func (gh *Client) filterTopIssues(
 }
 
 type ListTopIssueser interface {
-	ListTopIssues(*log.Logger, string, string, int) ([]Issue, *errors.HttpError)
+	ListTopIssues(context.Context, *log.Logger, string, string, int, IssueFilter) ([]Issue, *errors.HttpError)
 }
 
-func (gh *Client) ListTopIssues(logger *log.Logger, owner, repo string, limit int) ([]Issue, *errors.HttpError) {
+func (gh *Client) ListTopIssues(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+	limit int,
+	filter IssueFilter,
+) ([]Issue, *errors.HttpError) {
+	ctx, cancel := gh.withDeadline(ctx)
+	defer cancel()
 	return gh.filterTopIssues(
+		ctx,
 		logger,
-		fmt.Sprintf("github:repo:%s:%s:top_issues:%d", owner, repo, limit),
+		fmt.Sprintf("github:repo:%s:%s:top_issues:%d%s", owner, repo, limit, filter.cacheKeySuffix()),
 		"top issues",
 		owner,
 		repo,
 		limit,
+		filter,
 		func(rawIssue map[string]interface{}) bool {
 			_, isPr := rawIssue["pull_request"]
 			return !isPr
@@ -575,17 +1588,27 @@ func (gh *Client) ListTopIssues(logger *log.Logger, owner, repo string, limit in
 }
 
 type ListTopPrser interface {
-	ListTopPrs(*log.Logger, string, string, int) ([]Issue, *errors.HttpError)
+	ListTopPrs(context.Context, *log.Logger, string, string, int, IssueFilter) ([]Issue, *errors.HttpError)
 }
 
-func (gh *Client) ListTopPrs(logger *log.Logger, owner, repo string, limit int) ([]Issue, *errors.HttpError) {
+func (gh *Client) ListTopPrs(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+	limit int,
+	filter IssueFilter,
+) ([]Issue, *errors.HttpError) {
+	ctx, cancel := gh.withDeadline(ctx)
+	defer cancel()
 	return gh.filterTopIssues(
+		ctx,
 		logger,
-		fmt.Sprintf("github:repo:%s:%s:top_prs:%d", owner, repo, limit),
+		fmt.Sprintf("github:repo:%s:%s:top_prs:%d%s", owner, repo, limit, filter.cacheKeySuffix()),
 		"top PRs",
 		owner,
 		repo,
 		limit,
+		filter,
 		func(rawIssue map[string]interface{}) bool {
 			_, isPr := rawIssue["pull_request"]
 			return isPr
@@ -1,16 +1,22 @@
 package github
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/ksheedlo/ghviz/errors"
+	"github.com/ksheedlo/ghviz/interfaces"
 	"github.com/ksheedlo/ghviz/mocks"
 
+	"github.com/jonboulle/clockwork"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -44,7 +50,7 @@ func TestListStarEvents(t *testing.T) {
 		BaseUrl: ts.URL,
 		Token:   "deadbeef",
 	})
-	starEvents, err := gh.ListStarEvents(mocks.DummyLogger(t), "angular", "angular")
+	starEvents, err := gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
 	assert.NoError(t, err)
 	assert.Equal(t, len(starEvents), 3)
 	assert.True(t,
@@ -71,7 +77,7 @@ func TestListStarEventsBadJson(t *testing.T) {
 		BaseUrl: ts.URL,
 		Token:   "deadbeef",
 	})
-	_, err := gh.ListStarEvents(mocks.DummyLogger(t), "angular", "angular")
+	_, err := gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
 	assert.Error(t, err)
 }
 
@@ -89,7 +95,7 @@ func TestListStarEventsBadStarredAt(t *testing.T) {
 		BaseUrl: ts.URL,
 		Token:   "deadbeef",
 	})
-	_, err := gh.ListStarEvents(mocks.DummyLogger(t), "angular", "angular")
+	_, err := gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
 	assert.Error(t, err)
 }
 
@@ -120,7 +126,7 @@ func TestPagination(t *testing.T) {
 		BaseUrl: ts.URL,
 		Token:   "deadbeef",
 	})
-	starEvents, err := gh.ListStarEvents(mocks.DummyLogger(t), "angular", "angular")
+	starEvents, err := gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
 	assert.NoError(t, err)
 	assert.Equal(t, call, 2)
 	assert.Equal(t, len(starEvents), 6)
@@ -178,7 +184,7 @@ func TestListIssues(t *testing.T) {
 		BaseUrl: ts.URL,
 		Token:   "deadbeef",
 	})
-	allIssues, err := gh.ListIssues(mocks.DummyLogger(t), "lodash", "lodash")
+	allIssues, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
 	assert.NoError(t, err)
 	assert.Equal(t, len(allIssues), 4)
 	assert.Equal(t, allIssues[0].EventsUrl, "https://api.example.com/issues/1/events")
@@ -207,7 +213,7 @@ func TestListIssuesBadCreatedAt(t *testing.T) {
 		BaseUrl: ts.URL,
 		Token:   "deadbeef",
 	})
-	_, err := gh.ListIssues(mocks.DummyLogger(t), "lodash", "lodash")
+	_, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
 	assert.Error(t, err)
 }
 
@@ -233,10 +239,20 @@ func TestListIssuesBadClosedAt(t *testing.T) {
 		BaseUrl: ts.URL,
 		Token:   "deadbeef",
 	})
-	_, err := gh.ListIssues(mocks.DummyLogger(t), "lodash", "lodash")
+	_, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
 	assert.Error(t, err)
 }
 
+const lodashIssuesPath string = "/repos/lodash/lodash/issues?per_page=100&state=all&sort=created&direction=asc"
+
+func expectPageCacheMiss(redisMock *mocks.MockRediser, baseUrl, path string) {
+	redisMock.On("Get", pageKey(baseUrl+path)).Return("", nil)
+}
+
+func expectIssueSync(redisMock *mocks.MockRediser, owner, repo string) {
+	redisMock.On("Set", issuesSyncedAtKey(owner, repo), "", time.Duration(0)).Return(nil)
+}
+
 func TestRedisCacheHit(t *testing.T) {
 	t.Parallel()
 
@@ -258,7 +274,7 @@ func TestRedisCacheHit(t *testing.T) {
 		nil,
 	)
 
-	allIssues, err := gh.ListIssues(mocks.DummyLogger(t), "lodash", "lodash")
+	allIssues, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
 	assert.NoError(t, err)
 	assert.Equal(t, len(allIssues), 4)
 	assert.Equal(t, allIssues[0].EventsUrl, "https://api.example.com/issues/1/events")
@@ -282,12 +298,42 @@ func TestRedisCacheSet(t *testing.T) {
 	})
 
 	cacheKey := "github:repo:lodash:lodash:issues"
+	expectPageCacheMiss(redisMock, ts.URL, lodashIssuesPath)
+	expectIssueSync(redisMock, "lodash", "lodash")
 	redisMock.On("Get", cacheKey).Return("", nil)
 	redisMock.On("Set", cacheKey, "", time.Duration(0)).Return(nil)
 
-	_, err := gh.ListIssues(mocks.DummyLogger(t), "lodash", "lodash")
+	_, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	redisMock.AssertExpectations(t)
+}
+
+func TestIssueSyncNotRecordedWhenStoreFails(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, issuesJson)
+	}))
+	defer ts.Close()
+
+	redisMock := &mocks.MockRediser{}
+	gh := NewClient(&Options{
+		BaseUrl:      ts.URL,
+		MaxStaleness: 5,
+		RedisClient:  redisMock,
+		Token:        "deadbeef",
+	})
+
+	cacheKey := "github:repo:lodash:lodash:issues"
+	expectPageCacheMiss(redisMock, ts.URL, lodashIssuesPath)
+	redisMock.On("Get", cacheKey).Return("", nil)
+	redisMock.On("Set", cacheKey, "", time.Duration(0)).Return(fmt.Errorf("i/o timeout"))
+
+	allIssues, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
 	assert.NoError(t, err)
+	assert.Len(t, allIssues, 4)
 	redisMock.AssertExpectations(t)
+	redisMock.AssertNotCalled(t, "Set", issuesSyncedAtKey("lodash", "lodash"), "", time.Duration(0))
 }
 
 func TestRedisStaleCacheHit(t *testing.T) {
@@ -307,13 +353,15 @@ func TestRedisStaleCacheHit(t *testing.T) {
 	})
 
 	cacheKey := "github:repo:lodash:lodash:issues"
+	expectPageCacheMiss(redisMock, ts.URL, lodashIssuesPath)
+	expectIssueSync(redisMock, "lodash", "lodash")
 	redisMock.On("Get", cacheKey).Return(
 		fmt.Sprintf("%d|meh", time.Now().Add(time.Duration(-6)*time.Minute).Unix()),
 		nil,
 	)
 	redisMock.On("Set", cacheKey, "", time.Duration(0)).Return(nil)
 
-	allIssues, err := gh.ListIssues(mocks.DummyLogger(t), "lodash", "lodash")
+	allIssues, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
 	assert.NoError(t, err)
 	assert.Equal(t, len(allIssues), 4)
 	assert.Equal(t, allIssues[0].EventsUrl, "https://api.example.com/issues/1/events")
@@ -337,10 +385,12 @@ func TestBadRedisValues(t *testing.T) {
 	})
 
 	cacheKey := "github:repo:lodash:lodash:issues"
+	expectPageCacheMiss(redisMock, ts.URL, lodashIssuesPath)
+	expectIssueSync(redisMock, "lodash", "lodash")
 	redisMock.On("Get", cacheKey).Return("chicken", nil)
 	redisMock.On("Set", cacheKey, "", time.Duration(0)).Return(nil)
 
-	allIssues, err := gh.ListIssues(mocks.DummyLogger(t), "lodash", "lodash")
+	allIssues, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
 	assert.NoError(t, err)
 	assert.Equal(t, len(allIssues), 4)
 	assert.Equal(t, allIssues[0].EventsUrl, "https://api.example.com/issues/1/events")
@@ -364,10 +414,12 @@ func TestBadRedisTimestamp(t *testing.T) {
 	})
 
 	cacheKey := "github:repo:lodash:lodash:issues"
+	expectPageCacheMiss(redisMock, ts.URL, lodashIssuesPath)
+	expectIssueSync(redisMock, "lodash", "lodash")
 	redisMock.On("Get", cacheKey).Return("fish|chicken", nil)
 	redisMock.On("Set", cacheKey, "", time.Duration(0)).Return(nil)
 
-	allIssues, err := gh.ListIssues(mocks.DummyLogger(t), "lodash", "lodash")
+	allIssues, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
 	assert.NoError(t, err)
 	assert.Equal(t, len(allIssues), 4)
 	assert.Equal(t, allIssues[0].EventsUrl, "https://api.example.com/issues/1/events")
@@ -392,13 +444,15 @@ func TestBadRedisJSON(t *testing.T) {
 
 	brokenIssueJson := `{"title":"Test Issue`
 	cacheKey := "github:repo:lodash:lodash:issues"
+	expectPageCacheMiss(redisMock, ts.URL, lodashIssuesPath)
+	expectIssueSync(redisMock, "lodash", "lodash")
 	redisMock.On("Get", cacheKey).Return(
 		fmt.Sprintf("%d|%s", time.Now().Unix(), brokenIssueJson),
 		nil,
 	)
 	redisMock.On("Set", cacheKey, "", time.Duration(0)).Return(nil)
 
-	allIssues, err := gh.ListIssues(mocks.DummyLogger(t), "lodash", "lodash")
+	allIssues, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
 	assert.NoError(t, err)
 	assert.Equal(t, len(allIssues), 4)
 	assert.Equal(t, allIssues[0].EventsUrl, "https://api.example.com/issues/1/events")
@@ -496,7 +550,7 @@ func TestTopIssues(t *testing.T) {
 		BaseUrl: ts.URL,
 		Token:   "deadbeef",
 	})
-	allIssues, err := gh.ListTopIssues(mocks.DummyLogger(t), "lodash", "lodash", 5)
+	allIssues, err := gh.ListTopIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash", 5, IssueFilter{})
 	assert.NoError(t, err)
 	assert.Equal(t, call, 2)
 	assert.Equal(t, len(allIssues), 5)
@@ -507,6 +561,24 @@ func TestTopIssues(t *testing.T) {
 	}
 }
 
+func TestTopIssuesInvalidJson(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message":"not a list"}`)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	_, err := gh.ListTopIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash", 5, IssueFilter{})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadGateway, err.Status)
+	assert.Equal(t, errors.CodeInvalidResponse, err.Code)
+}
+
 const topPrsJsonPage2 string = `[{
 	"created_at":"2016-06-07T03:26:14.739Z",
 	"closed_at":null,
@@ -567,7 +639,7 @@ func TestTopPrs(t *testing.T) {
 		BaseUrl: ts.URL,
 		Token:   "deadbeef",
 	})
-	allIssues, err := gh.ListTopPrs(mocks.DummyLogger(t), "lodash", "lodash", 5)
+	allIssues, err := gh.ListTopPrs(context.Background(), mocks.DummyLogger(t), "lodash", "lodash", 5, IssueFilter{})
 	assert.NoError(t, err)
 	assert.Equal(t, call, 2)
 	assert.Equal(t, len(allIssues), 5)
@@ -657,7 +729,12 @@ func TestListAllPrEvents(t *testing.T) {
 	t.Parallel()
 
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintln(w, prEventsJson)
+		switch r.URL.Path {
+		case "/repos/lodash/lodash/issues/events":
+			fmt.Fprintln(w, prEventsJson)
+		default:
+			assert.FailNow(t, fmt.Sprintf("Unexpected request for %s", r.URL.String()))
+		}
 	}))
 	defer ts.Close()
 
@@ -666,7 +743,7 @@ func TestListAllPrEvents(t *testing.T) {
 		Token:   "deadbeef",
 	})
 
-	prEvents, err := gh.ListAllPrEvents(mocks.DummyLogger(t), "lodash", "lodash")
+	prEvents, err := gh.ListAllPrEvents(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
 	assert.NoError(t, err)
 	assert.Len(t, prEvents, 5)
 
@@ -692,8 +769,346 @@ func TestGithubError(t *testing.T) {
 		Token:   "deadbeef",
 	})
 
-	_, err := gh.ListIssues(mocks.DummyLogger(t), "lodash", "lodash")
+	_, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.Error(t, err)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	t.Parallel()
+
+	unblock := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	defer ts.Close()
+	defer close(unblock)
+
+	gh := NewClient(&Options{
+		BaseUrl:        ts.URL,
+		RequestTimeout: 50 * time.Millisecond,
+		Token:          "deadbeef",
+	})
+
+	_, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusGatewayTimeout, err.Status)
+}
+
+func TestOverallTimeout(t *testing.T) {
+	t.Parallel()
+
+	unblock := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	defer ts.Close()
+	defer close(unblock)
+
+	gh := NewClient(&Options{
+		BaseUrl:        ts.URL,
+		OverallTimeout: 50 * time.Millisecond,
+		Token:          "deadbeef",
+	})
+
+	_, err := gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusGatewayTimeout, err.Status)
+}
+
+func TestCanceledContext(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected no request to reach Github")
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := gh.ListIssues(ctx, mocks.DummyLogger(t), "lodash", "lodash")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, err.Status)
+}
+
+func TestUpstreamStatusMapping(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		upstream int
+		status   int
+		code     string
+	}{
+		{http.StatusNotFound, http.StatusNotFound, errors.CodeNotFound},
+		{http.StatusUnauthorized, http.StatusBadGateway, errors.CodeUnauthorized},
+		{http.StatusForbidden, http.StatusForbidden, errors.CodeForbidden},
+		{http.StatusUnprocessableEntity, http.StatusBadRequest, errors.CodeBadRequest},
+		{http.StatusInternalServerError, http.StatusBadGateway, errors.CodeUpstream},
+	} {
+		upstream := tc.upstream
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(upstream)
+			fmt.Fprintln(w, `{"message":"Something is wrong"}`)
+		}))
+
+		gh := NewClient(&Options{
+			BaseUrl: ts.URL,
+			Token:   "deadbeef",
+		})
+		_, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+		ts.Close()
+
+		assert.Error(t, err)
+		assert.Equal(t, tc.status, err.Status)
+		assert.Equal(t, tc.code, err.Code)
+		assert.Equal(t, tc.upstream, err.UpstreamStatus)
+		assert.Contains(t, err.Cause.Error(), "Something is wrong")
+	}
+}
+
+const ghostIssuesJson string = `[{
+	"created_at":"2016-03-07T03:26:14.739Z",
+	"closed_at":null,
+	"events_url":"https://api.example.com/issues/1/events",
+	"html_url":"https://api.example.com/issues/1",
+	"number":1,
+	"title":"Test 1",
+	"user":null
+}]`
+
+func TestListIssuesGhostUser(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, ghostIssuesJson)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	allIssues, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, allIssues, 1)
+	assert.Equal(t, "ghost", allIssues[0].Submitter)
+}
+
+const issuesMissingNumberJson string = `[{
+	"created_at":"2016-03-07T03:26:14.739Z",
+	"closed_at":null,
+	"title":"Test 1",
+	"user":{"login":"tester1"}
+}]`
+
+func TestListIssuesSchemaViolation(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, issuesMissingNumberJson)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	_, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
 	assert.Error(t, err)
+	assert.Equal(t, http.StatusBadGateway, err.Status)
+	assert.Equal(t, errors.CodeInvalidResponse, err.Code)
+}
+
+const ghostPrEventsJson string = `[{
+	"actor": null,
+	"created_at": "2016-03-16T22:24:01.799Z",
+	"event": "labeled",
+	"id": 87931,
+	"issue": {
+		"created_at":"2016-03-16T22:20:14.739Z",
+		"closed_at":null,
+		"number":9,
+		"pull_request":{},
+		"title":"PR 9",
+		"user":null
+	},
+	"label": null
+}]`
+
+func TestListAllPrEventsGhostActor(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/lodash/lodash/issues/events":
+			fmt.Fprintln(w, ghostPrEventsJson)
+		default:
+			assert.FailNow(t, fmt.Sprintf("Unexpected request for %s", r.URL.String()))
+		}
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+
+	prEvents, err := gh.ListAllPrEvents(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, prEvents, 2)
+	assertIssueEventContents(t, prEvents[0], "ghost", IssueCreated, "cr9", 9)
+	assertIssueEventContents(t, prEvents[1], "ghost", IssueLabeled, "87931", 9)
+	assert.Nil(t, prEvents[1].Detail)
+}
+
+const labeledIssuesJson string = `[{
+	"assignees":[{"login":"tester2","id":2}],
+	"comments":3,
+	"created_at":"2016-03-07T03:26:14.739Z",
+	"closed_at":null,
+	"events_url":"https://api.example.com/issues/1/events",
+	"html_url":"https://api.example.com/issues/1",
+	"labels":[{"id":7,"name":"bug","color":"fc2929"}],
+	"milestone":{"number":1,"title":"v1.0"},
+	"number":1,
+	"title":"Test 1",
+	"user":{"login":"tester1"}
+}, {
+	"assignees":[],
+	"created_at":"2016-03-07T03:23:53.002Z",
+	"closed_at":null,
+	"events_url":"https://api.example.com/issues/2/events",
+	"html_url":"https://api.example.com/issues/2",
+	"labels":[{"id":8,"name":"docs","color":"0000ff"}],
+	"milestone":null,
+	"number":2,
+	"title":"Test 2",
+	"user":{"login":"tester1"}
+}]`
+
+func TestListIssuesLabelsAndAssignees(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, labeledIssuesJson)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	allIssues, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, allIssues, 2)
+	assert.Equal(t, []string{"tester2"}, allIssues[0].Assignees)
+	assert.Equal(t, 3, allIssues[0].Comments)
+	assert.Equal(t, []Label{Label{Color: "fc2929", Name: "bug"}}, allIssues[0].Labels)
+	assert.Equal(t, "v1.0", allIssues[0].Milestone)
+	assert.Empty(t, allIssues[1].Assignees)
+	assert.Equal(t, "", allIssues[1].Milestone)
+}
+
+func TestCleanIssueJsonsKeepsLabels(t *testing.T) {
+	t.Parallel()
+
+	var rawIssues []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(labeledIssuesJson), &rawIssues))
+	cleanIssueJsons(rawIssues)
+	assert.Equal(t, map[string]interface{}{"color": "fc2929", "name": "bug"},
+		rawIssues[0]["labels"].([]interface{})[0])
+	assert.Equal(t, map[string]interface{}{"login": "tester2"},
+		rawIssues[0]["assignees"].([]interface{})[0])
+	assert.Equal(t, map[string]interface{}{"title": "v1.0"}, rawIssues[0]["milestone"])
+}
+
+func TestTopIssuesFilter(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Github applies the label filter.
+		assert.Equal(t, "DOCS", r.URL.Query().Get("labels"))
+		var issues []map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(labeledIssuesJson), &issues))
+		jsonBlob, _ := json.Marshal(issues[1:])
+		fmt.Fprintln(w, string(jsonBlob))
+	}))
+	defer ts.Close()
+
+	redisMock := &mocks.MockRediser{}
+	gh := NewClient(&Options{
+		BaseUrl:      ts.URL,
+		MaxStaleness: 5,
+		RedisClient:  redisMock,
+		Token:        "deadbeef",
+	})
+	cacheKey := "github:repo:lodash:lodash:top_issues:5:label=DOCS:assignee=:milestone="
+	expectPageCacheMiss(
+		redisMock,
+		ts.URL,
+		"/repos/lodash/lodash/issues?per_page=100&state=open&sort=created&direction=desc&labels=DOCS",
+	)
+	redisMock.On("Get", cacheKey).Return("", nil)
+	redisMock.On("Set", cacheKey, "", time.Duration(0)).Return(nil)
+
+	topIssues, err := gh.ListTopIssues(
+		context.Background(),
+		mocks.DummyLogger(t),
+		"lodash",
+		"lodash",
+		5,
+		IssueFilter{Label: "DOCS"},
+	)
+	assert.NoError(t, err)
+	assert.Len(t, topIssues, 1)
+	assert.Equal(t, 2, topIssues[0].Number)
+	redisMock.AssertExpectations(t)
+}
+
+func TestTopIssuesFilterMilestone(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "tester2", r.URL.Query().Get("assignee"))
+		assert.Equal(t, "", r.URL.Query().Get("milestone"))
+		fmt.Fprintln(w, labeledIssuesJson)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	topIssues, err := gh.ListTopIssues(
+		context.Background(),
+		mocks.DummyLogger(t),
+		"lodash",
+		"lodash",
+		5,
+		IssueFilter{Assignee: "tester2", Milestone: "v1.0"},
+	)
+	assert.Nil(t, err)
+	assert.Len(t, topIssues, 1)
+	assert.Equal(t, 1, topIssues[0].Number)
+}
+
+func TestIssueFilter(t *testing.T) {
+	t.Parallel()
+
+	issue := &Issue{
+		Assignees: []string{"tester2"},
+		Labels:    []Label{Label{Name: "bug"}},
+		Milestone: "v1.0",
+	}
+	assert.True(t, IssueFilter{}.Matches(issue))
+	assert.True(t, IssueFilter{Assignee: "tester2", Label: "Bug", Milestone: "v1.0"}.Matches(issue))
+	assert.False(t, IssueFilter{Assignee: "tester1"}.Matches(issue))
+	assert.False(t, IssueFilter{Label: "docs"}.Matches(issue))
+	assert.False(t, IssueFilter{Milestone: "v2.0"}.Matches(issue))
 }
 
 func TestMarshalIssue(t *testing.T) {
@@ -727,4 +1142,654 @@ func TestMarshalIssue(t *testing.T) {
 	assert.Equal(t, 99.0, issue["number"].(float64))
 	assert.Equal(t, "tester1", issue["submitter"].(string))
 	assert.Equal(t, "Test Issue", issue["title"].(string))
+	assert.Equal(t, []interface{}{}, issue["assignees"])
+	assert.Equal(t, []interface{}{}, issue["labels"])
+	assert.Equal(t, "", issue["milestone"].(string))
+	assert.Equal(t, 0.0, issue["comments"].(float64))
+}
+
+func TestConditionalRequestNotModified(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, `"abc123"`, r.Header.Get("If-None-Match"))
+		assert.Equal(t, "Mon, 07 Mar 2016 03:26:14 GMT", r.Header.Get("If-Modified-Since"))
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer ts.Close()
+
+	redisMock := &mocks.MockRediser{}
+	gh := NewClient(&Options{
+		BaseUrl:      ts.URL,
+		MaxStaleness: 5,
+		RedisClient:  redisMock,
+		Token:        "deadbeef",
+	})
+
+	cacheKey := "github:repo:lodash:lodash:issues"
+	redisMock.On("Get", cacheKey).Return("", nil)
+	redisMock.On("Get", pageKey(ts.URL+lodashIssuesPath)).Return(
+		string(mocks.MarshalJSON(t, &cachedPage{
+			Body:         issuesJson,
+			ETag:         `"abc123"`,
+			LastModified: "Mon, 07 Mar 2016 03:26:14 GMT",
+		})),
+		nil,
+	)
+	redisMock.On("Set", cacheKey, "", time.Duration(0)).Return(nil)
+	expectIssueSync(redisMock, "lodash", "lodash")
+
+	allIssues, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, allIssues, 4)
+	assert.Equal(t, allIssues[0].EventsUrl, "https://api.example.com/issues/1/events")
+	redisMock.AssertExpectations(t)
+}
+
+func TestConditionalRequestStoresETag(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "", r.Header.Get("If-None-Match"))
+		w.Header().Set("ETag", `"abc123"`)
+		fmt.Fprintln(w, issuesJson)
+	}))
+	defer ts.Close()
+
+	redisMock := &mocks.MockRediser{}
+	gh := NewClient(&Options{
+		BaseUrl:      ts.URL,
+		MaxStaleness: 5,
+		RedisClient:  redisMock,
+		Token:        "deadbeef",
+	})
+
+	cacheKey := "github:repo:lodash:lodash:issues"
+	redisMock.On("Get", cacheKey).Return("", nil)
+	expectPageCacheMiss(redisMock, ts.URL, lodashIssuesPath)
+	redisMock.On("Set", pageKey(ts.URL+lodashIssuesPath), "", 10*time.Minute).Return(nil)
+	redisMock.On("Set", cacheKey, "", time.Duration(0)).Return(nil)
+	expectIssueSync(redisMock, "lodash", "lodash")
+
+	_, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	redisMock.AssertExpectations(t)
+}
+
+func TestCachedPagesExpire(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc123"`)
+		fmt.Fprintln(w, `[]`)
+	}))
+	defer ts.Close()
+
+	clock := clockwork.NewFakeClock()
+	redis := interfaces.NewMemoryRedis(clock, 1<<20)
+	gh := NewClient(&Options{
+		BaseUrl:      ts.URL,
+		Clock:        clock,
+		MaxStaleness: 5,
+		RedisClient:  redis,
+		Token:        "deadbeef",
+	})
+	ctx := context.Background()
+	logger := mocks.DummyLogger(t)
+	_, _, err := gh.fetchGithubPage(ctx, logger, ts.URL+lodashIssuesPath, "application/json")
+	assert.Nil(t, err)
+	assert.NotNil(t, gh.loadCachedPage(ctx, logger, ts.URL+lodashIssuesPath))
+
+	clock.Advance(10 * time.Minute)
+	assert.Nil(t, gh.loadCachedPage(ctx, logger, ts.URL+lodashIssuesPath))
+}
+
+func TestVolatilePagesAreNotCached(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc123"`)
+		fmt.Fprintln(w, `[]`)
+	}))
+	defer ts.Close()
+
+	redis := interfaces.NewMemoryRedis(clockwork.NewRealClock(), 1<<20)
+	gh := NewClient(&Options{
+		BaseUrl:     ts.URL,
+		RedisClient: redis,
+		Token:       "deadbeef",
+	})
+	ctx := context.Background()
+	pageUrl := ts.URL + "/repos/lodash/lodash/issues?per_page=100&since=2016-03-07T04:00:00Z"
+	_, _, err := gh.fetchGithubPage(ctx, mocks.DummyLogger(t), pageUrl, "application/json")
+	assert.Nil(t, err)
+
+	_, redisErr := redis.Get(ctx, pageKey(pageUrl))
+	assert.Equal(t, interfaces.ErrNil, redisErr)
+}
+
+func TestSyncStargazers(t *testing.T) {
+	t.Parallel()
+
+	var lastPage string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.String() {
+		case "/repos/angular/angular/stargazers?per_page=100&page=1":
+			w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"next\", <%s>; rel=\"last\"", lastPage, lastPage))
+			fmt.Fprintln(w, starsJson)
+		case pathAndQueryOnly(t, lastPage):
+			fmt.Fprintln(w, starsJsonPage2)
+		default:
+			assert.FailNow(t, fmt.Sprintf("Unexpected request for %s", r.URL.String()))
+		}
+	}))
+	defer ts.Close()
+	lastPage = fmt.Sprintf("%s/repos/angular/angular/stargazers?page=2&per_page=100", ts.URL)
+
+	redisMock := &mocks.MockRediser{}
+	gh := NewClient(&Options{
+		BaseUrl:      ts.URL,
+		MaxStaleness: 5,
+		RedisClient:  redisMock,
+		Token:        "deadbeef",
+	})
+
+	cacheKey := "github:repo:angular:angular:stargazers"
+	redisMock.On("Get", cacheKey).Return(
+		fmt.Sprintf(
+			`%d|[{"starred_at":"2016-03-07T03:25:41.469Z"},{"starred_at":"2016-03-07T03:23:53.002Z"}]`,
+			time.Now().Add(time.Duration(-6)*time.Minute).Unix(),
+		),
+		nil,
+	)
+	expectPageCacheMiss(redisMock, ts.URL, "/repos/angular/angular/stargazers?per_page=100&page=1")
+	expectPageCacheMiss(redisMock, ts.URL, "/repos/angular/angular/stargazers?page=2&per_page=100")
+	redisMock.On("Set", cacheKey, "", time.Duration(0)).Return(nil)
+
+	starEvents, err := gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+	assert.NoError(t, err)
+	assert.Len(t, starEvents, 6)
+	redisMock.AssertExpectations(t)
+}
+
+func TestSyncStargazersOutOfSync(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, starsJson)
+	}))
+	defer ts.Close()
+
+	redisMock := &mocks.MockRediser{}
+	gh := NewClient(&Options{
+		BaseUrl:      ts.URL,
+		MaxStaleness: 5,
+		RedisClient:  redisMock,
+		Token:        "deadbeef",
+	})
+
+	cacheKey := "github:repo:angular:angular:stargazers"
+	redisMock.On("Get", cacheKey).Return(
+		fmt.Sprintf(
+			`%d|[{"starred_at":"2016-03-07T03:25:41.469Z"},{"starred_at":"2016-01-01T00:00:00Z"}]`,
+			time.Now().Add(time.Duration(-6)*time.Minute).Unix(),
+		),
+		nil,
+	)
+	expectPageCacheMiss(redisMock, ts.URL, "/repos/angular/angular/stargazers?per_page=100&page=1")
+	expectPageCacheMiss(redisMock, ts.URL, "/repos/angular/angular/stargazers?per_page=100")
+	redisMock.On("Set", cacheKey, "", time.Duration(0)).Return(nil)
+
+	starEvents, err := gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+	assert.NoError(t, err)
+	assert.Len(t, starEvents, 3)
+	redisMock.AssertExpectations(t)
+}
+
+func starsPageJson(t *testing.T, starIds []int) string {
+	stars := make([]map[string]interface{}, len(starIds))
+	for i, id := range starIds {
+		stars[i] = map[string]interface{}{
+			"starred_at": time.Unix(1457321141+int64(id), 0).UTC().Format(time.RFC3339),
+		}
+	}
+	jsonBlob, err := json.Marshal(stars)
+	assert.NoError(t, err)
+	return string(jsonBlob)
+}
+
+func starIdRange(first, last int) []int {
+	ids := make([]int, 0, last-first+1)
+	for id := first; id <= last; id++ {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// newStargazersServer serves the given stargazers 100 to a page.
+func newStargazersServer(t *testing.T, starIds []int) *httptest.Server {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		lastPage := (len(starIds)-1)/stargazersPerPage + 1
+		if page < lastPage {
+			w.Header().Add("Link", fmt.Sprintf(
+				"<%s/repos/angular/angular/stargazers?per_page=100&page=%d>; rel=\"next\", "+
+					"<%s/repos/angular/angular/stargazers?per_page=100&page=%d>; rel=\"last\"",
+				ts.URL, page+1, ts.URL, lastPage,
+			))
+		}
+		end := page * stargazersPerPage
+		if end > len(starIds) {
+			end = len(starIds)
+		}
+		fmt.Fprintln(w, starsPageJson(t, starIds[(page-1)*stargazersPerPage:end]))
+	}))
+	return ts
+}
+
+func TestSyncStargazersFullPage(t *testing.T) {
+	t.Parallel()
+
+	ts := newStargazersServer(t, starIdRange(0, 100))
+	defer ts.Close()
+
+	redis := interfaces.NewMemoryRedis(clockwork.NewRealClock(), 1<<20)
+	gh := NewClient(&Options{
+		BaseUrl:      ts.URL,
+		MaxStaleness: 5,
+		RedisClient:  redis,
+		Token:        "deadbeef",
+	})
+	ctx := context.Background()
+	redis.Set(ctx, stargazersKey("angular", "angular"), fmt.Sprintf(
+		"%d|%s",
+		time.Now().Add(-6*time.Minute).Unix(),
+		starsPageJson(t, starIdRange(0, 99)),
+	), 0)
+
+	starEvents, err := gh.ListStarEvents(ctx, mocks.DummyLogger(t), "angular", "angular")
+	assert.Nil(t, err)
+	assert.Len(t, starEvents, 101)
+	assert.Equal(t, int64(1457321141+100), starEvents[100].StarredAt.Unix())
+}
+
+func TestSyncStargazersFullPageOutOfSync(t *testing.T) {
+	t.Parallel()
+
+	// Stargazer 99 unstarred, and 100 and 101 starred since the cache was
+	// stored, so the cache still ends on a page boundary.
+	ts := newStargazersServer(t, append(starIdRange(0, 98), 100, 101))
+	defer ts.Close()
+
+	redis := interfaces.NewMemoryRedis(clockwork.NewRealClock(), 1<<20)
+	gh := NewClient(&Options{
+		BaseUrl:      ts.URL,
+		MaxStaleness: 5,
+		RedisClient:  redis,
+		Token:        "deadbeef",
+	})
+	ctx := context.Background()
+	redis.Set(ctx, stargazersKey("angular", "angular"), fmt.Sprintf(
+		"%d|%s",
+		time.Now().Add(-6*time.Minute).Unix(),
+		starsPageJson(t, starIdRange(0, 99)),
+	), 0)
+
+	starEvents, err := gh.ListStarEvents(ctx, mocks.DummyLogger(t), "angular", "angular")
+	assert.Nil(t, err)
+	assert.Len(t, starEvents, 101)
+	assert.Equal(t, int64(1457321141+100), starEvents[99].StarredAt.Unix())
+	assert.Equal(t, int64(1457321141+101), starEvents[100].StarredAt.Unix())
+}
+
+const updatedIssuesJson string = `[{
+	"created_at":"2016-03-07T03:26:14.739Z",
+	"closed_at":"2016-03-08T03:26:14.739Z",
+	"events_url":"https://api.example.com/issues/1/events",
+	"html_url":"https://api.example.com/issues/1",
+	"number":1,
+	"title":"Test 1",
+	"user":{"login":"tester1"}
+}, {
+	"created_at":"2016-03-08T03:46:46.458Z",
+	"events_url":"https://api.example.com/issues/5/events",
+	"html_url":"https://api.example.com/issues/5",
+	"number":5,
+	"title":"Test 5",
+	"user":{"login":"tester2"}
+}]`
+
+func TestIncrementalIssueSync(t *testing.T) {
+	t.Parallel()
+
+	issuesPath := "/repos/lodash/lodash/issues?per_page=100&state=all&sort=updated&direction=asc&since=2016-03-07T04:00:00Z"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, issuesPath, pathAndQueryOnly(t, r.URL.String()))
+		fmt.Fprintln(w, updatedIssuesJson)
+	}))
+	defer ts.Close()
+
+	redisMock := &mocks.MockRediser{}
+	gh := NewClient(&Options{
+		BaseUrl:      ts.URL,
+		MaxStaleness: 5,
+		RedisClient:  redisMock,
+		Token:        "deadbeef",
+	})
+
+	cacheKey := "github:repo:lodash:lodash:issues"
+	redisMock.On("Get", cacheKey).Return(
+		fmt.Sprintf("%d|%s", time.Now().Add(time.Duration(-6)*time.Minute).Unix(), issuesJson),
+		nil,
+	)
+	redisMock.On("Get", issuesSyncedAtKey("lodash", "lodash")).Return("2016-03-07T04:00:00Z", nil)
+	redisMock.On("Set", cacheKey, "", time.Duration(0)).Return(nil)
+	expectIssueSync(redisMock, "lodash", "lodash")
+
+	allIssues, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, allIssues, 5)
+	assert.Equal(t, 1, allIssues[0].Number)
+	assert.True(t, allIssues[0].IsClosed)
+	assert.Equal(t, 5, allIssues[4].Number)
+	assert.Equal(t, "tester2", allIssues[4].Submitter)
+	redisMock.AssertExpectations(t)
+}
+
+func TestForEach(t *testing.T) {
+	t.Parallel()
+
+	var mutex sync.Mutex
+	handled := make(map[int]bool)
+	err := ForEach(context.Background(), 10, 3, func(i int) *errors.HttpError {
+		mutex.Lock()
+		defer mutex.Unlock()
+		handled[i] = true
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, handled, 10)
+}
+
+func TestForEachWithoutConcurrency(t *testing.T) {
+	t.Parallel()
+
+	var mutex sync.Mutex
+	handled := make(map[int]bool)
+	for _, concurrency := range []int{0, -1} {
+		err := ForEach(context.Background(), 3, concurrency, func(i int) *errors.HttpError {
+			mutex.Lock()
+			defer mutex.Unlock()
+			handled[i] = true
+			return nil
+		})
+		assert.Nil(t, err)
+	}
+	assert.Len(t, handled, 3)
+
+	err := ForEach(context.Background(), 0, 0, func(i int) *errors.HttpError {
+		assert.Fail(t, "There is nothing to handle!")
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+func TestForEachStopsWhenCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var mutex sync.Mutex
+	calls := 0
+	err := ForEach(ctx, 100, 1, func(i int) *errors.HttpError {
+		mutex.Lock()
+		defer mutex.Unlock()
+		calls++
+		cancel()
+		return nil
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, errors.CodeCanceled, err.Code)
+	assert.Equal(t, 1, calls)
+}
+
+func TestParallelPagination(t *testing.T) {
+	t.Parallel()
+
+	var mutex sync.Mutex
+	requestedPages := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		mutex.Lock()
+		requestedPages[page]++
+		mutex.Unlock()
+		if page == "" {
+			w.Header().Add("Link", fmt.Sprintf(
+				"<http://%s/repos/angular/angular/stargazers?per_page=100&page=2>; rel=\"next\", "+
+					"<http://%s/repos/angular/angular/stargazers?per_page=100&page=4>; rel=\"last\"",
+				r.Host,
+				r.Host,
+			))
+		}
+		fmt.Fprintf(w, `[{"starred_at":"2016-03-0%sT03:25:41.469Z"}]`, withDefault(page, "1"))
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl:         ts.URL,
+		PageConcurrency: 2,
+		Token:           "deadbeef",
+	})
+	stargazers, err := gh.paginateGithub(
+		context.Background(),
+		mocks.DummyLogger(t),
+		fmt.Sprintf("%s/repos/angular/angular/stargazers?per_page=100", ts.URL),
+		"application/vnd.github.v3.star+json",
+	)
+	assert.NoError(t, err)
+	assert.Len(t, stargazers, 4)
+	for i, stargazer := range stargazers {
+		assert.Equal(t, fmt.Sprintf("2016-03-0%dT03:25:41.469Z", i+1), stargazer["starred_at"])
+	}
+	assert.Equal(t, map[string]int{"": 1, "2": 1, "3": 1, "4": 1}, requestedPages)
+}
+
+func TestParallelPaginationError(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Add("Link", fmt.Sprintf(
+				"<http://%s/stargazers?page=2>; rel=\"next\", <http://%s/stargazers?page=3>; rel=\"last\"",
+				r.Host,
+				r.Host,
+			))
+			fmt.Fprintln(w, starsJson)
+		case "2":
+			fmt.Fprintln(w, starsBadJson)
+		default:
+			fmt.Fprintln(w, starsJsonPage2)
+		}
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	_, err := gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusBadGateway, err.Status)
+}
+
+func withDefault(value, default_ string) string {
+	if value == "" {
+		return default_
+	}
+	return value
+}
+
+const pr3EventsJson string = `[{
+	"actor": {"login": "tester3"},
+	"created_at": "2016-03-07T03:46:40.000Z",
+	"event": "labeled",
+	"id": 1001,
+	"label": {"name": "ready for review", "color": "00ff00"}
+}, {
+	"actor": {"login": "tester2"},
+	"commit_id": "deadbeef",
+	"created_at": "2016-03-07T03:46:55.993Z",
+	"event": "merged",
+	"id": 1002
+}]`
+
+const truncatedPrEventsJson string = `[{
+	"actor": {"login": "tester2"},
+	"commit_id": "deadbeef",
+	"created_at": "2016-03-07T03:46:55.993Z",
+	"event": "merged",
+	"id": 1002,
+	"issue": {
+		"created_at":"2016-03-07T03:46:36.717Z",
+		"closed_at":"2016-03-07T03:46:55.993Z",
+		"events_url":"https://api.example.com/issues/3/events",
+		"html_url":"https://api.example.com/pull/3",
+		"number":3,
+		"pull_request":{},
+		"title":"Test 3",
+		"user":{"login":"tester1"}
+	}
+}]`
+
+// truncatedPrEventsFeed pads truncatedPrEventsJson out to the length of a
+// truncated feed with later events on an issue.
+func truncatedPrEventsFeed(t *testing.T) string {
+	var events []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(truncatedPrEventsJson), &events))
+	for id := 2000; len(events) < issueEventsFeedLimit; id++ {
+		events = append(events, map[string]interface{}{
+			"actor":      map[string]interface{}{"login": "tester1"},
+			"created_at": "2016-03-08T03:26:14.739Z",
+			"event":      "labeled",
+			"id":         id,
+			"issue": map[string]interface{}{
+				"created_at": "2016-03-07T03:26:14.739Z",
+				"html_url":   "https://api.example.com/issues/1",
+				"number":     1,
+				"title":      "Test 1",
+				"user":       map[string]interface{}{"login": "tester1"},
+			},
+			"label": map[string]interface{}{"name": "bug", "color": "ff0000"},
+		})
+	}
+	jsonBlob, err := json.Marshal(events)
+	assert.NoError(t, err)
+	return string(jsonBlob)
+}
+
+func TestListAllPrEventsBackfillsTruncatedFeed(t *testing.T) {
+	t.Parallel()
+
+	feed := truncatedPrEventsFeed(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/lodash/lodash/issues/events":
+			fmt.Fprintln(w, feed)
+		case "/repos/lodash/lodash/issues":
+			fmt.Fprintln(w, issuesJson)
+		case "/repos/lodash/lodash/issues/3/events":
+			fmt.Fprintln(w, pr3EventsJson)
+		case "/repos/lodash/lodash/issues/4/events":
+			fmt.Fprintln(w, "[]")
+		default:
+			assert.FailNow(t, fmt.Sprintf("Unexpected request for %s", r.URL.String()))
+		}
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+
+	prEvents, err := gh.ListAllPrEvents(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, prEvents, 4)
+	assertIssueEventContents(t, prEvents[0], "tester1", IssueCreated, "cr3", 3)
+	assertIssueEventContents(t, prEvents[1], "tester2", IssueMerged, "1002", 3)
+	assertIssueEventContents(t, prEvents[2], "tester3", IssueLabeled, "1001", 3)
+	assertIssueEventContents(t, prEvents[3], "tester1", IssueCreated, "cr4", 4)
+}
+
+func TestListAllPrEventsShortFeed(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/lodash/lodash/issues/events":
+			fmt.Fprintln(w, truncatedPrEventsJson)
+		default:
+			assert.FailNow(t, fmt.Sprintf("Unexpected request for %s", r.URL.String()))
+		}
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+
+	prEvents, err := gh.ListAllPrEvents(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.Nil(t, err)
+	assert.Len(t, prEvents, 2)
+	assertIssueEventContents(t, prEvents[0], "tester1", IssueCreated, "cr3", 3)
+	assertIssueEventContents(t, prEvents[1], "tester2", IssueMerged, "1002", 3)
+}
+
+func TestListAllPrEventsCachesBackfills(t *testing.T) {
+	t.Parallel()
+
+	feed := truncatedPrEventsFeed(t)
+	var mutex sync.Mutex
+	backfillRequests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/lodash/lodash/issues/events":
+			fmt.Fprintln(w, feed)
+		case "/repos/lodash/lodash/issues":
+			fmt.Fprintln(w, issuesJson)
+		case "/repos/lodash/lodash/issues/3/events":
+			mutex.Lock()
+			backfillRequests++
+			mutex.Unlock()
+			fmt.Fprintln(w, pr3EventsJson)
+		case "/repos/lodash/lodash/issues/4/events":
+			mutex.Lock()
+			backfillRequests++
+			mutex.Unlock()
+			fmt.Fprintln(w, "[]")
+		default:
+			assert.FailNow(t, fmt.Sprintf("Unexpected request for %s", r.URL.String()))
+		}
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl:      ts.URL,
+		MaxStaleness: 5,
+		RedisClient:  interfaces.NewMemoryRedis(clockwork.NewRealClock(), 1<<22),
+		Token:        "deadbeef",
+	})
+
+	for i := 0; i < 2; i++ {
+		prEvents, err := gh.ListAllPrEvents(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+		assert.Nil(t, err)
+		assert.Len(t, prEvents, 4)
+		assertIssueEventContents(t, prEvents[2], "tester3", IssueLabeled, "1001", 3)
+	}
+	assert.Equal(t, 2, backfillRequests)
 }
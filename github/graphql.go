@@ -0,0 +1,1367 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+)
+
+// Backend is the set of operations implemented by both the REST and the
+// GraphQL clients, so the services can pick either one at startup.
+type Backend interface {
+	GetRateLimiter
+	GetTrafficer
+	GetWatcherCounter
+	ListAllPrEventser
+	ListCodeFrequencyer
+	ListCommitser
+	ListContributorStatser
+	ListForkser
+	ListIssueser
+	ListPullRequestser
+	ListReleaseser
+	ListRepositorieser
+	ListStarEventser
+	ListTagser
+	ListTopIssueser
+	ListTopPrser
+}
+
+func NewBackend(api string, options *Options) (Backend, error) {
+	switch api {
+	case "", "rest":
+		return NewClient(options), nil
+	case "graphql":
+		return NewGraphQLClient(options), nil
+	}
+	return nil, fmt.Errorf("Unknown Github API %q, expected rest or graphql", api)
+}
+
+type GraphQLClient struct {
+	client     *Client
+	graphQLUrl string
+}
+
+// withDefaultGraphQLUrl derives the GraphQL endpoint from the REST base URL
+// when it is not configured. Github Enterprise serves the REST API from
+// /api/v3 and the GraphQL API from /api/graphql.
+func withDefaultGraphQLUrl(graphQLUrl, baseUrl string) string {
+	if graphQLUrl != "" {
+		return graphQLUrl
+	}
+	if strings.HasSuffix(baseUrl, "/api/v3") {
+		return fmt.Sprintf("%s/graphql", strings.TrimSuffix(baseUrl, "/v3"))
+	}
+	return "https://api.github.com/graphql"
+}
+
+func NewGraphQLClient(options *Options) *GraphQLClient {
+	gql := &GraphQLClient{}
+	gql.client = NewClient(options)
+	gql.graphQLUrl = withDefaultGraphQLUrl(options.GraphQLUrl, gql.client.baseUrl)
+	return gql
+}
+
+type graphQLPageInfo struct {
+	EndCursor   string `json:"endCursor"`
+	HasNextPage bool   `json:"hasNextPage"`
+}
+
+type graphQLActor struct {
+	Login string `json:"login"`
+}
+
+type graphQLIssue struct {
+	Assignees struct {
+		Nodes []graphQLActor `json:"nodes"`
+	} `json:"assignees"`
+	Author   *graphQLActor `json:"author"`
+	ClosedAt *string       `json:"closedAt"`
+	Comments struct {
+		TotalCount int `json:"totalCount"`
+	} `json:"comments"`
+	CreatedAt string `json:"createdAt"`
+	Labels    struct {
+		Nodes []struct {
+			Color string `json:"color"`
+			Name  string `json:"name"`
+		} `json:"nodes"`
+	} `json:"labels"`
+	Milestone *struct {
+		Title string `json:"title"`
+	} `json:"milestone"`
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Url    string `json:"url"`
+}
+
+type graphQLTimelineItem struct {
+	Actor  *graphQLActor `json:"actor"`
+	Commit *struct {
+		Oid string `json:"oid"`
+	} `json:"commit"`
+	CreatedAt string `json:"createdAt"`
+	Id        string `json:"id"`
+	Label     *struct {
+		Color string `json:"color"`
+		Name  string `json:"name"`
+	} `json:"label"`
+	Typename string `json:"__typename"`
+}
+
+type graphQLTimeline struct {
+	Nodes    []graphQLTimelineItem `json:"nodes"`
+	PageInfo graphQLPageInfo       `json:"pageInfo"`
+}
+
+var timelineEventTypes map[string]DetailedIssueEventType = map[string]DetailedIssueEventType{
+	"ClosedEvent":    IssueClosed,
+	"LabeledEvent":   IssueLabeled,
+	"MergedEvent":    IssueMerged,
+	"UnlabeledEvent": IssueUnlabeled,
+}
+
+// These are the only fields cleanIssueJsons keeps from the REST API, so they
+// are all we ask the GraphQL API for.
+const graphQLIssueFields string = `number title createdAt closedAt url author { login }
+assignees(first: 20) { nodes { login } }
+comments { totalCount }
+labels(first: 50) { nodes { name color } }
+milestone { title }`
+
+const graphQLTimelineFields string = `
+nodes {
+	__typename
+	... on ClosedEvent { id createdAt actor { login } }
+	... on MergedEvent { id createdAt actor { login } commit { oid } }
+	... on LabeledEvent { id createdAt actor { login } label { name color } }
+	... on UnlabeledEvent { id createdAt actor { login } label { name color } }
+}
+pageInfo { endCursor hasNextPage }`
+
+const graphQLTimelineItemTypes string = `[CLOSED_EVENT, MERGED_EVENT, LABELED_EVENT, UNLABELED_EVENT]`
+
+func repositoryNotFound(owner, repo string) *errors.HttpError {
+	return &errors.HttpError{
+		Code:    errors.CodeNotFound,
+		Message: fmt.Sprintf("Repository %s/%s Not Found", owner, repo),
+		Status:  http.StatusNotFound,
+	}
+}
+
+func (gql *GraphQLClient) query(
+	ctx context.Context,
+	logger *log.Logger,
+	query string,
+	variables map[string]interface{},
+	data interface{},
+) *errors.HttpError {
+	gh := gql.client
+	if httpErr := gh.checkCircuit(logger); httpErr != nil {
+		return httpErr
+	}
+	if httpErr := gh.awaitRateLimit(ctx, logger); httpErr != nil {
+		return httpErr
+	}
+	// Suppress JSON marshaling errors because we control the query and its
+	// variables.
+	body, _ := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	// Suppress errors from http.NewRequest. This can only error if the URL or
+	// HTTP method are invalid, and we control both in this module.
+	rr, _ := http.NewRequest("POST", gql.graphQLUrl, bytes.NewReader(body))
+	rr = rr.WithContext(ctx)
+	rr.Header.Add("Accept", "application/json")
+	rr.Header.Add("Content-Type", "application/json")
+	resp, httpErr := gh.roundTrip(ctx, logger, rr)
+	// Queries are POSTs, so we do not retry them, but their failures still
+	// count towards opening the circuit breaker.
+	gh.recordUpstreamResult(ctx, logger, isRetryable(ctx, resp, httpErr))
+	if httpErr != nil {
+		return httpErr
+	}
+	defer resp.Body.Close()
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		logger.Printf("ERROR: %s\n", err.Error())
+		return &errors.HttpError{Message: "Server Error", Status: http.StatusInternalServerError}
+	}
+	if !isSuccess(resp) {
+		return upstreamError(logger, resp, contents)
+	}
+	var envelope struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(contents, &envelope); err != nil {
+		logger.Printf("ERROR: %s\n", err.Error())
+		return &errors.HttpError{Message: "Github API Error", Status: http.StatusBadGateway}
+	}
+	if len(envelope.Errors) > 0 {
+		for _, gqlErr := range envelope.Errors {
+			logger.Printf("ERROR: %s\n", gqlErr.Message)
+		}
+		return &errors.HttpError{Message: "Github API Error", Status: http.StatusBadGateway}
+	}
+	if err := json.Unmarshal(envelope.Data, data); err != nil {
+		logger.Printf("ERROR: %s\n", err.Error())
+		return &errors.HttpError{Message: "Github API Error", Status: http.StatusBadGateway}
+	}
+	return nil
+}
+
+// issueJson converts an issue from the GraphQL API into the same shape the
+// REST client caches, so both backends can share cached values.
+func (gql *GraphQLClient) issueJson(
+	owner, repo string,
+	issue *graphQLIssue,
+	isPr bool,
+) map[string]interface{} {
+	var closedAt interface{}
+	if issue.ClosedAt != nil {
+		closedAt = *issue.ClosedAt
+	}
+	login := "ghost"
+	if issue.Author != nil {
+		login = issue.Author.Login
+	}
+	assignees := make([]interface{}, len(issue.Assignees.Nodes))
+	for i, assignee := range issue.Assignees.Nodes {
+		assignees[i] = map[string]interface{}{"login": assignee.Login}
+	}
+	labels := make([]interface{}, len(issue.Labels.Nodes))
+	for i, label := range issue.Labels.Nodes {
+		labels[i] = map[string]interface{}{"color": label.Color, "name": label.Name}
+	}
+	var milestone interface{}
+	if issue.Milestone != nil {
+		milestone = map[string]interface{}{"title": issue.Milestone.Title}
+	}
+	rawIssue := map[string]interface{}{
+		"assignees":  assignees,
+		"closed_at":  closedAt,
+		"comments":   float64(issue.Comments.TotalCount),
+		"created_at": issue.CreatedAt,
+		"events_url": fmt.Sprintf(
+			"%s/repos/%s/%s/issues/%d/events",
+			gql.client.baseUrl,
+			owner,
+			repo,
+			issue.Number,
+		),
+		"html_url":  issue.Url,
+		"labels":    labels,
+		"milestone": milestone,
+		"number":    float64(issue.Number),
+		"title":     issue.Title,
+		"user":      map[string]interface{}{"login": login},
+	}
+	if isPr {
+		rawIssue["pull_request"] = map[string]interface{}{}
+	}
+	return rawIssue
+}
+
+// listIssueNodes pages through the issues or pullRequests connection of a
+// repository, keeping the nodes that match the filter. A limit of 0 fetches
+// every node.
+func (gql *GraphQLClient) listIssueNodes(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo, connection, arguments string,
+	limit int,
+	filter IssueFilter,
+) ([]graphQLIssue, *errors.HttpError) {
+	query := fmt.Sprintf(`query($owner: String!, $repo: String!, $first: Int!, $cursor: String) {
+	repository(owner: $owner, name: $repo) {
+		connection: %s(first: $first, after: $cursor, %s) {
+			nodes { %s }
+			pageInfo { endCursor hasNextPage }
+		}
+	}
+}`, connection, arguments, graphQLIssueFields)
+
+	var allNodes []graphQLIssue
+	var cursor interface{}
+	for {
+		first := 100
+		// Without a filter, every node counts towards the limit, so there is
+		// no need to fetch more than that.
+		if limit > 0 && filter.IsEmpty() && limit-len(allNodes) < first {
+			first = limit - len(allNodes)
+		}
+		var data struct {
+			Repository *struct {
+				Connection struct {
+					Nodes    []graphQLIssue  `json:"nodes"`
+					PageInfo graphQLPageInfo `json:"pageInfo"`
+				} `json:"connection"`
+			} `json:"repository"`
+		}
+		if httpErr := gql.query(ctx, logger, query, map[string]interface{}{
+			"cursor": cursor,
+			"first":  first,
+			"owner":  owner,
+			"repo":   repo,
+		}, &data); httpErr != nil {
+			return nil, httpErr
+		}
+		if data.Repository == nil {
+			return nil, repositoryNotFound(owner, repo)
+		}
+		for i := range data.Repository.Connection.Nodes {
+			node := &data.Repository.Connection.Nodes[i]
+			if limit > 0 && len(allNodes) >= limit {
+				break
+			}
+			if gql.matchesNode(logger, owner, repo, filter, node, connection == "pullRequests") {
+				allNodes = append(allNodes, *node)
+			}
+		}
+		pageInfo := data.Repository.Connection.PageInfo
+		if !pageInfo.HasNextPage || (limit > 0 && len(allNodes) >= limit) {
+			return allNodes, nil
+		}
+		cursor = pageInfo.EndCursor
+	}
+}
+
+func (gql *GraphQLClient) matchesNode(
+	logger *log.Logger,
+	owner, repo string,
+	filter IssueFilter,
+	node *graphQLIssue,
+	isPr bool,
+) bool {
+	return matchesRawIssue(logger, filter, gql.issueJson(owner, repo, node, isPr))
+}
+
+func (gql *GraphQLClient) ListStarEvents(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]StarEvent, *errors.HttpError) {
+	ctx, cancel := gql.client.withDeadline(ctx)
+	defer cancel()
+	untypedStargazers, httpErr := redisWrap(
+		ctx,
+		gql.client,
+		stargazersKey(owner, repo),
+		"stargazers",
+		logger,
+		func(ctx context.Context, _ []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			query := `query($owner: String!, $repo: String!, $cursor: String) {
+	repository(owner: $owner, name: $repo) {
+		stargazers(first: 100, after: $cursor, orderBy: {field: STARRED_AT, direction: ASC}) {
+			edges { starredAt }
+			pageInfo { endCursor hasNextPage }
+		}
+	}
+}`
+			stargazers := make([]map[string]interface{}, 0)
+			var cursor interface{}
+			for {
+				var data struct {
+					Repository *struct {
+						Stargazers struct {
+							Edges []struct {
+								StarredAt string `json:"starredAt"`
+							} `json:"edges"`
+							PageInfo graphQLPageInfo `json:"pageInfo"`
+						} `json:"stargazers"`
+					} `json:"repository"`
+				}
+				if httpErr := gql.query(ctx, logger, query, map[string]interface{}{
+					"cursor": cursor,
+					"owner":  owner,
+					"repo":   repo,
+				}, &data); httpErr != nil {
+					return nil, httpErr
+				}
+				if data.Repository == nil {
+					return nil, repositoryNotFound(owner, repo)
+				}
+				for _, edge := range data.Repository.Stargazers.Edges {
+					stargazers = append(stargazers, map[string]interface{}{
+						"starred_at": edge.StarredAt,
+					})
+				}
+				pageInfo := data.Repository.Stargazers.PageInfo
+				if !pageInfo.HasNextPage {
+					return stargazers, nil
+				}
+				cursor = pageInfo.EndCursor
+			}
+		},
+	)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return parseStarEvents(logger, untypedStargazers)
+}
+
+func (gql *GraphQLClient) ListIssues(ctx context.Context, logger *log.Logger, owner, repo string) ([]Issue, *errors.HttpError) {
+	ctx, cancel := gql.client.withDeadline(ctx)
+	defer cancel()
+	rawIssues, err := redisWrap(
+		ctx,
+		gql.client,
+		fmt.Sprintf("github:repo:%s:%s:issues", owner, repo),
+		"issues",
+		logger,
+		func(ctx context.Context, _ []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			ordering := "orderBy: {field: CREATED_AT, direction: ASC}"
+			issueNodes, err := gql.listIssueNodes(ctx, logger, owner, repo, "issues", ordering, 0, IssueFilter{})
+			if err != nil {
+				return nil, err
+			}
+			prNodes, err := gql.listIssueNodes(ctx, logger, owner, repo, "pullRequests", ordering, 0, IssueFilter{})
+			if err != nil {
+				return nil, err
+			}
+			issues := make([]map[string]interface{}, 0, len(issueNodes)+len(prNodes))
+			for i := range issueNodes {
+				issues = append(issues, gql.issueJson(owner, repo, &issueNodes[i], false))
+			}
+			for i := range prNodes {
+				issues = append(issues, gql.issueJson(owner, repo, &prNodes[i], true))
+			}
+			sort.Stable(byIssueNumber(issues))
+			return issues, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return parseIssues(logger, rawIssues)
+}
+
+func (gql *GraphQLClient) listTopIssues(
+	ctx context.Context,
+	logger *log.Logger,
+	cacheKey, pluralType, owner, repo, connection string,
+	limit int,
+	filter IssueFilter,
+) ([]Issue, *errors.HttpError) {
+	rawIssues, err := redisWrap(
+		ctx,
+		gql.client,
+		cacheKey,
+		pluralType,
+		logger,
+		func(ctx context.Context, _ []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			nodes, err := gql.listIssueNodes(
+				ctx,
+				logger,
+				owner,
+				repo,
+				connection,
+				"states: OPEN, orderBy: {field: CREATED_AT, direction: DESC}",
+				limit,
+				filter,
+			)
+			if err != nil {
+				return nil, err
+			}
+			issues := make([]map[string]interface{}, len(nodes))
+			for i := range nodes {
+				issues[i] = gql.issueJson(owner, repo, &nodes[i], connection == "pullRequests")
+			}
+			return issues, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return parseIssues(logger, rawIssues)
+}
+
+func (gql *GraphQLClient) ListTopIssues(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+	limit int,
+	filter IssueFilter,
+) ([]Issue, *errors.HttpError) {
+	ctx, cancel := gql.client.withDeadline(ctx)
+	defer cancel()
+	return gql.listTopIssues(
+		ctx,
+		logger,
+		fmt.Sprintf("github:repo:%s:%s:top_issues:%d%s", owner, repo, limit, filter.cacheKeySuffix()),
+		"top issues",
+		owner,
+		repo,
+		"issues",
+		limit,
+		filter,
+	)
+}
+
+func (gql *GraphQLClient) ListTopPrs(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+	limit int,
+	filter IssueFilter,
+) ([]Issue, *errors.HttpError) {
+	ctx, cancel := gql.client.withDeadline(ctx)
+	defer cancel()
+	return gql.listTopIssues(
+		ctx,
+		logger,
+		fmt.Sprintf("github:repo:%s:%s:top_prs:%d%s", owner, repo, limit, filter.cacheKeySuffix()),
+		"top PRs",
+		owner,
+		repo,
+		"pullRequests",
+		limit,
+		filter,
+	)
+}
+
+// graphQLEventId namespaces the node id of a timeline event. These events have
+// no databaseId in the GraphQL API, so their ids can never match the numeric
+// ids the REST API uses, and must not be mistaken for them.
+func graphQLEventId(nodeId string) string {
+	return "gql:" + nodeId
+}
+
+func timelineItemEvent(
+	logger *log.Logger,
+	issueNumber int,
+	item *graphQLTimelineItem,
+) (*DetailedIssueEvent, *errors.HttpError) {
+	eventType, eventIsKnown := timelineEventTypes[item.Typename]
+	if !eventIsKnown {
+		return nil, nil
+	}
+	createdAt, err := time.Parse(time.RFC3339, item.CreatedAt)
+	if err != nil {
+		logger.Printf("ERROR: %s\n", err.Error())
+		return nil, &errors.HttpError{Message: "Github API Error", Status: http.StatusBadGateway}
+	}
+	actorId := "ghost"
+	if item.Actor != nil {
+		actorId = item.Actor.Login
+	}
+	var detail interface{}
+	switch eventType {
+	case IssueMerged:
+		if item.Commit != nil {
+			detail = item.Commit.Oid
+		}
+	case IssueLabeled, IssueUnlabeled:
+		if item.Label != nil {
+			detail = map[string]interface{}{
+				"color": item.Label.Color,
+				"name":  item.Label.Name,
+			}
+		}
+	}
+	return &DetailedIssueEvent{
+		ActorId:     actorId,
+		CreatedAt:   createdAt,
+		Detail:      detail,
+		EventType:   eventType,
+		Id:          graphQLEventId(item.Id),
+		IssueNumber: issueNumber,
+	}, nil
+}
+
+// listRemainingTimeline fetches the rest of a pull request's timeline when it
+// did not fit into the page embedded in the pull request query.
+func (gql *GraphQLClient) listRemainingTimeline(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+	number int,
+	cursor string,
+) ([]graphQLTimelineItem, *errors.HttpError) {
+	query := fmt.Sprintf(`query($owner: String!, $repo: String!, $number: Int!, $cursor: String) {
+	repository(owner: $owner, name: $repo) {
+		pullRequest(number: $number) {
+			timelineItems(first: 100, after: $cursor, itemTypes: %s) { %s }
+		}
+	}
+}`, graphQLTimelineItemTypes, graphQLTimelineFields)
+
+	var items []graphQLTimelineItem
+	for {
+		var data struct {
+			Repository *struct {
+				PullRequest *struct {
+					TimelineItems graphQLTimeline `json:"timelineItems"`
+				} `json:"pullRequest"`
+			} `json:"repository"`
+		}
+		if httpErr := gql.query(ctx, logger, query, map[string]interface{}{
+			"cursor": cursor,
+			"number": number,
+			"owner":  owner,
+			"repo":   repo,
+		}, &data); httpErr != nil {
+			return nil, httpErr
+		}
+		if data.Repository == nil || data.Repository.PullRequest == nil {
+			return nil, repositoryNotFound(owner, repo)
+		}
+		timeline := data.Repository.PullRequest.TimelineItems
+		items = append(items, timeline.Nodes...)
+		if !timeline.PageInfo.HasNextPage {
+			return items, nil
+		}
+		cursor = timeline.PageInfo.EndCursor
+	}
+}
+
+type graphQLPullRequest struct {
+	Additions      int           `json:"additions"`
+	Author         *graphQLActor `json:"author"`
+	BaseRefName    string        `json:"baseRefName"`
+	ChangedFiles   int           `json:"changedFiles"`
+	ClosedAt       *string       `json:"closedAt"`
+	CreatedAt      string        `json:"createdAt"`
+	Deletions      int           `json:"deletions"`
+	IsDraft        bool          `json:"isDraft"`
+	MergedAt       *string       `json:"mergedAt"`
+	Number         int           `json:"number"`
+	ReviewRequests struct {
+		Nodes []struct {
+			RequestedReviewer *struct {
+				Login string `json:"login"`
+				Slug  string `json:"slug"`
+			} `json:"requestedReviewer"`
+		} `json:"nodes"`
+	} `json:"reviewRequests"`
+	Title     string `json:"title"`
+	UpdatedAt string `json:"updatedAt"`
+	Url       string `json:"url"`
+}
+
+// pullRequestJson converts a PR from the GraphQL API into the same shape the
+// REST client caches.
+func pullRequestJson(pr *graphQLPullRequest) map[string]interface{} {
+	optionalString := func(value *string) interface{} {
+		if value == nil {
+			return nil
+		}
+		return *value
+	}
+	login := ghostLogin
+	if pr.Author != nil {
+		login = pr.Author.Login
+	}
+	reviewers := make([]interface{}, 0)
+	teams := make([]interface{}, 0)
+	for _, request := range pr.ReviewRequests.Nodes {
+		if request.RequestedReviewer == nil {
+			continue
+		}
+		if request.RequestedReviewer.Login != "" {
+			reviewers = append(reviewers, map[string]interface{}{"login": request.RequestedReviewer.Login})
+		} else if request.RequestedReviewer.Slug != "" {
+			teams = append(teams, map[string]interface{}{"slug": request.RequestedReviewer.Slug})
+		}
+	}
+	return map[string]interface{}{
+		"additions":           float64(pr.Additions),
+		"base":                map[string]interface{}{"ref": pr.BaseRefName},
+		"changed_files":       float64(pr.ChangedFiles),
+		"closed_at":           optionalString(pr.ClosedAt),
+		"created_at":          pr.CreatedAt,
+		"deletions":           float64(pr.Deletions),
+		"draft":               pr.IsDraft,
+		"html_url":            pr.Url,
+		"merged_at":           optionalString(pr.MergedAt),
+		"number":              float64(pr.Number),
+		"requested_reviewers": reviewers,
+		"requested_teams":     teams,
+		"title":               pr.Title,
+		"updated_at":          pr.UpdatedAt,
+		"user":                map[string]interface{}{"login": login},
+	}
+}
+
+func (gql *GraphQLClient) ListPullRequests(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]PullRequest, *errors.HttpError) {
+	ctx, cancel := gql.client.withDeadline(ctx)
+	defer cancel()
+	rawPulls, httpErr := redisWrap(
+		ctx,
+		gql.client,
+		pullRequestsKey(owner, repo),
+		"pull requests",
+		logger,
+		func(ctx context.Context, _ []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			query := `query($owner: String!, $repo: String!, $cursor: String) {
+	repository(owner: $owner, name: $repo) {
+		pullRequests(first: 100, after: $cursor, orderBy: {field: CREATED_AT, direction: ASC}) {
+			nodes {
+				number title url createdAt closedAt mergedAt updatedAt isDraft
+				additions deletions changedFiles baseRefName author { login }
+				reviewRequests(first: 20) {
+					nodes { requestedReviewer { ... on User { login } ... on Team { slug } } }
+				}
+			}
+			pageInfo { endCursor hasNextPage }
+		}
+	}
+}`
+			pulls := make([]map[string]interface{}, 0)
+			var cursor interface{}
+			for {
+				var data struct {
+					Repository *struct {
+						PullRequests struct {
+							Nodes    []graphQLPullRequest `json:"nodes"`
+							PageInfo graphQLPageInfo      `json:"pageInfo"`
+						} `json:"pullRequests"`
+					} `json:"repository"`
+				}
+				if httpErr := gql.query(ctx, logger, query, map[string]interface{}{
+					"cursor": cursor,
+					"owner":  owner,
+					"repo":   repo,
+				}, &data); httpErr != nil {
+					return nil, httpErr
+				}
+				if data.Repository == nil {
+					return nil, repositoryNotFound(owner, repo)
+				}
+				for i := range data.Repository.PullRequests.Nodes {
+					pulls = append(pulls, pullRequestJson(&data.Repository.PullRequests.Nodes[i]))
+				}
+				pageInfo := data.Repository.PullRequests.PageInfo
+				if !pageInfo.HasNextPage {
+					return pulls, nil
+				}
+				cursor = pageInfo.EndCursor
+			}
+		},
+	)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return parsePullRequests(logger, rawPulls)
+}
+
+type graphQLCommit struct {
+	Author *struct {
+		Name string        `json:"name"`
+		User *graphQLActor `json:"user"`
+	} `json:"author"`
+	CommittedDate   string `json:"committedDate"`
+	MessageHeadline string `json:"messageHeadline"`
+	Oid             string `json:"oid"`
+}
+
+// commitJson converts a commit from the GraphQL API into the same shape the
+// REST client caches.
+func commitJson(commit *graphQLCommit) map[string]interface{} {
+	var author interface{}
+	gitAuthor := map[string]interface{}{}
+	if commit.Author != nil {
+		gitAuthor["name"] = commit.Author.Name
+		if commit.Author.User != nil {
+			author = map[string]interface{}{"login": commit.Author.User.Login}
+		}
+	}
+	return map[string]interface{}{
+		"author": author,
+		"commit": map[string]interface{}{
+			"author":    gitAuthor,
+			"committer": map[string]interface{}{"date": commit.CommittedDate},
+			"message":   commit.MessageHeadline,
+		},
+		"sha": commit.Oid,
+	}
+}
+
+func (gql *GraphQLClient) ListCommits(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]Commit, *errors.HttpError) {
+	ctx, cancel := gql.client.withDeadline(ctx)
+	defer cancel()
+	rawCommits, httpErr := redisWrap(
+		ctx,
+		gql.client,
+		commitsKey(owner, repo),
+		"commits",
+		logger,
+		func(ctx context.Context, stale []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			query := `query($owner: String!, $repo: String!, $cursor: String) {
+	repository(owner: $owner, name: $repo) {
+		defaultBranchRef {
+			target {
+				... on Commit {
+					history(first: 100, after: $cursor) {
+						nodes { oid messageHeadline committedDate author { name user { login } } }
+						pageInfo { endCursor hasNextPage }
+					}
+				}
+			}
+		}
+	}
+}`
+			var cursor interface{}
+			return newCommitsSince(stale, func() ([]map[string]interface{}, bool, *errors.HttpError) {
+				var data struct {
+					Repository *struct {
+						DefaultBranchRef *struct {
+							Target struct {
+								History struct {
+									Nodes    []graphQLCommit `json:"nodes"`
+									PageInfo graphQLPageInfo `json:"pageInfo"`
+								} `json:"history"`
+							} `json:"target"`
+						} `json:"defaultBranchRef"`
+					} `json:"repository"`
+				}
+				if httpErr := gql.query(ctx, logger, query, map[string]interface{}{
+					"cursor": cursor,
+					"owner":  owner,
+					"repo":   repo,
+				}, &data); httpErr != nil {
+					return nil, false, httpErr
+				}
+				if data.Repository == nil {
+					return nil, false, repositoryNotFound(owner, repo)
+				}
+				// Empty repos do not have a default branch yet.
+				if data.Repository.DefaultBranchRef == nil {
+					return nil, false, nil
+				}
+				history := data.Repository.DefaultBranchRef.Target.History
+				page := make([]map[string]interface{}, len(history.Nodes))
+				for i := range history.Nodes {
+					page[i] = commitJson(&history.Nodes[i])
+				}
+				cursor = history.PageInfo.EndCursor
+				return page, history.PageInfo.HasNextPage, nil
+			})
+		},
+	)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return parseCommits(logger, rawCommits)
+}
+
+type graphQLRelease struct {
+	IsDraft       bool    `json:"isDraft"`
+	IsPrerelease  bool    `json:"isPrerelease"`
+	Name          *string `json:"name"`
+	PublishedAt   *string `json:"publishedAt"`
+	ReleaseAssets struct {
+		Nodes []struct {
+			DownloadCount int `json:"downloadCount"`
+		} `json:"nodes"`
+	} `json:"releaseAssets"`
+	TagName string `json:"tagName"`
+	Url     string `json:"url"`
+}
+
+// releaseJson converts a release from the GraphQL API into the same shape the
+// REST client caches.
+func releaseJson(release *graphQLRelease) map[string]interface{} {
+	assets := make([]interface{}, len(release.ReleaseAssets.Nodes))
+	for i, asset := range release.ReleaseAssets.Nodes {
+		assets[i] = map[string]interface{}{"download_count": float64(asset.DownloadCount)}
+	}
+	var name, publishedAt interface{}
+	if release.Name != nil {
+		name = *release.Name
+	}
+	if release.PublishedAt != nil {
+		publishedAt = *release.PublishedAt
+	}
+	return map[string]interface{}{
+		"assets":       assets,
+		"draft":        release.IsDraft,
+		"html_url":     release.Url,
+		"name":         name,
+		"prerelease":   release.IsPrerelease,
+		"published_at": publishedAt,
+		"tag_name":     release.TagName,
+	}
+}
+
+func (gql *GraphQLClient) ListReleases(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]Release, *errors.HttpError) {
+	ctx, cancel := gql.client.withDeadline(ctx)
+	defer cancel()
+	rawReleases, httpErr := redisWrap(
+		ctx,
+		gql.client,
+		releasesKey(owner, repo),
+		"releases",
+		logger,
+		func(ctx context.Context, _ []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			query := `query($owner: String!, $repo: String!, $cursor: String) {
+	repository(owner: $owner, name: $repo) {
+		releases(first: 100, after: $cursor, orderBy: {field: CREATED_AT, direction: DESC}) {
+			nodes {
+				name tagName url isDraft isPrerelease publishedAt
+				releaseAssets(first: 100) { nodes { downloadCount } }
+			}
+			pageInfo { endCursor hasNextPage }
+		}
+	}
+}`
+			releases := make([]map[string]interface{}, 0)
+			var cursor interface{}
+			for {
+				var data struct {
+					Repository *struct {
+						Releases struct {
+							Nodes    []graphQLRelease `json:"nodes"`
+							PageInfo graphQLPageInfo  `json:"pageInfo"`
+						} `json:"releases"`
+					} `json:"repository"`
+				}
+				if httpErr := gql.query(ctx, logger, query, map[string]interface{}{
+					"cursor": cursor,
+					"owner":  owner,
+					"repo":   repo,
+				}, &data); httpErr != nil {
+					return nil, httpErr
+				}
+				if data.Repository == nil {
+					return nil, repositoryNotFound(owner, repo)
+				}
+				for i := range data.Repository.Releases.Nodes {
+					releases = append(releases, releaseJson(&data.Repository.Releases.Nodes[i]))
+				}
+				pageInfo := data.Repository.Releases.PageInfo
+				if !pageInfo.HasNextPage {
+					return releases, nil
+				}
+				cursor = pageInfo.EndCursor
+			}
+		},
+	)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return parseReleases(logger, rawReleases)
+}
+
+func (gql *GraphQLClient) ListTags(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]Tag, *errors.HttpError) {
+	ctx, cancel := gql.client.withDeadline(ctx)
+	defer cancel()
+	rawTags, httpErr := redisWrap(
+		ctx,
+		gql.client,
+		tagsKey(owner, repo),
+		"tags",
+		logger,
+		func(ctx context.Context, _ []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			// Annotated tags point at a tag object rather than a commit, so
+			// follow them through to the commit like the REST API does.
+			query := `query($owner: String!, $repo: String!, $cursor: String) {
+	repository(owner: $owner, name: $repo) {
+		refs(refPrefix: "refs/tags/", first: 100, after: $cursor) {
+			nodes { name target { oid ... on Tag { target { oid } } } }
+			pageInfo { endCursor hasNextPage }
+		}
+	}
+}`
+			tags := make([]map[string]interface{}, 0)
+			var cursor interface{}
+			for {
+				var data struct {
+					Repository *struct {
+						Refs struct {
+							Nodes []struct {
+								Name   string `json:"name"`
+								Target struct {
+									Oid    string `json:"oid"`
+									Target *struct {
+										Oid string `json:"oid"`
+									} `json:"target"`
+								} `json:"target"`
+							} `json:"nodes"`
+							PageInfo graphQLPageInfo `json:"pageInfo"`
+						} `json:"refs"`
+					} `json:"repository"`
+				}
+				if httpErr := gql.query(ctx, logger, query, map[string]interface{}{
+					"cursor": cursor,
+					"owner":  owner,
+					"repo":   repo,
+				}, &data); httpErr != nil {
+					return nil, httpErr
+				}
+				if data.Repository == nil {
+					return nil, repositoryNotFound(owner, repo)
+				}
+				for _, ref := range data.Repository.Refs.Nodes {
+					sha := ref.Target.Oid
+					if ref.Target.Target != nil {
+						sha = ref.Target.Target.Oid
+					}
+					tags = append(tags, map[string]interface{}{
+						"commit": map[string]interface{}{"sha": sha},
+						"name":   ref.Name,
+					})
+				}
+				pageInfo := data.Repository.Refs.PageInfo
+				if !pageInfo.HasNextPage {
+					return tags, nil
+				}
+				cursor = pageInfo.EndCursor
+			}
+		},
+	)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return parseTags(logger, rawTags)
+}
+
+func (gql *GraphQLClient) ListForks(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]ForkEvent, *errors.HttpError) {
+	ctx, cancel := gql.client.withDeadline(ctx)
+	defer cancel()
+	rawForks, httpErr := redisWrap(
+		ctx,
+		gql.client,
+		forksKey(owner, repo),
+		"forks",
+		logger,
+		func(ctx context.Context, _ []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			query := `query($owner: String!, $repo: String!, $cursor: String) {
+	repository(owner: $owner, name: $repo) {
+		forks(first: 100, after: $cursor, orderBy: {field: CREATED_AT, direction: ASC}) {
+			nodes { createdAt }
+			pageInfo { endCursor hasNextPage }
+		}
+	}
+}`
+			forks := make([]map[string]interface{}, 0)
+			var cursor interface{}
+			for {
+				var data struct {
+					Repository *struct {
+						Forks struct {
+							Nodes []struct {
+								CreatedAt string `json:"createdAt"`
+							} `json:"nodes"`
+							PageInfo graphQLPageInfo `json:"pageInfo"`
+						} `json:"forks"`
+					} `json:"repository"`
+				}
+				if httpErr := gql.query(ctx, logger, query, map[string]interface{}{
+					"cursor": cursor,
+					"owner":  owner,
+					"repo":   repo,
+				}, &data); httpErr != nil {
+					return nil, httpErr
+				}
+				if data.Repository == nil {
+					return nil, repositoryNotFound(owner, repo)
+				}
+				for _, fork := range data.Repository.Forks.Nodes {
+					forks = append(forks, map[string]interface{}{"created_at": fork.CreatedAt})
+				}
+				pageInfo := data.Repository.Forks.PageInfo
+				if !pageInfo.HasNextPage {
+					return forks, nil
+				}
+				cursor = pageInfo.EndCursor
+			}
+		},
+	)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return parseForkEvents(logger, rawForks)
+}
+
+func (gql *GraphQLClient) GetWatcherCount(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) (int, *errors.HttpError) {
+	ctx, cancel := gql.client.withDeadline(ctx)
+	defer cancel()
+	var data struct {
+		Repository *struct {
+			Watchers struct {
+				TotalCount int `json:"totalCount"`
+			} `json:"watchers"`
+		} `json:"repository"`
+	}
+	if httpErr := gql.query(ctx, logger, `query($owner: String!, $repo: String!) {
+	repository(owner: $owner, name: $repo) { watchers { totalCount } }
+}`, map[string]interface{}{
+		"owner": owner,
+		"repo":  repo,
+	}, &data); httpErr != nil {
+		return 0, httpErr
+	}
+	if data.Repository == nil {
+		return 0, repositoryNotFound(owner, repo)
+	}
+	return data.Repository.Watchers.TotalCount, nil
+}
+
+func ownerNotFound(owner string) *errors.HttpError {
+	return &errors.HttpError{
+		Code:    errors.CodeNotFound,
+		Message: fmt.Sprintf("Owner %s Not Found", owner),
+		Status:  http.StatusNotFound,
+	}
+}
+
+func (gql *GraphQLClient) ListRepositories(
+	ctx context.Context,
+	logger *log.Logger,
+	owner string,
+) ([]Repository, *errors.HttpError) {
+	ctx, cancel := gql.client.withDeadline(ctx)
+	defer cancel()
+	rawRepositories, httpErr := redisWrap(
+		ctx,
+		gql.client,
+		repositoriesKey(owner),
+		"repositories",
+		logger,
+		func(ctx context.Context, _ []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			// repositoryOwner resolves both organizations and users.
+			query := `query($owner: String!, $cursor: String) {
+	repositoryOwner(login: $owner) {
+		repositories(first: 100, after: $cursor, ownerAffiliations: [OWNER], orderBy: {field: NAME, direction: ASC}) {
+			nodes { name isArchived isFork owner { login } }
+			pageInfo { endCursor hasNextPage }
+		}
+	}
+}`
+			repositories := make([]map[string]interface{}, 0)
+			var cursor interface{}
+			for {
+				var data struct {
+					RepositoryOwner *struct {
+						Repositories struct {
+							Nodes []struct {
+								IsArchived bool         `json:"isArchived"`
+								IsFork     bool         `json:"isFork"`
+								Name       string       `json:"name"`
+								Owner      *userPayload `json:"owner"`
+							} `json:"nodes"`
+							PageInfo graphQLPageInfo `json:"pageInfo"`
+						} `json:"repositories"`
+					} `json:"repositoryOwner"`
+				}
+				if httpErr := gql.query(ctx, logger, query, map[string]interface{}{
+					"cursor": cursor,
+					"owner":  owner,
+				}, &data); httpErr != nil {
+					return nil, httpErr
+				}
+				if data.RepositoryOwner == nil {
+					return nil, ownerNotFound(owner)
+				}
+				for _, repository := range data.RepositoryOwner.Repositories.Nodes {
+					repositories = append(repositories, map[string]interface{}{
+						"archived": repository.IsArchived,
+						"fork":     repository.IsFork,
+						"name":     repository.Name,
+						"owner":    map[string]interface{}{"login": repository.Owner.login()},
+					})
+				}
+				pageInfo := data.RepositoryOwner.Repositories.PageInfo
+				if !pageInfo.HasNextPage {
+					return repositories, nil
+				}
+				cursor = pageInfo.EndCursor
+			}
+		},
+	)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return parseRepositories(logger, rawRepositories)
+}
+
+func (gql *GraphQLClient) ListAllPrEvents(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]DetailedIssueEvent, *errors.HttpError) {
+	ctx, cancel := gql.client.withDeadline(ctx)
+	defer cancel()
+	query := fmt.Sprintf(`query($owner: String!, $repo: String!, $cursor: String) {
+	repository(owner: $owner, name: $repo) {
+		pullRequests(first: 50, after: $cursor, orderBy: {field: CREATED_AT, direction: ASC}) {
+			nodes {
+				%s
+				timelineItems(first: 100, itemTypes: %s) { %s }
+			}
+			pageInfo { endCursor hasNextPage }
+		}
+	}
+}`, graphQLIssueFields, graphQLTimelineItemTypes, graphQLTimelineFields)
+
+	var detailedEvents []DetailedIssueEvent
+	var cursor interface{}
+	for {
+		var data struct {
+			Repository *struct {
+				PullRequests struct {
+					Nodes []struct {
+						graphQLIssue
+						TimelineItems graphQLTimeline `json:"timelineItems"`
+					} `json:"nodes"`
+					PageInfo graphQLPageInfo `json:"pageInfo"`
+				} `json:"pullRequests"`
+			} `json:"repository"`
+		}
+		if httpErr := gql.query(ctx, logger, query, map[string]interface{}{
+			"cursor": cursor,
+			"owner":  owner,
+			"repo":   repo,
+		}, &data); httpErr != nil {
+			return nil, httpErr
+		}
+		if data.Repository == nil {
+			return nil, repositoryNotFound(owner, repo)
+		}
+		for _, pr := range data.Repository.PullRequests.Nodes {
+			issue := Issue{}
+			if httpErr := parseIssue(
+				logger,
+				&issue,
+				gql.issueJson(owner, repo, &pr.graphQLIssue, true),
+			); httpErr != nil {
+				return nil, httpErr
+			}
+			detailedEvents = append(detailedEvents, DetailedIssueEvent{
+				ActorId:     issue.Submitter,
+				CreatedAt:   issue.CreatedAt,
+				EventType:   IssueCreated,
+				Id:          fmt.Sprintf("cr%d", issue.Number),
+				IssueNumber: issue.Number,
+			})
+			timelineItems := pr.TimelineItems.Nodes
+			if pr.TimelineItems.PageInfo.HasNextPage {
+				remainingItems, httpErr := gql.listRemainingTimeline(
+					ctx,
+					logger,
+					owner,
+					repo,
+					pr.Number,
+					pr.TimelineItems.PageInfo.EndCursor,
+				)
+				if httpErr != nil {
+					return nil, httpErr
+				}
+				timelineItems = append(timelineItems, remainingItems...)
+			}
+			for i := range timelineItems {
+				event, httpErr := timelineItemEvent(logger, issue.Number, &timelineItems[i])
+				if httpErr != nil {
+					return nil, httpErr
+				}
+				if event != nil {
+					detailedEvents = append(detailedEvents, *event)
+				}
+			}
+		}
+		pageInfo := data.Repository.PullRequests.PageInfo
+		if !pageInfo.HasNextPage {
+			return detailedEvents, nil
+		}
+		cursor = pageInfo.EndCursor
+	}
+}
+
+// GetTraffic goes through the REST API, since traffic is not part of the
+// GraphQL schema.
+func (gql *GraphQLClient) GetTraffic(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) (*Traffic, *errors.HttpError) {
+	return gql.client.GetTraffic(ctx, logger, owner, repo)
+}
+
+// ListContributorStats goes through the REST API, since repository
+// statistics are not part of the GraphQL schema.
+func (gql *GraphQLClient) ListContributorStats(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]ContributorStats, *errors.HttpError) {
+	return gql.client.ListContributorStats(ctx, logger, owner, repo)
+}
+
+func (gql *GraphQLClient) ListCodeFrequency(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]CodeFrequency, *errors.HttpError) {
+	return gql.client.ListCodeFrequency(ctx, logger, owner, repo)
+}
+
+func (gql *GraphQLClient) GetRateLimit(ctx context.Context, logger *log.Logger) (*RateLimit, *errors.HttpError) {
+	ctx, cancel := gql.client.withDeadline(ctx)
+	defer cancel()
+	var data struct {
+		RateLimit struct {
+			Limit     int    `json:"limit"`
+			Remaining int    `json:"remaining"`
+			ResetAt   string `json:"resetAt"`
+		} `json:"rateLimit"`
+	}
+	if httpErr := gql.query(
+		ctx,
+		logger,
+		`query { rateLimit { limit remaining resetAt } }`,
+		map[string]interface{}{},
+		&data,
+	); httpErr != nil {
+		return nil, httpErr
+	}
+	resetAt, err := time.Parse(time.RFC3339, data.RateLimit.ResetAt)
+	if err != nil {
+		logger.Printf("ERROR: %s\n", err.Error())
+		return nil, &errors.HttpError{Message: "Github API Error", Status: http.StatusBadGateway}
+	}
+	rateLimit := RateLimit{
+		Limit:     data.RateLimit.Limit,
+		Remaining: data.RateLimit.Remaining,
+		Reset:     resetAt,
+	}
+	gql.client.recordRateLimit(rateLimit)
+	return &rateLimit, nil
+}
@@ -0,0 +1,446 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/mocks"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+// newGraphQLServer starts a stand-in for the Github GraphQL API. The respond
+// function receives each decoded request and returns the JSON document to
+// send back.
+func newGraphQLServer(t *testing.T, respond func(*graphQLRequest) string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/graphql", r.URL.Path)
+		assert.Equal(t, "token deadbeef", r.Header.Get("Authorization"))
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		var req graphQLRequest
+		assert.NoError(t, json.Unmarshal(body, &req))
+		fmt.Fprintln(w, respond(&req))
+	}))
+}
+
+func newTestGraphQLClient(ts *httptest.Server) *GraphQLClient {
+	return NewGraphQLClient(&Options{
+		BaseUrl:    ts.URL,
+		GraphQLUrl: fmt.Sprintf("%s/graphql", ts.URL),
+		Token:      "deadbeef",
+	})
+}
+
+func TestGraphQLListStarEvents(t *testing.T) {
+	t.Parallel()
+
+	ts := newGraphQLServer(t, func(req *graphQLRequest) string {
+		assert.Contains(t, req.Query, "stargazers(")
+		assert.Equal(t, "angular", req.Variables["owner"])
+		if req.Variables["cursor"] == nil {
+			return `{"data":{"repository":{"stargazers":{
+				"edges":[{"starredAt":"2016-03-07T03:23:53Z"},{"starredAt":"2016-03-07T03:25:41Z"}],
+				"pageInfo":{"endCursor":"c1","hasNextPage":true}}}}}`
+		}
+		assert.Equal(t, "c1", req.Variables["cursor"])
+		return `{"data":{"repository":{"stargazers":{
+			"edges":[{"starredAt":"2016-03-07T03:26:14Z"}],
+			"pageInfo":{"endCursor":"c2","hasNextPage":false}}}}}`
+	})
+	defer ts.Close()
+
+	starEvents, err := newTestGraphQLClient(ts).ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+	assert.NoError(t, err)
+	assert.Len(t, starEvents, 3)
+	assert.True(t, starEvents[1].StarredAt.Before(starEvents[2].StarredAt))
+}
+
+func TestGraphQLListIssues(t *testing.T) {
+	t.Parallel()
+
+	ts := newGraphQLServer(t, func(req *graphQLRequest) string {
+		if strings.Contains(req.Query, "connection: issues(") {
+			return `{"data":{"repository":{"connection":{
+				"nodes":[
+					{"number":1,"title":"Test 1","createdAt":"2016-03-07T03:26:14Z","closedAt":null,
+					 "url":"https://github.com/lodash/lodash/issues/1","author":{"login":"tester1"}},
+					{"number":3,"title":"Test 3","createdAt":"2016-03-08T03:26:14Z","closedAt":"2016-03-09T03:26:14Z",
+					 "url":"https://github.com/lodash/lodash/issues/3","author":null}],
+				"pageInfo":{"endCursor":"c1","hasNextPage":false}}}}}`
+		}
+		assert.Contains(t, req.Query, "connection: pullRequests(")
+		return `{"data":{"repository":{"connection":{
+			"nodes":[
+				{"number":2,"title":"PR 2","createdAt":"2016-03-07T04:26:14Z","closedAt":null,
+				 "url":"https://github.com/lodash/lodash/pull/2","author":{"login":"tester2"}}],
+			"pageInfo":{"endCursor":"c1","hasNextPage":false}}}}}`
+	})
+	defer ts.Close()
+
+	allIssues, err := newTestGraphQLClient(ts).ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, allIssues, 3)
+	assert.Equal(t, 1, allIssues[0].Number)
+	assert.False(t, allIssues[0].IsPr)
+	assert.Equal(t, 2, allIssues[1].Number)
+	assert.True(t, allIssues[1].IsPr)
+	assert.Equal(t, "tester2", allIssues[1].Submitter)
+	assert.True(t, allIssues[2].IsClosed)
+	assert.Equal(t, "ghost", allIssues[2].Submitter)
+	assert.Equal(t, fmt.Sprintf("%s/repos/lodash/lodash/issues/3/events", ts.URL), allIssues[2].EventsUrl)
+}
+
+func TestGraphQLListTopPrs(t *testing.T) {
+	t.Parallel()
+
+	ts := newGraphQLServer(t, func(req *graphQLRequest) string {
+		assert.Contains(t, req.Query, "connection: pullRequests(")
+		assert.Contains(t, req.Query, "states: OPEN")
+		assert.Equal(t, 2.0, req.Variables["first"])
+		return `{"data":{"repository":{"connection":{
+			"nodes":[
+				{"number":9,"title":"PR 9","createdAt":"2016-03-09T03:26:14Z","closedAt":null,
+				 "url":"https://github.com/lodash/lodash/pull/9","author":{"login":"tester1"}},
+				{"number":8,"title":"PR 8","createdAt":"2016-03-08T03:26:14Z","closedAt":null,
+				 "url":"https://github.com/lodash/lodash/pull/8","author":{"login":"tester1"}}],
+			"pageInfo":{"endCursor":"c1","hasNextPage":true}}}}}`
+	})
+	defer ts.Close()
+
+	topPrs, err := newTestGraphQLClient(ts).ListTopPrs(context.Background(), mocks.DummyLogger(t), "lodash", "lodash", 2, IssueFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, topPrs, 2)
+	assert.Equal(t, "PR 9", topPrs[0].Title)
+	assert.True(t, topPrs[0].IsPr)
+}
+
+func TestGraphQLListTopIssuesFilter(t *testing.T) {
+	t.Parallel()
+
+	ts := newGraphQLServer(t, func(req *graphQLRequest) string {
+		assert.Contains(t, req.Query, "labels(first: 50)")
+		assert.Equal(t, 100.0, req.Variables["first"])
+		return `{"data":{"repository":{"connection":{
+			"nodes":[
+				{"number":9,"title":"Issue 9","createdAt":"2016-03-09T03:26:14Z","closedAt":null,
+				 "url":"https://github.com/lodash/lodash/issues/9","author":{"login":"tester1"},
+				 "labels":{"nodes":[{"name":"docs","color":"0000ff"}]},"assignees":{"nodes":[]},
+				 "comments":{"totalCount":0},"milestone":null},
+				{"number":8,"title":"Issue 8","createdAt":"2016-03-08T03:26:14Z","closedAt":null,
+				 "url":"https://github.com/lodash/lodash/issues/8","author":{"login":"tester1"},
+				 "labels":{"nodes":[{"name":"bug","color":"fc2929"}]},"assignees":{"nodes":[{"login":"tester2"}]},
+				 "comments":{"totalCount":4},"milestone":{"title":"v1.0"}}],
+			"pageInfo":{"endCursor":"c1","hasNextPage":false}}}}}`
+	})
+	defer ts.Close()
+
+	topIssues, err := newTestGraphQLClient(ts).ListTopIssues(
+		context.Background(),
+		mocks.DummyLogger(t),
+		"lodash",
+		"lodash",
+		2,
+		IssueFilter{Label: "bug"},
+	)
+	assert.NoError(t, err)
+	assert.Len(t, topIssues, 1)
+	assert.Equal(t, 8, topIssues[0].Number)
+	assert.Equal(t, []string{"tester2"}, topIssues[0].Assignees)
+	assert.Equal(t, 4, topIssues[0].Comments)
+	assert.Equal(t, "v1.0", topIssues[0].Milestone)
+}
+
+func TestGraphQLListPullRequests(t *testing.T) {
+	t.Parallel()
+
+	ts := newGraphQLServer(t, func(req *graphQLRequest) string {
+		assert.Contains(t, req.Query, "pullRequests(")
+		assert.Contains(t, req.Query, "changedFiles")
+		return `{"data":{"repository":{"pullRequests":{
+			"nodes":[{
+				"number":7,"title":"PR 7","url":"https://github.com/lodash/lodash/pull/7",
+				"createdAt":"2016-03-16T22:20:00Z","closedAt":"2016-03-17T22:20:00Z",
+				"mergedAt":"2016-03-17T22:20:00Z","updatedAt":"2016-03-17T22:20:00Z",
+				"isDraft":false,"additions":12,"deletions":3,"changedFiles":2,"baseRefName":"main",
+				"author":null,
+				"reviewRequests":{"nodes":[{"requestedReviewer":{"login":"tester2"}},{"requestedReviewer":null}]}
+			}],
+			"pageInfo":{"endCursor":"p1","hasNextPage":false}}}}}`
+	})
+	defer ts.Close()
+
+	pulls, err := newTestGraphQLClient(ts).ListPullRequests(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, pulls, 1)
+	assert.Equal(t, 12, pulls[0].Additions)
+	assert.Equal(t, 3, pulls[0].Deletions)
+	assert.Equal(t, 2, pulls[0].ChangedFiles)
+	assert.Equal(t, "main", pulls[0].BaseBranch)
+	assert.True(t, pulls[0].IsMerged)
+	assert.Equal(t, "ghost", pulls[0].Submitter)
+	assert.Equal(t, []string{"tester2"}, pulls[0].RequestedReviewers)
+}
+
+func TestGraphQLListCommits(t *testing.T) {
+	t.Parallel()
+
+	ts := newGraphQLServer(t, func(req *graphQLRequest) string {
+		assert.Contains(t, req.Query, "defaultBranchRef")
+		if req.Variables["cursor"] == nil {
+			return `{"data":{"repository":{"defaultBranchRef":{"target":{"history":{
+				"nodes":[{"oid":"c2","messageHeadline":"Fix the build","committedDate":"2016-03-08T03:26:14Z",
+					"author":{"name":"Git Name","user":{"login":"tester1"}}}],
+				"pageInfo":{"endCursor":"h1","hasNextPage":true}}}}}}}`
+		}
+		assert.Equal(t, "h1", req.Variables["cursor"])
+		return `{"data":{"repository":{"defaultBranchRef":{"target":{"history":{
+			"nodes":[{"oid":"c1","messageHeadline":"Initial commit","committedDate":"2016-03-07T03:26:14Z",
+				"author":{"name":"Git Name","user":null}}],
+			"pageInfo":{"endCursor":"h2","hasNextPage":false}}}}}}}`
+	})
+	defer ts.Close()
+
+	commits, err := newTestGraphQLClient(ts).ListCommits(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, commits, 2)
+	assert.Equal(t, "c2", commits[0].Sha)
+	assert.Equal(t, "tester1", commits[0].Author)
+	assert.Equal(t, "Fix the build", commits[0].Headline)
+	assert.Equal(t, "Git Name", commits[1].Author)
+	assert.Equal(t, time.Date(2016, 3, 7, 3, 26, 14, 0, time.UTC), commits[1].CommittedAt.UTC())
+}
+
+func TestGraphQLListCommitsEmptyRepo(t *testing.T) {
+	t.Parallel()
+
+	ts := newGraphQLServer(t, func(req *graphQLRequest) string {
+		return `{"data":{"repository":{"defaultBranchRef":null}}}`
+	})
+	defer ts.Close()
+
+	commits, err := newTestGraphQLClient(ts).ListCommits(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, commits, 0)
+}
+
+func TestGraphQLListReleases(t *testing.T) {
+	t.Parallel()
+
+	ts := newGraphQLServer(t, func(req *graphQLRequest) string {
+		assert.Contains(t, req.Query, "releases(")
+		return `{"data":{"repository":{"releases":{
+			"nodes":[{
+				"name":null,"tagName":"v1.1.0","url":"https://github.com/lodash/lodash/releases/tag/v1.1.0",
+				"isDraft":false,"isPrerelease":false,"publishedAt":"2016-04-01T12:00:00Z",
+				"releaseAssets":{"nodes":[{"downloadCount":5},{"downloadCount":7}]}
+			},{
+				"name":"Upcoming","tagName":"v2.0.0","url":"https://github.com/lodash/lodash/releases/tag/v2.0.0",
+				"isDraft":true,"isPrerelease":false,"publishedAt":null,
+				"releaseAssets":{"nodes":[]}
+			}],
+			"pageInfo":{"endCursor":"r1","hasNextPage":false}}}}}`
+	})
+	defer ts.Close()
+
+	releases, err := newTestGraphQLClient(ts).ListReleases(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, releases, 2)
+	assert.Equal(t, "v1.1.0", releases[0].Name)
+	assert.Equal(t, 12, releases[0].AssetDownloads)
+	assert.Equal(t, time.Date(2016, 4, 1, 12, 0, 0, 0, time.UTC), releases[0].PublishedAt.UTC())
+	assert.True(t, releases[1].IsDraft)
+	assert.True(t, releases[1].PublishedAt.IsZero())
+}
+
+func TestGraphQLListTags(t *testing.T) {
+	t.Parallel()
+
+	ts := newGraphQLServer(t, func(req *graphQLRequest) string {
+		assert.Contains(t, req.Query, "refs/tags/")
+		return `{"data":{"repository":{"refs":{
+			"nodes":[
+				{"name":"v1.1.0","target":{"oid":"tagobject","target":{"oid":"deadbeef"}}},
+				{"name":"v1.0.0","target":{"oid":"cafebabe"}}
+			],
+			"pageInfo":{"endCursor":"t1","hasNextPage":false}}}}}`
+	})
+	defer ts.Close()
+
+	tags, err := newTestGraphQLClient(ts).ListTags(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Equal(t, []Tag{Tag{Name: "v1.1.0", Sha: "deadbeef"}, Tag{Name: "v1.0.0", Sha: "cafebabe"}}, tags)
+}
+
+func TestGraphQLListForks(t *testing.T) {
+	t.Parallel()
+
+	ts := newGraphQLServer(t, func(req *graphQLRequest) string {
+		assert.Contains(t, req.Query, "forks(")
+		return `{"data":{"repository":{"forks":{
+			"nodes":[{"createdAt":"2016-03-07T03:26:14Z"},{"createdAt":"2016-03-08T03:26:14Z"}],
+			"pageInfo":{"endCursor":"f1","hasNextPage":false}}}}}`
+	})
+	defer ts.Close()
+
+	forkEvents, err := newTestGraphQLClient(ts).ListForks(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, forkEvents, 2)
+	assert.Equal(t, time.Date(2016, 3, 8, 3, 26, 14, 0, time.UTC), forkEvents[1].ForkedAt.UTC())
+}
+
+func TestGraphQLGetWatcherCount(t *testing.T) {
+	t.Parallel()
+
+	ts := newGraphQLServer(t, func(req *graphQLRequest) string {
+		assert.Contains(t, req.Query, "watchers")
+		return `{"data":{"repository":{"watchers":{"totalCount":7}}}}`
+	})
+	defer ts.Close()
+
+	watchers, err := newTestGraphQLClient(ts).GetWatcherCount(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Equal(t, 7, watchers)
+}
+
+func TestGraphQLListRepositories(t *testing.T) {
+	t.Parallel()
+
+	ts := newGraphQLServer(t, func(req *graphQLRequest) string {
+		assert.Contains(t, req.Query, "repositoryOwner(")
+		if req.Variables["cursor"] == nil {
+			return `{"data":{"repositoryOwner":{"repositories":{
+				"nodes":[{"name":"lodash","isArchived":false,"isFork":false,"owner":{"login":"lodash"}}],
+				"pageInfo":{"endCursor":"r1","hasNextPage":true}}}}}`
+		}
+		assert.Equal(t, "r1", req.Variables["cursor"])
+		return `{"data":{"repositoryOwner":{"repositories":{
+			"nodes":[{"name":"lodash-cli","isArchived":true,"isFork":false,"owner":{"login":"lodash"}}],
+			"pageInfo":{"endCursor":"r2","hasNextPage":false}}}}}`
+	})
+	defer ts.Close()
+
+	repositories, err := newTestGraphQLClient(ts).ListRepositories(context.Background(), mocks.DummyLogger(t), "lodash")
+	assert.NoError(t, err)
+	assert.Equal(t, []Repository{
+		Repository{Name: "lodash", Owner: "lodash"},
+		Repository{Name: "lodash-cli", Owner: "lodash", IsArchived: true},
+	}, repositories)
+}
+
+func TestGraphQLListRepositoriesOwnerNotFound(t *testing.T) {
+	t.Parallel()
+
+	ts := newGraphQLServer(t, func(req *graphQLRequest) string {
+		return `{"data":{"repositoryOwner":null}}`
+	})
+	defer ts.Close()
+
+	_, err := newTestGraphQLClient(ts).ListRepositories(context.Background(), mocks.DummyLogger(t), "nobody")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, err.Status)
+}
+
+func TestGraphQLListAllPrEvents(t *testing.T) {
+	t.Parallel()
+
+	ts := newGraphQLServer(t, func(req *graphQLRequest) string {
+		if strings.Contains(req.Query, "pullRequest(number: $number)") {
+			assert.Equal(t, 7.0, req.Variables["number"])
+			assert.Equal(t, "t1", req.Variables["cursor"])
+			return `{"data":{"repository":{"pullRequest":{"timelineItems":{
+				"nodes":[{"__typename":"MergedEvent","id":"ME_1","createdAt":"2016-03-16T22:25:00Z",
+					"actor":{"login":"tester2"},"commit":{"oid":"deadbeef"}}],
+				"pageInfo":{"endCursor":"t2","hasNextPage":false}}}}}}`
+		}
+		return `{"data":{"repository":{"pullRequests":{
+			"nodes":[{
+				"number":7,"title":"PR 7","createdAt":"2016-03-16T22:20:00Z","closedAt":null,
+				"url":"https://github.com/lodash/lodash/pull/7","author":{"login":"tester1"},
+				"timelineItems":{
+					"nodes":[{"__typename":"LabeledEvent","id":"LE_1","createdAt":"2016-03-16T22:24:01Z",
+						"actor":{"login":"tester3"},"label":{"name":"ready for review","color":"00ff00"}}],
+					"pageInfo":{"endCursor":"t1","hasNextPage":true}}
+			}],
+			"pageInfo":{"endCursor":"p1","hasNextPage":false}}}}}`
+	})
+	defer ts.Close()
+
+	prEvents, err := newTestGraphQLClient(ts).ListAllPrEvents(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, prEvents, 3)
+	assertIssueEventContents(t, prEvents[0], "tester1", IssueCreated, "cr7", 7)
+	assertIssueEventContents(t, prEvents[1], "tester3", IssueLabeled, "gql:LE_1", 7)
+	assert.Equal(t, "ready for review", prEvents[1].Detail.(map[string]interface{})["name"])
+	assertIssueEventContents(t, prEvents[2], "tester2", IssueMerged, "gql:ME_1", 7)
+	assert.Equal(t, "deadbeef", prEvents[2].Detail.(string))
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	t.Parallel()
+
+	ts := newGraphQLServer(t, func(req *graphQLRequest) string {
+		return `{"data":null,"errors":[{"message":"Something went wrong"}]}`
+	})
+	defer ts.Close()
+
+	_, err := newTestGraphQLClient(ts).ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusBadGateway, err.Status)
+}
+
+func TestGraphQLRepositoryNotFound(t *testing.T) {
+	t.Parallel()
+
+	ts := newGraphQLServer(t, func(req *graphQLRequest) string {
+		return `{"data":{"repository":null}}`
+	})
+	defer ts.Close()
+
+	_, err := newTestGraphQLClient(ts).ListStarEvents(context.Background(), mocks.DummyLogger(t), "lodash", "nope")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, err.Status)
+}
+
+func TestGraphQLGetRateLimit(t *testing.T) {
+	t.Parallel()
+
+	ts := newGraphQLServer(t, func(req *graphQLRequest) string {
+		assert.Contains(t, req.Query, "rateLimit")
+		return `{"data":{"rateLimit":{"limit":5000,"remaining":4990,"resetAt":"2016-03-26T05:21:27Z"}}}`
+	})
+	defer ts.Close()
+
+	rateLimit, err := newTestGraphQLClient(ts).GetRateLimit(context.Background(), mocks.DummyLogger(t))
+	assert.NoError(t, err)
+	assert.Equal(t, 4990, rateLimit.Remaining)
+	assert.Equal(t, time.Date(2016, 3, 26, 5, 21, 27, 0, time.UTC), rateLimit.Reset.UTC())
+}
+
+func TestNewBackend(t *testing.T) {
+	t.Parallel()
+
+	backend, err := NewBackend("", &Options{})
+	assert.NoError(t, err)
+	assert.IsType(t, &Client{}, backend)
+
+	backend, err = NewBackend("graphql", &Options{})
+	assert.NoError(t, err)
+	assert.IsType(t, &GraphQLClient{}, backend)
+
+	_, err = NewBackend("soap", &Options{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,458 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+)
+
+// ghostLogin stands in for deleted accounts. Github returns a null user for
+// the issues and events they left behind.
+const ghostLogin string = "ghost"
+
+type userPayload struct {
+	Login string `json:"login"`
+}
+
+func (user *userPayload) login() string {
+	if user == nil || user.Login == "" {
+		return ghostLogin
+	}
+	return user.Login
+}
+
+type labelPayload struct {
+	Color string `json:"color"`
+	Name  string `json:"name"`
+}
+
+type milestonePayload struct {
+	Title string `json:"title"`
+}
+
+type issuePayload struct {
+	Assignees []*userPayload    `json:"assignees"`
+	ClosedAt  *string           `json:"closed_at"`
+	Comments  int               `json:"comments"`
+	CreatedAt string            `json:"created_at"`
+	EventsUrl string            `json:"events_url"`
+	HtmlUrl   string            `json:"html_url"`
+	Labels    []*labelPayload   `json:"labels"`
+	Milestone *milestonePayload `json:"milestone"`
+	Number    *int              `json:"number"`
+	Title     string            `json:"title"`
+	User      *userPayload      `json:"user"`
+}
+
+type issueEventPayload struct {
+	Actor     *userPayload `json:"actor"`
+	CreatedAt string       `json:"created_at"`
+	Event     string       `json:"event"`
+	Id        *int64       `json:"id"`
+}
+
+func schemaError(logger *log.Logger, cause error) *errors.HttpError {
+	logger.Printf("ERROR: Unexpected response from Github: %s\n", cause.Error())
+	return &errors.HttpError{
+		Cause:   cause,
+		Code:    errors.CodeInvalidResponse,
+		Message: "Unexpected Response From Github",
+		Status:  http.StatusBadGateway,
+	}
+}
+
+// decodePayload converts a generic JSON object, as we get it from Github or
+// from the cache, into one of the payload types above.
+func decodePayload(logger *log.Logger, raw interface{}, payload interface{}) *errors.HttpError {
+	// Suppress JSON marshaling errors. The raw value was itself decoded from
+	// JSON, so we can always marshal it again.
+	jsonBlob, _ := json.Marshal(raw)
+	if err := json.Unmarshal(jsonBlob, payload); err != nil {
+		return schemaError(logger, err)
+	}
+	return nil
+}
+
+func parseTimestamp(logger *log.Logger, field, value string) (time.Time, *errors.HttpError) {
+	timestamp, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, schemaError(logger, fmt.Errorf("%s: %s", field, err.Error()))
+	}
+	return timestamp, nil
+}
+
+func (payload *issuePayload) toIssue(logger *log.Logger, issue *Issue, isPr bool) *errors.HttpError {
+	if payload.Number == nil {
+		return schemaError(logger, fmt.Errorf("issue is missing its number"))
+	}
+	createdAt, httpErr := parseTimestamp(logger, "created_at", payload.CreatedAt)
+	if httpErr != nil {
+		return httpErr
+	}
+	issue.IsClosed = payload.ClosedAt != nil
+	if issue.IsClosed {
+		closedAt, httpErr := parseTimestamp(logger, "closed_at", *payload.ClosedAt)
+		if httpErr != nil {
+			return httpErr
+		}
+		issue.ClosedAt = closedAt
+	}
+	issue.Assignees = nil
+	for _, assignee := range payload.Assignees {
+		if assignee != nil && assignee.Login != "" {
+			issue.Assignees = append(issue.Assignees, assignee.Login)
+		}
+	}
+	issue.Comments = payload.Comments
+	issue.CreatedAt = createdAt
+	issue.EventsUrl = payload.EventsUrl
+	issue.HtmlUrl = payload.HtmlUrl
+	issue.IsPr = isPr
+	issue.Labels = nil
+	for _, label := range payload.Labels {
+		if label != nil {
+			issue.Labels = append(issue.Labels, Label{Color: label.Color, Name: label.Name})
+		}
+	}
+	issue.Milestone = ""
+	if payload.Milestone != nil {
+		issue.Milestone = payload.Milestone.Title
+	}
+	issue.Number = *payload.Number
+	issue.Submitter = payload.User.login()
+	issue.Title = payload.Title
+	return nil
+}
+
+// matchesRawIssue applies a filter to an issue as Github returned it. Issues
+// that cannot be decoded never match.
+func matchesRawIssue(logger *log.Logger, filter IssueFilter, rawIssue map[string]interface{}) bool {
+	if filter.IsEmpty() {
+		return true
+	}
+	var issue Issue
+	if httpErr := parseIssue(logger, &issue, rawIssue); httpErr != nil {
+		return false
+	}
+	return filter.Matches(&issue)
+}
+
+// matchesRawMilestone checks the milestone of an issue as Github returned it,
+// without decoding the rest of the issue.
+func matchesRawMilestone(filter IssueFilter, rawIssue map[string]interface{}) bool {
+	if filter.Milestone == "" {
+		return true
+	}
+	milestone, _ := rawIssue["milestone"].(map[string]interface{})
+	title, _ := milestone["title"].(string)
+	return title == filter.Milestone
+}
+
+type pullRequestPayload struct {
+	Additions int `json:"additions"`
+	Base      *struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+	ChangedFiles       int            `json:"changed_files"`
+	ClosedAt           *string        `json:"closed_at"`
+	CreatedAt          string         `json:"created_at"`
+	Deletions          int            `json:"deletions"`
+	Draft              bool           `json:"draft"`
+	HtmlUrl            string         `json:"html_url"`
+	MergedAt           *string        `json:"merged_at"`
+	Number             *int           `json:"number"`
+	RequestedReviewers []*userPayload `json:"requested_reviewers"`
+	RequestedTeams     []*struct {
+		Slug string `json:"slug"`
+	} `json:"requested_teams"`
+	Title string       `json:"title"`
+	User  *userPayload `json:"user"`
+}
+
+func (payload *pullRequestPayload) toPullRequest(logger *log.Logger, pr *PullRequest) *errors.HttpError {
+	if payload.Number == nil {
+		return schemaError(logger, fmt.Errorf("pull request is missing its number"))
+	}
+	createdAt, httpErr := parseTimestamp(logger, "created_at", payload.CreatedAt)
+	if httpErr != nil {
+		return httpErr
+	}
+	pr.IsClosed = payload.ClosedAt != nil
+	if pr.IsClosed {
+		if pr.ClosedAt, httpErr = parseTimestamp(logger, "closed_at", *payload.ClosedAt); httpErr != nil {
+			return httpErr
+		}
+	}
+	pr.IsMerged = payload.MergedAt != nil
+	if pr.IsMerged {
+		if pr.MergedAt, httpErr = parseTimestamp(logger, "merged_at", *payload.MergedAt); httpErr != nil {
+			return httpErr
+		}
+	}
+	pr.Additions = payload.Additions
+	pr.BaseBranch = ""
+	if payload.Base != nil {
+		pr.BaseBranch = payload.Base.Ref
+	}
+	pr.ChangedFiles = payload.ChangedFiles
+	pr.CreatedAt = createdAt
+	pr.Deletions = payload.Deletions
+	pr.HtmlUrl = payload.HtmlUrl
+	pr.IsDraft = payload.Draft
+	pr.Number = *payload.Number
+	pr.RequestedReviewers = nil
+	for _, reviewer := range payload.RequestedReviewers {
+		if reviewer != nil && reviewer.Login != "" {
+			pr.RequestedReviewers = append(pr.RequestedReviewers, reviewer.Login)
+		}
+	}
+	for _, team := range payload.RequestedTeams {
+		if team != nil && team.Slug != "" {
+			pr.RequestedReviewers = append(pr.RequestedReviewers, team.Slug)
+		}
+	}
+	pr.Submitter = payload.User.login()
+	pr.Title = payload.Title
+	return nil
+}
+
+func parsePullRequests(logger *log.Logger, rawPulls []map[string]interface{}) ([]PullRequest, *errors.HttpError) {
+	var payloads []pullRequestPayload
+	if httpErr := decodePayload(logger, rawPulls, &payloads); httpErr != nil {
+		return nil, httpErr
+	}
+	pulls := make([]PullRequest, len(payloads))
+	for i := range payloads {
+		if httpErr := payloads[i].toPullRequest(logger, &pulls[i]); httpErr != nil {
+			return nil, httpErr
+		}
+	}
+	return pulls, nil
+}
+
+type commitPayload struct {
+	Author *userPayload `json:"author"`
+	Commit struct {
+		Author *struct {
+			Name string `json:"name"`
+		} `json:"author"`
+		Committer *struct {
+			Date string `json:"date"`
+		} `json:"committer"`
+		Message string `json:"message"`
+	} `json:"commit"`
+	Sha string `json:"sha"`
+}
+
+func (payload *commitPayload) toCommit(logger *log.Logger, commit *Commit) *errors.HttpError {
+	if payload.Sha == "" {
+		return schemaError(logger, fmt.Errorf("commit is missing its sha"))
+	}
+	if payload.Commit.Committer == nil {
+		return schemaError(logger, fmt.Errorf("commit %s is missing its committer", payload.Sha))
+	}
+	committedAt, httpErr := parseTimestamp(logger, "committer.date", payload.Commit.Committer.Date)
+	if httpErr != nil {
+		return httpErr
+	}
+	commit.Author = ghostLogin
+	if payload.Author != nil && payload.Author.Login != "" {
+		commit.Author = payload.Author.Login
+	} else if payload.Commit.Author != nil && payload.Commit.Author.Name != "" {
+		commit.Author = payload.Commit.Author.Name
+	}
+	commit.CommittedAt = committedAt
+	commit.Headline = messageHeadline(payload.Commit.Message)
+	commit.Sha = payload.Sha
+	return nil
+}
+
+func parseCommits(logger *log.Logger, rawCommits []map[string]interface{}) ([]Commit, *errors.HttpError) {
+	var payloads []commitPayload
+	if httpErr := decodePayload(logger, rawCommits, &payloads); httpErr != nil {
+		return nil, httpErr
+	}
+	commits := make([]Commit, len(payloads))
+	for i := range payloads {
+		if httpErr := payloads[i].toCommit(logger, &commits[i]); httpErr != nil {
+			return nil, httpErr
+		}
+	}
+	return commits, nil
+}
+
+type releasePayload struct {
+	Assets []*struct {
+		DownloadCount int `json:"download_count"`
+	} `json:"assets"`
+	Draft       bool    `json:"draft"`
+	HtmlUrl     string  `json:"html_url"`
+	Name        *string `json:"name"`
+	Prerelease  bool    `json:"prerelease"`
+	PublishedAt *string `json:"published_at"`
+	TagName     string  `json:"tag_name"`
+}
+
+func (payload *releasePayload) toRelease(logger *log.Logger, release *Release) *errors.HttpError {
+	if payload.TagName == "" {
+		return schemaError(logger, fmt.Errorf("release is missing its tag_name"))
+	}
+	release.PublishedAt = time.Time{}
+	if payload.PublishedAt != nil {
+		publishedAt, httpErr := parseTimestamp(logger, "published_at", *payload.PublishedAt)
+		if httpErr != nil {
+			return httpErr
+		}
+		release.PublishedAt = publishedAt
+	}
+	release.AssetDownloads = 0
+	for _, asset := range payload.Assets {
+		if asset != nil {
+			release.AssetDownloads += asset.DownloadCount
+		}
+	}
+	release.HtmlUrl = payload.HtmlUrl
+	release.IsDraft = payload.Draft
+	release.IsPrerelease = payload.Prerelease
+	// Releases without a title show up on Github under their tag.
+	release.Name = payload.TagName
+	if payload.Name != nil && *payload.Name != "" {
+		release.Name = *payload.Name
+	}
+	release.TagName = payload.TagName
+	return nil
+}
+
+func parseReleases(logger *log.Logger, rawReleases []map[string]interface{}) ([]Release, *errors.HttpError) {
+	var payloads []releasePayload
+	if httpErr := decodePayload(logger, rawReleases, &payloads); httpErr != nil {
+		return nil, httpErr
+	}
+	releases := make([]Release, len(payloads))
+	for i := range payloads {
+		if httpErr := payloads[i].toRelease(logger, &releases[i]); httpErr != nil {
+			return nil, httpErr
+		}
+	}
+	return releases, nil
+}
+
+type tagPayload struct {
+	Commit *struct {
+		Sha string `json:"sha"`
+	} `json:"commit"`
+	Name string `json:"name"`
+}
+
+func parseTags(logger *log.Logger, rawTags []map[string]interface{}) ([]Tag, *errors.HttpError) {
+	var payloads []tagPayload
+	if httpErr := decodePayload(logger, rawTags, &payloads); httpErr != nil {
+		return nil, httpErr
+	}
+	tags := make([]Tag, len(payloads))
+	for i, payload := range payloads {
+		if payload.Name == "" || payload.Commit == nil {
+			return nil, schemaError(logger, fmt.Errorf("tag %d is missing its name or commit", i))
+		}
+		tags[i].Name = payload.Name
+		tags[i].Sha = payload.Commit.Sha
+	}
+	return tags, nil
+}
+
+type contributorStatsPayload struct {
+	Author *userPayload `json:"author"`
+	Total  int          `json:"total"`
+	Weeks  []struct {
+		Additions int   `json:"a"`
+		Commits   int   `json:"c"`
+		Deletions int   `json:"d"`
+		Week      int64 `json:"w"`
+	} `json:"weeks"`
+}
+
+func parseContributorStats(
+	logger *log.Logger,
+	rawContributors []map[string]interface{},
+) ([]ContributorStats, *errors.HttpError) {
+	var payloads []contributorStatsPayload
+	if httpErr := decodePayload(logger, rawContributors, &payloads); httpErr != nil {
+		return nil, httpErr
+	}
+	contributors := make([]ContributorStats, len(payloads))
+	for i, payload := range payloads {
+		contributors[i].Author = payload.Author.login()
+		contributors[i].TotalCommits = payload.Total
+		for _, week := range payload.Weeks {
+			contributors[i].Weeks = append(contributors[i].Weeks, WeeklyStats{
+				Additions: week.Additions,
+				Commits:   week.Commits,
+				Deletions: week.Deletions,
+				Week:      time.Unix(week.Week, 0).UTC(),
+			})
+		}
+	}
+	return contributors, nil
+}
+
+func parseCodeFrequencies(
+	logger *log.Logger,
+	rawFrequencies []map[string]interface{},
+) ([]CodeFrequency, *errors.HttpError) {
+	var payloads []struct {
+		Additions int   `json:"additions"`
+		Deletions int   `json:"deletions"`
+		Week      int64 `json:"week"`
+	}
+	if httpErr := decodePayload(logger, rawFrequencies, &payloads); httpErr != nil {
+		return nil, httpErr
+	}
+	frequencies := make([]CodeFrequency, len(payloads))
+	for i, payload := range payloads {
+		// Github reports deletions as negative numbers.
+		deletions := payload.Deletions
+		if deletions < 0 {
+			deletions = -deletions
+		}
+		frequencies[i] = CodeFrequency{
+			Additions: payload.Additions,
+			Deletions: deletions,
+			Week:      time.Unix(payload.Week, 0).UTC(),
+		}
+	}
+	return frequencies, nil
+}
+
+type repositoryPayload struct {
+	Archived bool         `json:"archived"`
+	Fork     bool         `json:"fork"`
+	Name     string       `json:"name"`
+	Owner    *userPayload `json:"owner"`
+}
+
+func parseRepositories(
+	logger *log.Logger,
+	rawRepositories []map[string]interface{},
+) ([]Repository, *errors.HttpError) {
+	var payloads []repositoryPayload
+	if httpErr := decodePayload(logger, rawRepositories, &payloads); httpErr != nil {
+		return nil, httpErr
+	}
+	repositories := make([]Repository, len(payloads))
+	for i, payload := range payloads {
+		if payload.Name == "" {
+			return nil, schemaError(logger, fmt.Errorf("repository %d is missing its name", i))
+		}
+		repositories[i] = Repository{
+			IsArchived: payload.Archived,
+			IsFork:     payload.Fork,
+			Name:       payload.Name,
+			Owner:      payload.Owner.login(),
+		}
+	}
+	return repositories, nil
+}
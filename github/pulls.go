@@ -0,0 +1,187 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+)
+
+type PullRequest struct {
+	Additions          int
+	BaseBranch         string
+	ChangedFiles       int
+	ClosedAt           time.Time
+	CreatedAt          time.Time
+	Deletions          int
+	HtmlUrl            string
+	IsClosed           bool
+	IsDraft            bool
+	IsMerged           bool
+	MergedAt           time.Time
+	Number             int
+	RequestedReviewers []string
+	Submitter          string
+	Title              string
+}
+
+func (pr *PullRequest) MarshalJSON() ([]byte, error) {
+	requestedReviewers := pr.RequestedReviewers
+	if requestedReviewers == nil {
+		requestedReviewers = []string{}
+	}
+	return json.Marshal(map[string]interface{}{
+		"additions":           pr.Additions,
+		"base_branch":         pr.BaseBranch,
+		"changed_files":       pr.ChangedFiles,
+		"closed_at":           pr.ClosedAt,
+		"created_at":          pr.CreatedAt,
+		"deletions":           pr.Deletions,
+		"html_url":            pr.HtmlUrl,
+		"is_closed":           pr.IsClosed,
+		"is_draft":            pr.IsDraft,
+		"is_merged":           pr.IsMerged,
+		"merged_at":           pr.MergedAt,
+		"number":              pr.Number,
+		"requested_reviewers": requestedReviewers,
+		"submitter":           pr.Submitter,
+		"title":               pr.Title,
+	})
+}
+
+type ListPullRequestser interface {
+	ListPullRequests(context.Context, *log.Logger, string, string) ([]PullRequest, *errors.HttpError)
+}
+
+func pullRequestsKey(owner, repo string) string {
+	return fmt.Sprintf("github:repo:%s:%s:pulls", owner, repo)
+}
+
+func cleanPullRequestJsons(pulls []map[string]interface{}) {
+	for _, pull := range pulls {
+		keepKeys(
+			pull,
+			"additions",
+			"base",
+			"changed_files",
+			"closed_at",
+			"created_at",
+			"deletions",
+			"draft",
+			"html_url",
+			"merged_at",
+			"number",
+			"requested_reviewers",
+			"requested_teams",
+			"title",
+			"updated_at",
+			"user",
+		)
+		keepKeys(pull["base"], "ref")
+		keepKeys(pull["user"], "login")
+		if reviewers, ok := pull["requested_reviewers"].([]interface{}); ok {
+			for _, reviewer := range reviewers {
+				keepKeys(reviewer, "login")
+			}
+		}
+		if teams, ok := pull["requested_teams"].([]interface{}); ok {
+			for _, team := range teams {
+				keepKeys(team, "slug")
+			}
+		}
+	}
+}
+
+// reusablePullDetails finds the stale cached PRs that have not been updated
+// since we cached them, so we do not have to fetch their details again.
+func reusablePullDetails(stale []map[string]interface{}) map[float64]map[string]interface{} {
+	reusable := make(map[float64]map[string]interface{})
+	for _, pull := range stale {
+		number, hasNumber := pull["number"].(float64)
+		_, hasDetails := pull["additions"]
+		if hasNumber && hasDetails {
+			reusable[number] = pull
+		}
+	}
+	return reusable
+}
+
+// ListPullRequests lists every PR in a repo along with its size. The list
+// endpoint leaves out the size, so it also fetches each PR that changed since
+// the last time we cached them.
+func (gh *Client) ListPullRequests(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]PullRequest, *errors.HttpError) {
+	ctx, cancel := gh.withDeadline(ctx)
+	defer cancel()
+	rawPulls, httpErr := redisWrap(
+		ctx,
+		gh,
+		pullRequestsKey(owner, repo),
+		"pull requests",
+		logger,
+		func(ctx context.Context, stale []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			pulls, httpErr := gh.paginateGithub(
+				ctx,
+				logger,
+				fmt.Sprintf(
+					"%s/repos/%s/%s/pulls?per_page=100&state=all&sort=created&direction=asc",
+					gh.baseUrl,
+					owner,
+					repo,
+				),
+				"application/vnd.github.v3+json",
+			)
+			if httpErr != nil {
+				return nil, httpErr
+			}
+
+			reusable := reusablePullDetails(stale)
+			var detailUrls []string
+			var detailIndices []int
+			for i, pull := range pulls {
+				number, _ := pull["number"].(float64)
+				if cached, isCached := reusable[number]; isCached && cached["updated_at"] == pull["updated_at"] {
+					pulls[i] = cached
+					continue
+				}
+				detailUrls = append(
+					detailUrls,
+					fmt.Sprintf("%s/repos/%s/%s/pulls/%d", gh.baseUrl, owner, repo, int(number)),
+				)
+				detailIndices = append(detailIndices, i)
+			}
+			if len(detailUrls) > 0 {
+				logger.Printf("Fetching details for %d pull requests in %s/%s.\n", len(detailUrls), owner, repo)
+			}
+			httpErr = gh.fetchEach(
+				ctx,
+				logger,
+				detailUrls,
+				"application/vnd.github.v3+json",
+				func(i int, contents []byte) *errors.HttpError {
+					var pull map[string]interface{}
+					if err := json.Unmarshal(contents, &pull); err != nil {
+						return schemaError(logger, err)
+					}
+					pulls[detailIndices[i]] = pull
+					return nil
+				},
+			)
+			if httpErr != nil {
+				return nil, httpErr
+			}
+			cleanPullRequestJsons(pulls)
+			return pulls, nil
+		},
+	)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return parsePullRequests(logger, rawPulls)
+}
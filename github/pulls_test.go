@@ -0,0 +1,174 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/mocks"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const pullsJson string = `[{
+	"base":{"ref":"master","sha":"deadbeef"},
+	"closed_at":"2016-03-08T03:26:14Z",
+	"created_at":"2016-03-07T03:26:14Z",
+	"draft":false,
+	"html_url":"https://github.com/lodash/lodash/pull/1",
+	"merged_at":"2016-03-08T03:26:14Z",
+	"number":1,
+	"requested_reviewers":[],
+	"requested_teams":[],
+	"title":"PR 1",
+	"updated_at":"2016-03-08T03:26:14Z",
+	"user":{"login":"tester1","id":1}
+}, {
+	"base":{"ref":"master","sha":"deadbeef"},
+	"closed_at":null,
+	"created_at":"2016-03-09T03:26:14Z",
+	"draft":true,
+	"html_url":"https://github.com/lodash/lodash/pull/2",
+	"merged_at":null,
+	"number":2,
+	"requested_reviewers":[{"login":"tester2","id":2}],
+	"requested_teams":[{"slug":"core","id":3}],
+	"title":"PR 2",
+	"updated_at":"2016-03-10T03:26:14Z",
+	"user":null
+}]`
+
+func pullDetailJson(number, additions, deletions, changedFiles int, updatedAt string) string {
+	return fmt.Sprintf(`{
+	"additions":%d,
+	"base":{"ref":"master"},
+	"changed_files":%d,
+	"closed_at":null,
+	"created_at":"2016-03-09T03:26:14Z",
+	"deletions":%d,
+	"draft":true,
+	"html_url":"https://github.com/lodash/lodash/pull/%d",
+	"merged_at":null,
+	"number":%d,
+	"requested_reviewers":[{"login":"tester2"}],
+	"requested_teams":[{"slug":"core"}],
+	"title":"PR %d",
+	"updated_at":"%s",
+	"user":null
+}`, additions, changedFiles, deletions, number, number, number, updatedAt)
+}
+
+const lodashPullsPath string = "/repos/lodash/lodash/pulls?per_page=100&state=all&sort=created&direction=asc"
+
+func TestListPullRequests(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/lodash/lodash/pulls":
+			fmt.Fprintln(w, pullsJson)
+		case "/repos/lodash/lodash/pulls/1":
+			fmt.Fprintln(w, `{"additions":10,"deletions":2,"changed_files":1,"number":1,
+				"created_at":"2016-03-07T03:26:14Z","closed_at":"2016-03-08T03:26:14Z",
+				"merged_at":"2016-03-08T03:26:14Z","base":{"ref":"master"},"user":{"login":"tester1"},
+				"title":"PR 1","updated_at":"2016-03-08T03:26:14Z"}`)
+		case "/repos/lodash/lodash/pulls/2":
+			fmt.Fprintln(w, pullDetailJson(2, 300, 40, 5, "2016-03-10T03:26:14Z"))
+		default:
+			assert.FailNow(t, fmt.Sprintf("Unexpected request for %s", r.URL.String()))
+		}
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	pulls, err := gh.ListPullRequests(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, pulls, 2)
+
+	assert.Equal(t, 1, pulls[0].Number)
+	assert.Equal(t, 10, pulls[0].Additions)
+	assert.True(t, pulls[0].IsMerged)
+	assert.Equal(t, time.Date(2016, 3, 8, 3, 26, 14, 0, time.UTC), pulls[0].MergedAt.UTC())
+	assert.Equal(t, "tester1", pulls[0].Submitter)
+
+	assert.Equal(t, 300, pulls[1].Additions)
+	assert.Equal(t, 40, pulls[1].Deletions)
+	assert.Equal(t, 5, pulls[1].ChangedFiles)
+	assert.Equal(t, "master", pulls[1].BaseBranch)
+	assert.True(t, pulls[1].IsDraft)
+	assert.False(t, pulls[1].IsMerged)
+	assert.Equal(t, []string{"tester2", "core"}, pulls[1].RequestedReviewers)
+	assert.Equal(t, "ghost", pulls[1].Submitter)
+}
+
+func TestListPullRequestsReusesStaleDetails(t *testing.T) {
+	t.Parallel()
+
+	var mutex sync.Mutex
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requested = append(requested, r.URL.Path)
+		mutex.Unlock()
+		switch r.URL.Path {
+		case "/repos/lodash/lodash/pulls":
+			fmt.Fprintln(w, pullsJson)
+		case "/repos/lodash/lodash/pulls/2":
+			fmt.Fprintln(w, pullDetailJson(2, 300, 40, 5, "2016-03-10T03:26:14Z"))
+		default:
+			assert.FailNow(t, fmt.Sprintf("Unexpected request for %s", r.URL.String()))
+		}
+	}))
+	defer ts.Close()
+
+	redisMock := &mocks.MockRediser{}
+	gh := NewClient(&Options{
+		BaseUrl:      ts.URL,
+		MaxStaleness: 5,
+		RedisClient:  redisMock,
+		Token:        "deadbeef",
+	})
+	cacheKey := pullRequestsKey("lodash", "lodash")
+	stale := fmt.Sprintf(`[{"additions":10,"deletions":2,"changed_files":1,"number":1,
+		"created_at":"2016-03-07T03:26:14Z","closed_at":"2016-03-08T03:26:14Z",
+		"merged_at":"2016-03-08T03:26:14Z","base":{"ref":"master"},"user":{"login":"tester1"},
+		"title":"PR 1","updated_at":"2016-03-08T03:26:14Z"},
+		%s]`, pullDetailJson(2, 1, 1, 1, "2016-03-09T03:26:14Z"))
+	redisMock.On("Get", cacheKey).Return(
+		fmt.Sprintf("%d|%s", time.Now().Add(-6*time.Minute).Unix(), stale),
+		nil,
+	)
+	expectPageCacheMiss(redisMock, ts.URL, lodashPullsPath)
+	expectPageCacheMiss(redisMock, ts.URL, "/repos/lodash/lodash/pulls/2")
+	redisMock.On("Set", cacheKey, "", time.Duration(0)).Return(nil)
+
+	pulls, err := gh.ListPullRequests(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, pulls, 2)
+	assert.Equal(t, 10, pulls[0].Additions)
+	assert.Equal(t, 300, pulls[1].Additions)
+	assert.Equal(t, []string{"/repos/lodash/lodash/pulls", "/repos/lodash/lodash/pulls/2"}, requested)
+	redisMock.AssertExpectations(t)
+}
+
+func TestMarshalPullRequest(t *testing.T) {
+	t.Parallel()
+
+	jsonBytes := mocks.MarshalJSON(t, &PullRequest{
+		Additions:  10,
+		BaseBranch: "master",
+		IsDraft:    true,
+		Number:     7,
+	})
+	assert.Contains(t, string(jsonBytes), `"additions":10`)
+	assert.Contains(t, string(jsonBytes), `"base_branch":"master"`)
+	assert.Contains(t, string(jsonBytes), `"is_draft":true`)
+	assert.Contains(t, string(jsonBytes), `"requested_reviewers":[]`)
+}
@@ -0,0 +1,176 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+)
+
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+func (rl *RateLimit) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"limit":     rl.Limit,
+		"remaining": rl.Remaining,
+		"reset":     rl.Reset,
+	})
+}
+
+type rateLimitState struct {
+	sync.Mutex
+	blockedUntil time.Time
+	current      RateLimit
+	isKnown      bool
+}
+
+func rateLimitError(retryAfter time.Duration) *errors.HttpError {
+	return &errors.HttpError{
+		Code:       errors.CodeRateLimited,
+		Message:    "Github Rate Limit Exceeded",
+		RetryAfter: retryAfter,
+		Status:     http.StatusServiceUnavailable,
+	}
+}
+
+func parseRateLimitHeaders(header http.Header) (RateLimit, bool) {
+	limit, limitErr := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	remaining, remainingErr := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	reset, resetErr := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if limitErr != nil || remainingErr != nil || resetErr != nil {
+		return RateLimit{}, false
+	}
+	return RateLimit{Limit: limit, Remaining: remaining, Reset: time.Unix(reset, 0)}, true
+}
+
+func (gh *Client) recordRateLimit(rateLimit RateLimit) {
+	gh.rateLimit.Lock()
+	defer gh.rateLimit.Unlock()
+	gh.rateLimit.current = rateLimit
+	gh.rateLimit.isKnown = true
+}
+
+// awaitRateLimit decides whether we can afford to send another request. If
+// the remaining quota is at or below the configured reserve, it either sleeps
+// until the quota resets or refuses the request, depending on how long the
+// reset is away.
+func (gh *Client) awaitRateLimit(ctx context.Context, logger *log.Logger) *errors.HttpError {
+	gh.rateLimit.Lock()
+	now := gh.clock.Now()
+	var resumeAt time.Time
+	if now.Before(gh.rateLimit.blockedUntil) {
+		resumeAt = gh.rateLimit.blockedUntil
+	} else if gh.rateLimit.isKnown &&
+		gh.rateLimit.current.Remaining <= gh.rateLimitReserve &&
+		now.Before(gh.rateLimit.current.Reset) {
+		resumeAt = gh.rateLimit.current.Reset
+	}
+	gh.rateLimit.Unlock()
+
+	if resumeAt.IsZero() {
+		return nil
+	}
+	wait := resumeAt.Sub(now)
+	if wait > gh.maxRateLimitWait {
+		logger.Printf("Github rate limit is exhausted for another %s, refusing request.\n", wait.String())
+		return rateLimitError(wait)
+	}
+	logger.Printf("Github rate limit is exhausted, pausing for %s.\n", wait.String())
+	select {
+	case <-gh.clock.After(wait):
+		return nil
+	case <-ctx.Done():
+		return requestError(ctx, ctx.Err())
+	}
+}
+
+// checkRateLimitResponse inspects a response for the primary and secondary
+// rate limit errors Github returns, and blocks further requests until the
+// limit is lifted.
+func (gh *Client) checkRateLimitResponse(logger *log.Logger, resp *http.Response) *errors.HttpError {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	now := gh.clock.Now()
+	var retryAfter time.Duration
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		retryAfter = time.Duration(seconds) * time.Second
+	} else if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		rateLimit, _ := parseRateLimitHeaders(resp.Header)
+		retryAfter = rateLimit.Reset.Sub(now)
+	} else {
+		return nil
+	}
+	if retryAfter < 0 {
+		retryAfter = 0
+	}
+
+	gh.rateLimit.Lock()
+	if blockedUntil := now.Add(retryAfter); blockedUntil.After(gh.rateLimit.blockedUntil) {
+		gh.rateLimit.blockedUntil = blockedUntil
+	}
+	gh.rateLimit.Unlock()
+	logger.Printf("Github responded with a rate limit error, retry after %s.\n", retryAfter.String())
+	return rateLimitError(retryAfter)
+}
+
+type GetRateLimiter interface {
+	GetRateLimit(context.Context, *log.Logger) (*RateLimit, *errors.HttpError)
+}
+
+func (gh *Client) GetRateLimit(ctx context.Context, logger *log.Logger) (*RateLimit, *errors.HttpError) {
+	ctx, cancel := gh.withDeadline(ctx)
+	defer cancel()
+	// Requests to /rate_limit do not count against the rate limit, so skip
+	// awaitRateLimit here.
+	resp, httpErr := gh.doGithubRequest(
+		ctx,
+		logger,
+		fmt.Sprintf("%s/rate_limit", gh.baseUrl),
+		"application/vnd.github.v3+json",
+		nil,
+	)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	defer resp.Body.Close()
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		logger.Printf("ERROR: %s\n", err.Error())
+		return nil, &errors.HttpError{Message: "Server Error", Status: http.StatusInternalServerError}
+	}
+	if !isSuccess(resp) {
+		return nil, upstreamError(logger, resp, contents)
+	}
+	var body struct {
+		Resources struct {
+			Core struct {
+				Limit     int   `json:"limit"`
+				Remaining int   `json:"remaining"`
+				Reset     int64 `json:"reset"`
+			} `json:"core"`
+		} `json:"resources"`
+	}
+	if err := json.Unmarshal(contents, &body); err != nil {
+		logger.Printf("ERROR: %s\n", err.Error())
+		return nil, &errors.HttpError{Message: "Github API Error", Status: http.StatusBadGateway}
+	}
+	rateLimit := RateLimit{
+		Limit:     body.Resources.Core.Limit,
+		Remaining: body.Resources.Core.Remaining,
+		Reset:     time.Unix(body.Resources.Core.Reset, 0),
+	}
+	gh.recordRateLimit(rateLimit)
+	return &rateLimit, nil
+}
@@ -0,0 +1,212 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/mocks"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitRefusesWhenExhausted(t *testing.T) {
+	t.Parallel()
+
+	clock := clockwork.NewFakeClockAt(time.Unix(1458966366, 0))
+	call := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call++
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "10")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", clock.Now().Add(20*time.Minute).Unix()))
+		fmt.Fprintln(w, starsJson)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl:          ts.URL,
+		Clock:            clock,
+		RateLimitReserve: 10,
+		Token:            "deadbeef",
+	})
+	_, err := gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+	assert.NoError(t, err)
+
+	_, err = gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, err.Status)
+	assert.Equal(t, 20*time.Minute, err.RetryAfter)
+	assert.Equal(t, 1, call)
+}
+
+func TestRateLimitAllowsAfterReset(t *testing.T) {
+	t.Parallel()
+
+	clock := clockwork.NewFakeClockAt(time.Unix(1458966366, 0))
+	call := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call++
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", clock.Now().Add(time.Minute).Unix()))
+		fmt.Fprintln(w, starsJson)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Clock:   clock,
+		Token:   "deadbeef",
+	})
+	_, err := gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+	assert.NoError(t, err)
+	clock.Advance(2 * time.Minute)
+	_, err = gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, call)
+}
+
+func TestRateLimitPausesWithinMaxWait(t *testing.T) {
+	t.Parallel()
+
+	clock := clockwork.NewFakeClockAt(time.Unix(1458966366, 0))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", clock.Now().Add(time.Minute).Unix()))
+		fmt.Fprintln(w, starsJson)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl:          ts.URL,
+		Clock:            clock,
+		MaxRateLimitWait: time.Hour,
+		Token:            "deadbeef",
+	})
+	_, err := gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+	assert.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		_, err := gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+		assert.NoError(t, err)
+		close(done)
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	<-done
+}
+
+func TestRateLimitPauseCanceled(t *testing.T) {
+	t.Parallel()
+
+	clock := clockwork.NewFakeClockAt(time.Unix(1458966366, 0))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", clock.Now().Add(time.Minute).Unix()))
+		fmt.Fprintln(w, starsJson)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl:          ts.URL,
+		Clock:            clock,
+		MaxRateLimitWait: time.Hour,
+		Token:            "deadbeef",
+	})
+	_, err := gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_, err := gh.ListStarEvents(ctx, mocks.DummyLogger(t), "angular", "angular")
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, err.Status)
+		close(done)
+	}()
+	clock.BlockUntil(1)
+	cancel()
+	<-done
+}
+
+func TestSecondaryRateLimit(t *testing.T) {
+	t.Parallel()
+
+	clock := clockwork.NewFakeClockAt(time.Unix(1458966366, 0))
+	call := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call++
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintln(w, `{"message":"You have exceeded a secondary rate limit."}`)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Clock:   clock,
+		Token:   "deadbeef",
+	})
+	_, err := gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, err.Status)
+	assert.Equal(t, time.Minute, err.RetryAfter)
+
+	clock.Advance(30 * time.Second)
+	_, err = gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+	assert.Error(t, err)
+	assert.Equal(t, 30*time.Second, err.RetryAfter)
+	assert.Equal(t, 1, call)
+}
+
+const rateLimitJson string = `{
+	"resources": {
+		"core": {"limit": 5000, "remaining": 4321, "reset": 1458969687},
+		"search": {"limit": 30, "remaining": 30, "reset": 1458966426}
+	},
+	"rate": {"limit": 5000, "remaining": 4321, "reset": 1458969687}
+}`
+
+func TestGetRateLimit(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rate_limit", r.URL.String())
+		fmt.Fprintln(w, rateLimitJson)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	rateLimit, err := gh.GetRateLimit(context.Background(), mocks.DummyLogger(t))
+	assert.NoError(t, err)
+	assert.Equal(t, 5000, rateLimit.Limit)
+	assert.Equal(t, 4321, rateLimit.Remaining)
+	assert.Equal(t, time.Unix(1458969687, 0), rateLimit.Reset)
+}
+
+func TestMarshalRateLimit(t *testing.T) {
+	t.Parallel()
+
+	jsonBytes := mocks.MarshalJSON(t, &RateLimit{
+		Limit:     5000,
+		Remaining: 4321,
+		Reset:     time.Unix(1458966366, 892000000).UTC(),
+	})
+	var rateLimit map[string]interface{}
+	assert.NoError(t, json.Unmarshal(jsonBytes, &rateLimit))
+	assert.Equal(t, 5000.0, rateLimit["limit"].(float64))
+	assert.Equal(t, 4321.0, rateLimit["remaining"].(float64))
+	assert.Equal(t, "2016-03-26T04:26:06.892Z", rateLimit["reset"].(string))
+}
@@ -0,0 +1,157 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+)
+
+// Release is a Github release. Drafts have not been published, so their
+// PublishedAt is the zero time.
+type Release struct {
+	AssetDownloads int
+	HtmlUrl        string
+	IsDraft        bool
+	IsPrerelease   bool
+	Name           string
+	PublishedAt    time.Time
+	TagName        string
+}
+
+func (release *Release) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"asset_downloads": release.AssetDownloads,
+		"html_url":        release.HtmlUrl,
+		"is_draft":        release.IsDraft,
+		"is_prerelease":   release.IsPrerelease,
+		"name":            release.Name,
+		"published_at":    release.PublishedAt,
+		"tag_name":        release.TagName,
+	})
+}
+
+// Tag is a git tag along with the commit it points to.
+type Tag struct {
+	Name string
+	Sha  string
+}
+
+func (tag *Tag) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"name": tag.Name,
+		"sha":  tag.Sha,
+	})
+}
+
+type ListReleaseser interface {
+	ListReleases(context.Context, *log.Logger, string, string) ([]Release, *errors.HttpError)
+}
+
+type ListTagser interface {
+	ListTags(context.Context, *log.Logger, string, string) ([]Tag, *errors.HttpError)
+}
+
+func releasesKey(owner, repo string) string {
+	return fmt.Sprintf("github:repo:%s:%s:releases", owner, repo)
+}
+
+func tagsKey(owner, repo string) string {
+	return fmt.Sprintf("github:repo:%s:%s:tags", owner, repo)
+}
+
+func cleanReleaseJsons(releases []map[string]interface{}) {
+	for _, release := range releases {
+		keepKeys(
+			release,
+			"assets",
+			"draft",
+			"html_url",
+			"name",
+			"prerelease",
+			"published_at",
+			"tag_name",
+		)
+		if assets, ok := release["assets"].([]interface{}); ok {
+			for _, asset := range assets {
+				keepKeys(asset, "download_count")
+			}
+		}
+	}
+}
+
+func cleanTagJsons(tags []map[string]interface{}) {
+	for _, tag := range tags {
+		keepKeys(tag, "commit", "name")
+		keepKeys(tag["commit"], "sha")
+	}
+}
+
+func (gh *Client) ListReleases(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]Release, *errors.HttpError) {
+	ctx, cancel := gh.withDeadline(ctx)
+	defer cancel()
+	rawReleases, httpErr := redisWrap(
+		ctx,
+		gh,
+		releasesKey(owner, repo),
+		"releases",
+		logger,
+		func(ctx context.Context, _ []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			releases, httpErr := gh.paginateGithub(
+				ctx,
+				logger,
+				fmt.Sprintf("%s/repos/%s/%s/releases?per_page=100", gh.baseUrl, owner, repo),
+				"application/vnd.github.v3+json",
+			)
+			if httpErr != nil {
+				return nil, httpErr
+			}
+			cleanReleaseJsons(releases)
+			return releases, nil
+		},
+	)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return parseReleases(logger, rawReleases)
+}
+
+func (gh *Client) ListTags(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]Tag, *errors.HttpError) {
+	ctx, cancel := gh.withDeadline(ctx)
+	defer cancel()
+	rawTags, httpErr := redisWrap(
+		ctx,
+		gh,
+		tagsKey(owner, repo),
+		"tags",
+		logger,
+		func(ctx context.Context, _ []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			tags, httpErr := gh.paginateGithub(
+				ctx,
+				logger,
+				fmt.Sprintf("%s/repos/%s/%s/tags?per_page=100", gh.baseUrl, owner, repo),
+				"application/vnd.github.v3+json",
+			)
+			if httpErr != nil {
+				return nil, httpErr
+			}
+			cleanTagJsons(tags)
+			return tags, nil
+		},
+	)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return parseTags(logger, rawTags)
+}
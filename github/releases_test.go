@@ -0,0 +1,125 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+	"github.com/ksheedlo/ghviz/mocks"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const releasesJson string = `[{
+	"tag_name":"v2.0.0-beta.1",
+	"name":"",
+	"draft":false,
+	"prerelease":true,
+	"html_url":"https://github.com/lodash/lodash/releases/tag/v2.0.0-beta.1",
+	"published_at":"2016-04-01T12:00:00Z",
+	"author":{"login":"tester1","id":1},
+	"assets":[{"name":"lodash.js","download_count":12},{"name":"lodash.min.js","download_count":30}]
+}, {
+	"tag_name":"v1.0.0",
+	"name":"First Release",
+	"draft":false,
+	"prerelease":false,
+	"html_url":"https://github.com/lodash/lodash/releases/tag/v1.0.0",
+	"published_at":"2016-03-01T12:00:00Z",
+	"author":{"login":"tester1","id":1},
+	"assets":[]
+}, {
+	"tag_name":"v3.0.0",
+	"name":"Next",
+	"draft":true,
+	"prerelease":false,
+	"html_url":"https://github.com/lodash/lodash/releases/tag/untagged-1",
+	"published_at":null,
+	"author":null,
+	"assets":[]
+}]`
+
+func TestListReleases(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/lodash/lodash/releases?per_page=100", r.URL.String())
+		fmt.Fprintln(w, releasesJson)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	releases, err := gh.ListReleases(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, releases, 3)
+
+	assert.Equal(t, "v2.0.0-beta.1", releases[0].Name)
+	assert.Equal(t, "v2.0.0-beta.1", releases[0].TagName)
+	assert.True(t, releases[0].IsPrerelease)
+	assert.Equal(t, 42, releases[0].AssetDownloads)
+	assert.Equal(t, time.Date(2016, 4, 1, 12, 0, 0, 0, time.UTC), releases[0].PublishedAt.UTC())
+
+	assert.Equal(t, "First Release", releases[1].Name)
+	assert.Equal(t, 0, releases[1].AssetDownloads)
+
+	assert.True(t, releases[2].IsDraft)
+	assert.True(t, releases[2].PublishedAt.IsZero())
+}
+
+func TestListReleasesMissingTag(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `[{"name":"Mystery","published_at":"2016-04-01T12:00:00Z","assets":[]}]`)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	_, err := gh.ListReleases(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.Error(t, err)
+	assert.Equal(t, errors.CodeInvalidResponse, err.Code)
+}
+
+func TestListTags(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/lodash/lodash/tags?per_page=100", r.URL.String())
+		fmt.Fprintln(w, `[
+			{"name":"v1.0.0","commit":{"sha":"deadbeef","url":"https://api.github.com/commits/deadbeef"},"zipball_url":""},
+			{"name":"v0.9.0","commit":{"sha":"cafebabe","url":"https://api.github.com/commits/cafebabe"},"zipball_url":""}
+		]`)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	tags, err := gh.ListTags(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Equal(t, []Tag{Tag{Name: "v1.0.0", Sha: "deadbeef"}, Tag{Name: "v0.9.0", Sha: "cafebabe"}}, tags)
+}
+
+func TestMarshalRelease(t *testing.T) {
+	t.Parallel()
+
+	jsonBytes := mocks.MarshalJSON(t, &Release{
+		AssetDownloads: 42,
+		IsPrerelease:   true,
+		TagName:        "v1.0.0",
+	})
+	assert.Contains(t, string(jsonBytes), `"asset_downloads":42`)
+	assert.Contains(t, string(jsonBytes), `"is_prerelease":true`)
+	assert.Contains(t, string(jsonBytes), `"tag_name":"v1.0.0"`)
+}
@@ -0,0 +1,109 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/ksheedlo/ghviz/errors"
+)
+
+// Repository is a repo owned by an organization or a user.
+type Repository struct {
+	IsArchived bool
+	IsFork     bool
+	Name       string
+	Owner      string
+}
+
+func (repository *Repository) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"is_archived": repository.IsArchived,
+		"is_fork":     repository.IsFork,
+		"name":        repository.Name,
+		"owner":       repository.Owner,
+	})
+}
+
+// RepositoryFilter picks which of an owner's repos to aggregate over.
+// Archived repos and forks are skipped unless they are asked for.
+type RepositoryFilter struct {
+	IncludeArchived bool
+	IncludeForks    bool
+}
+
+func (filter RepositoryFilter) Matches(repository *Repository) bool {
+	return (filter.IncludeArchived || !repository.IsArchived) &&
+		(filter.IncludeForks || !repository.IsFork)
+}
+
+func FilterRepositories(repositories []Repository, filter RepositoryFilter) []Repository {
+	filtered := make([]Repository, 0, len(repositories))
+	for i := range repositories {
+		if filter.Matches(&repositories[i]) {
+			filtered = append(filtered, repositories[i])
+		}
+	}
+	return filtered
+}
+
+// ListRepositorieser lists every repo owned by an organization or a user.
+type ListRepositorieser interface {
+	ListRepositories(context.Context, *log.Logger, string) ([]Repository, *errors.HttpError)
+}
+
+func repositoriesKey(owner string) string {
+	return fmt.Sprintf("github:owner:%s:repos", owner)
+}
+
+func cleanRepositoryJsons(repositories []map[string]interface{}) {
+	for _, repository := range repositories {
+		keepKeys(repository, "archived", "fork", "name", "owner")
+		keepKeys(repository["owner"], "login")
+	}
+}
+
+func (gh *Client) ListRepositories(
+	ctx context.Context,
+	logger *log.Logger,
+	owner string,
+) ([]Repository, *errors.HttpError) {
+	ctx, cancel := gh.withDeadline(ctx)
+	defer cancel()
+	rawRepositories, httpErr := redisWrap(
+		ctx,
+		gh,
+		repositoriesKey(owner),
+		"repositories",
+		logger,
+		func(ctx context.Context, _ []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			repositories, httpErr := gh.paginateGithub(
+				ctx,
+				logger,
+				fmt.Sprintf("%s/orgs/%s/repos?per_page=100&type=all", gh.baseUrl, owner),
+				"application/vnd.github.v3+json",
+			)
+			// Github only knows the owner as an organization if it is one, so
+			// fall back to listing a user's repos.
+			if httpErr != nil && httpErr.Status == http.StatusNotFound {
+				repositories, httpErr = gh.paginateGithub(
+					ctx,
+					logger,
+					fmt.Sprintf("%s/users/%s/repos?per_page=100&type=owner", gh.baseUrl, owner),
+					"application/vnd.github.v3+json",
+				)
+			}
+			if httpErr != nil {
+				return nil, httpErr
+			}
+			cleanRepositoryJsons(repositories)
+			return repositories, nil
+		},
+	)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return parseRepositories(logger, rawRepositories)
+}
@@ -0,0 +1,100 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ksheedlo/ghviz/errors"
+	"github.com/ksheedlo/ghviz/mocks"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListRepositoriesOrg(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/orgs/lodash/repos?per_page=100&type=all", r.URL.String())
+		fmt.Fprintln(w, `[
+			{"name":"lodash","owner":{"login":"lodash"},"archived":false,"fork":false,"stargazers_count":5},
+			{"name":"lodash-cli","owner":{"login":"lodash"},"archived":true,"fork":false},
+			{"name":"babel","owner":{"login":"lodash"},"archived":false,"fork":true}
+		]`)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	repositories, err := gh.ListRepositories(context.Background(), mocks.DummyLogger(t), "lodash")
+	assert.NoError(t, err)
+	assert.Equal(t, []Repository{
+		Repository{Name: "lodash", Owner: "lodash"},
+		Repository{Name: "lodash-cli", Owner: "lodash", IsArchived: true},
+		Repository{Name: "babel", Owner: "lodash", IsFork: true},
+	}, repositories)
+}
+
+func TestListRepositoriesUser(t *testing.T) {
+	t.Parallel()
+
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.String())
+		if r.URL.Path == "/orgs/tester1/repos" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintln(w, `{"message":"Not Found"}`)
+			return
+		}
+		fmt.Fprintln(w, `[{"name":"coolrepo","owner":{"login":"tester1"},"archived":false,"fork":false}]`)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	repositories, err := gh.ListRepositories(context.Background(), mocks.DummyLogger(t), "tester1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"/orgs/tester1/repos?per_page=100&type=all",
+		"/users/tester1/repos?per_page=100&type=owner",
+	}, requested)
+	assert.Equal(t, []Repository{Repository{Name: "coolrepo", Owner: "tester1"}}, repositories)
+}
+
+func TestListRepositoriesNotFound(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintln(w, `{"message":"Not Found"}`)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	_, err := gh.ListRepositories(context.Background(), mocks.DummyLogger(t), "nobody")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, err.Status)
+	assert.Equal(t, errors.CodeNotFound, err.Code)
+}
+
+func TestFilterRepositories(t *testing.T) {
+	t.Parallel()
+
+	repositories := []Repository{
+		Repository{Name: "active"},
+		Repository{Name: "archived", IsArchived: true},
+		Repository{Name: "fork", IsFork: true},
+	}
+	assert.Equal(t, []Repository{Repository{Name: "active"}}, FilterRepositories(repositories, RepositoryFilter{}))
+	assert.Len(t, FilterRepositories(repositories, RepositoryFilter{IncludeArchived: true}), 2)
+	assert.Len(t, FilterRepositories(repositories, RepositoryFilter{IncludeArchived: true, IncludeForks: true}), 3)
+}
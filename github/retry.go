@@ -0,0 +1,178 @@
+package github
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+)
+
+const defaultRetryBaseDelay time.Duration = 500 * time.Millisecond
+const maxRetryDelay time.Duration = 30 * time.Second
+
+// defaultMaxStatsPolls bounds how many times we ask again for statistics that
+// Github is still computing.
+const defaultMaxStatsPolls int = 10
+
+// circuitBreaker stops us from hammering Github while it is having trouble.
+// Once failures reach the configured threshold in a row, the breaker opens
+// and requests fail fast until the cool-off period has passed. After that,
+// a single failure is enough to open it again.
+type circuitBreaker struct {
+	sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func circuitOpenError(retryAfter time.Duration) *errors.HttpError {
+	return &errors.HttpError{
+		Code:       errors.CodeUnavailable,
+		Message:    "Github Unavailable",
+		RetryAfter: retryAfter,
+		Status:     http.StatusServiceUnavailable,
+	}
+}
+
+// checkCircuit refuses the request while the breaker is open.
+func (gh *Client) checkCircuit(logger *log.Logger) *errors.HttpError {
+	if gh.breakerThreshold <= 0 {
+		return nil
+	}
+	gh.breaker.Lock()
+	defer gh.breaker.Unlock()
+	now := gh.clock.Now()
+	if now.Before(gh.breaker.openUntil) {
+		wait := gh.breaker.openUntil.Sub(now)
+		logger.Printf("Github circuit breaker is open for another %s, refusing request.\n", wait.String())
+		return circuitOpenError(wait)
+	}
+	return nil
+}
+
+func (gh *Client) isCircuitOpen() bool {
+	if gh.breakerThreshold <= 0 {
+		return false
+	}
+	gh.breaker.Lock()
+	defer gh.breaker.Unlock()
+	return gh.clock.Now().Before(gh.breaker.openUntil)
+}
+
+// recordUpstreamResult counts consecutive failures towards opening the circuit
+// breaker. Requests the caller canceled or timed out say nothing about how
+// Github is doing, so they are not counted either way.
+func (gh *Client) recordUpstreamResult(ctx context.Context, logger *log.Logger, failed bool) {
+	if gh.breakerThreshold <= 0 || ctx.Err() != nil {
+		return
+	}
+	gh.breaker.Lock()
+	defer gh.breaker.Unlock()
+	if !failed {
+		gh.breaker.failures = 0
+		return
+	}
+	gh.breaker.failures++
+	if gh.breaker.failures >= gh.breakerThreshold {
+		gh.breaker.openUntil = gh.clock.Now().Add(gh.breakerCoolOff)
+		logger.Printf(
+			"Github failed %d times in a row, opening the circuit breaker for %s.\n",
+			gh.breaker.failures,
+			gh.breakerCoolOff.String(),
+		)
+	}
+}
+
+// isRetryable tells transient upstream failures apart from errors that will
+// not go away by asking again, like rate limiting or the caller giving up.
+func isRetryable(ctx context.Context, resp *http.Response, httpErr *errors.HttpError) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if httpErr != nil {
+		return httpErr.Status == http.StatusBadGateway || httpErr.Status == http.StatusGatewayTimeout
+	}
+	switch resp.StatusCode {
+	case http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryDelay backs off exponentially from the base delay, with jitter so
+// concurrent page fetches do not retry in lockstep.
+func (gh *Client) retryDelay(attempt int) time.Duration {
+	delay := gh.retryBaseDelay << uint(attempt)
+	if delay <= 0 || delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func statsNotReadyError(retryAfter time.Duration) *errors.HttpError {
+	return &errors.HttpError{
+		Code:       errors.CodeNotReady,
+		Message:    "Github Is Still Computing Statistics",
+		RetryAfter: retryAfter,
+		Status:     http.StatusServiceUnavailable,
+	}
+}
+
+// sendGithubRequest sends an idempotent GET to Github, retrying transient
+// failures up to the configured number of times. The statistics endpoints
+// answer 202 Accepted with an empty body while Github computes them in the
+// background, so those are polled with backoff until the statistics are
+// ready.
+func (gh *Client) sendGithubRequest(
+	ctx context.Context,
+	logger *log.Logger,
+	url, mediaType string,
+	header http.Header,
+) (*http.Response, *errors.HttpError) {
+	attempt := 0
+	polls := 0
+	for {
+		if httpErr := gh.checkCircuit(logger); httpErr != nil {
+			return nil, httpErr
+		}
+		if httpErr := gh.awaitRateLimit(ctx, logger); httpErr != nil {
+			return nil, httpErr
+		}
+		resp, httpErr := gh.doGithubRequest(ctx, logger, url, mediaType, header)
+		retryable := isRetryable(ctx, resp, httpErr)
+		gh.recordUpstreamResult(ctx, logger, retryable)
+		var delay time.Duration
+		if httpErr == nil && resp.StatusCode == http.StatusAccepted {
+			resp.Body.Close()
+			delay = gh.retryDelay(polls)
+			if polls >= gh.maxStatsPolls {
+				logger.Printf("Github did not finish computing %s after %d polls.\n", url, polls)
+				return nil, statsNotReadyError(delay)
+			}
+			polls++
+			logger.Printf("Github is still computing %s, polling again in %s.\n", url, delay.String())
+		} else {
+			if !retryable || attempt >= gh.maxRetries || gh.isCircuitOpen() {
+				return resp, httpErr
+			}
+			if resp != nil {
+				logger.Printf("Github responded with status %d, retrying.\n", resp.StatusCode)
+				resp.Body.Close()
+			}
+			delay = gh.retryDelay(attempt)
+			attempt++
+			logger.Printf("Retrying %s in %s (attempt %d of %d).\n", url, delay.String(), attempt, gh.maxRetries)
+		}
+		select {
+		case <-gh.clock.After(delay):
+		case <-ctx.Done():
+			return nil, requestError(ctx, ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/mocks"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryTransientErrors(t *testing.T) {
+	t.Parallel()
+
+	var mutex sync.Mutex
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests++
+		attempt := requests
+		mutex.Unlock()
+		if attempt < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprintln(w, issuesJson)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl:        ts.URL,
+		MaxRetries:     3,
+		RetryBaseDelay: time.Millisecond,
+		Token:          "deadbeef",
+	})
+
+	allIssues, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, allIssues, 4)
+	assert.Equal(t, 3, requests)
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	t.Parallel()
+
+	var mutex sync.Mutex
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests++
+		mutex.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl:        ts.URL,
+		MaxRetries:     2,
+		RetryBaseDelay: time.Millisecond,
+		Token:          "deadbeef",
+	})
+
+	_, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.Error(t, err)
+	assert.Equal(t, 3, requests)
+}
+
+func TestRetrySkipsClientErrors(t *testing.T) {
+	t.Parallel()
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintln(w, `{"message":"Not Found"}`)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl:        ts.URL,
+		MaxRetries:     2,
+		RetryBaseDelay: time.Millisecond,
+		Token:          "deadbeef",
+	})
+
+	gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.Equal(t, 1, requests)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	clock := clockwork.NewFakeClockAt(time.Unix(1458966366, 0))
+	healthy := false
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if !healthy {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprintln(w, starsJson)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl:          ts.URL,
+		BreakerCoolOff:   time.Minute,
+		BreakerThreshold: 2,
+		Clock:            clock,
+		Token:            "deadbeef",
+	})
+
+	for i := 0; i < 2; i++ {
+		_, err := gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, 2, requests)
+
+	_, err := gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, err.Status)
+	assert.Equal(t, time.Minute, err.RetryAfter)
+	assert.Equal(t, 2, requests)
+
+	healthy = true
+	clock.Advance(time.Minute)
+	_, err = gh.ListStarEvents(context.Background(), mocks.DummyLogger(t), "angular", "angular")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, requests)
+}
+
+func TestCircuitBreakerIgnoresCanceledRequests(t *testing.T) {
+	t.Parallel()
+
+	var mutex sync.Mutex
+	hang := false
+	requests := 0
+	unblock := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests++
+		hanging := hang
+		mutex.Unlock()
+		if hanging {
+			<-unblock
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+	defer close(unblock)
+
+	gh := NewClient(&Options{
+		BaseUrl:          ts.URL,
+		BreakerCoolOff:   time.Minute,
+		BreakerThreshold: 2,
+		Token:            "deadbeef",
+	})
+	logger := mocks.DummyLogger(t)
+
+	_, err := gh.ListStarEvents(context.Background(), logger, "angular", "angular")
+	assert.Error(t, err)
+
+	// Giving up on a hanging request must not reset the failure count.
+	mutex.Lock()
+	hang = true
+	mutex.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = gh.sendGithubRequest(ctx, logger, ts.URL+"/repos/angular/angular/stargazers", "application/json", nil)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusGatewayTimeout, err.Status)
+
+	mutex.Lock()
+	hang = false
+	mutex.Unlock()
+	_, err = gh.ListStarEvents(context.Background(), logger, "angular", "angular")
+	assert.Error(t, err)
+	assert.True(t, gh.isCircuitOpen())
+	assert.Equal(t, 3, requests)
+}
+
+func TestCircuitBreakerServesStaleCache(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	redisMock := &mocks.MockRediser{}
+	gh := NewClient(&Options{
+		BaseUrl:          ts.URL,
+		BreakerCoolOff:   time.Minute,
+		BreakerThreshold: 1,
+		MaxStaleness:     5,
+		RedisClient:      redisMock,
+		Token:            "deadbeef",
+	})
+
+	cacheKey := "github:repo:lodash:lodash:issues"
+	expectPageCacheMiss(redisMock, ts.URL, lodashIssuesPath)
+	redisMock.On("Get", issuesSyncedAtKey("lodash", "lodash")).Return("", nil)
+	redisMock.On("Get", cacheKey).Return(
+		fmt.Sprintf("%d|%s", time.Now().Add(time.Duration(-6)*time.Minute).Unix(), issuesJson),
+		nil,
+	)
+
+	allIssues, err := gh.ListIssues(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, allIssues, 4)
+	redisMock.AssertExpectations(t)
+}
@@ -0,0 +1,124 @@
+package github
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+)
+
+// CacheStatus records whether anything served for a request came from a
+// stale cache entry.
+type CacheStatus struct {
+	sync.Mutex
+	oldest time.Time
+	stale  bool
+}
+
+// Stale reports whether a stale entry was served, and when the oldest stale
+// entry was cached.
+func (status *CacheStatus) Stale() (bool, time.Time) {
+	status.Lock()
+	defer status.Unlock()
+	return status.stale, status.oldest
+}
+
+// MarkStale records that an entry cached at cachedAt was served stale.
+func (status *CacheStatus) MarkStale(cachedAt time.Time) {
+	status.Lock()
+	defer status.Unlock()
+	if !status.stale || cachedAt.Before(status.oldest) {
+		status.oldest = cachedAt
+	}
+	status.stale = true
+}
+
+type cacheStatusKey struct{}
+
+// WithCacheStatus returns a context that makes the client record in status
+// whenever it serves a stale cache entry.
+func WithCacheStatus(ctx context.Context, status *CacheStatus) context.Context {
+	return context.WithValue(ctx, cacheStatusKey{}, status)
+}
+
+func markStale(ctx context.Context, cachedAt time.Time) {
+	if status, ok := ctx.Value(cacheStatusKey{}).(*CacheStatus); ok {
+		status.MarkStale(cachedAt)
+	}
+}
+
+func canServeStale(gh *Client, timeSubmitted time.Time) bool {
+	return gh.maxStaleAge > 0 && gh.clock.Now().Sub(timeSubmitted) <= gh.maxStaleAge
+}
+
+// detachedContext keeps the values of the context it was made from, like the
+// request's logger and cache status, but never expires or gets canceled.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (ctx detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (ctx detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (ctx detachedContext) Err() error {
+	return nil
+}
+
+func (ctx detachedContext) Value(key interface{}) interface{} {
+	return ctx.parent.Value(key)
+}
+
+// detach returns a context for work that has to outlive the caller's.
+func detach(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+type revalidationState struct {
+	sync.Mutex
+	keys map[string]bool
+}
+
+// revalidate refreshes a stale cache entry in the background. The refresh
+// outlives the request that found the entry stale, and only one refresh of a
+// key runs at a time.
+func (gh *Client) revalidate(
+	ctx context.Context,
+	cacheKey string,
+	logger *log.Logger,
+	staleItems []map[string]interface{},
+	fallback func(context.Context, []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError),
+	onStored func(context.Context),
+) {
+	gh.revalidating.Lock()
+	if gh.revalidating.keys == nil {
+		gh.revalidating.keys = make(map[string]bool)
+	}
+	if gh.revalidating.keys[cacheKey] {
+		gh.revalidating.Unlock()
+		return
+	}
+	gh.revalidating.keys[cacheKey] = true
+	gh.revalidating.Unlock()
+
+	go func() {
+		defer func() {
+			gh.revalidating.Lock()
+			delete(gh.revalidating.keys, cacheKey)
+			gh.revalidating.Unlock()
+		}()
+		ctx, cancel := gh.withDeadline(detach(ctx))
+		defer cancel()
+		if _, httpErr := fetchShared(ctx, gh, cacheKey, logger, staleItems, fallback, onStored); httpErr != nil {
+			logger.Printf("Failed to refresh %s from Github: %s\n", cacheKey, httpErr.Error())
+			return
+		}
+		logger.Printf("Refreshed %s from Github.\n", cacheKey)
+	}()
+}
@@ -0,0 +1,180 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+	"github.com/ksheedlo/ghviz/mocks"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const lodashForksPath string = "/repos/lodash/lodash/forks?per_page=100&sort=oldest"
+
+func TestRedisWrapServesStaleWhileRevalidating(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, lodashForksPath, r.URL.String())
+		fmt.Fprintln(w, `[{"created_at":"2016-03-07T03:26:14Z"},{"created_at":"2016-03-08T03:26:14Z"}]`)
+	}))
+	defer ts.Close()
+
+	redisMock := &mocks.MockRediser{}
+	gh := NewClient(&Options{
+		BaseUrl:      ts.URL,
+		MaxStaleAge:  time.Hour,
+		MaxStaleness: 5,
+		RedisClient:  redisMock,
+		Token:        "deadbeef",
+	})
+	cachedAt := time.Now().Add(-6 * time.Minute).Truncate(time.Second)
+	redisMock.On("Get", forksKey("lodash", "lodash")).Return(
+		fmt.Sprintf(`%d|[{"created_at":"2016-03-07T03:26:14Z"}]`, cachedAt.Unix()),
+		nil,
+	)
+	expectPageCacheMiss(redisMock, ts.URL, lodashForksPath)
+	refreshed := make(chan struct{})
+	redisMock.
+		On("Set", forksKey("lodash", "lodash"), "", time.Duration(0)).
+		Return(nil).
+		Run(func(mock.Arguments) { close(refreshed) })
+
+	status := &CacheStatus{}
+	ctx, cancel := context.WithCancel(WithCacheStatus(context.Background(), status))
+	forkEvents, err := gh.ListForks(ctx, mocks.DummyLogger(t), "lodash", "lodash")
+	// The refresh has to outlive the request.
+	cancel()
+	assert.NoError(t, err)
+	assert.Len(t, forkEvents, 1)
+	stale, oldest := status.Stale()
+	assert.True(t, stale)
+	assert.Equal(t, cachedAt, oldest)
+
+	select {
+	case <-refreshed:
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "The stale key was never refreshed!")
+	}
+	redisMock.AssertExpectations(t)
+}
+
+func TestRedisWrapRefetchesPastMaxStaleAge(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `[{"created_at":"2016-03-07T03:26:14Z"},{"created_at":"2016-03-08T03:26:14Z"}]`)
+	}))
+	defer ts.Close()
+
+	redisMock := &mocks.MockRediser{}
+	gh := NewClient(&Options{
+		BaseUrl:      ts.URL,
+		MaxStaleAge:  time.Hour,
+		MaxStaleness: 5,
+		RedisClient:  redisMock,
+		Token:        "deadbeef",
+	})
+	redisMock.On("Get", forksKey("lodash", "lodash")).Return(
+		fmt.Sprintf(`%d|[{"created_at":"2016-03-07T03:26:14Z"}]`, time.Now().Add(-2*time.Hour).Unix()),
+		nil,
+	)
+	expectPageCacheMiss(redisMock, ts.URL, lodashForksPath)
+	redisMock.On("Set", forksKey("lodash", "lodash"), "", time.Duration(0)).Return(nil)
+
+	status := &CacheStatus{}
+	forkEvents, err := gh.ListForks(WithCacheStatus(context.Background(), status), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, forkEvents, 2)
+	stale, _ := status.Stale()
+	assert.False(t, stale)
+	redisMock.AssertExpectations(t)
+}
+
+func TestRevalidateRunsOncePerKey(t *testing.T) {
+	t.Parallel()
+
+	redisMock := &mocks.MockRediser{}
+	gh := NewClient(&Options{RedisClient: redisMock})
+	logger := mocks.DummyLogger(t)
+	refreshed := make(chan struct{})
+	redisMock.
+		On("Set", "key", "", time.Duration(0)).
+		Return(nil).
+		Run(func(mock.Arguments) { close(refreshed) })
+
+	release := make(chan struct{})
+	gh.revalidate(context.Background(), "key", logger, nil, func(context.Context, []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+		<-release
+		return []map[string]interface{}{}, nil
+	}, nil)
+	gh.revalidate(context.Background(), "key", logger, nil, func(context.Context, []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+		assert.Fail(t, "The key should only be refreshed once at a time!")
+		return nil, nil
+	}, nil)
+	close(release)
+
+	select {
+	case <-refreshed:
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "The key was never refreshed!")
+	}
+}
+
+func TestCacheStatusKeepsOldest(t *testing.T) {
+	t.Parallel()
+
+	status := &CacheStatus{}
+	stale, _ := status.Stale()
+	assert.False(t, stale)
+
+	status.MarkStale(time.Unix(20, 0))
+	status.MarkStale(time.Unix(10, 0))
+	status.MarkStale(time.Unix(30, 0))
+	stale, oldest := status.Stale()
+	assert.True(t, stale)
+	assert.Equal(t, time.Unix(10, 0), oldest)
+}
+
+func TestStalenessFollowsClock(t *testing.T) {
+	t.Parallel()
+
+	clock := clockwork.NewFakeClock()
+	gh := NewClient(&Options{
+		Clock:        clock,
+		MaxStaleAge:  time.Hour,
+		MaxStaleness: 5,
+	})
+	cachedAt := clock.Now()
+	assert.False(t, isStale(gh, cachedAt))
+
+	clock.Advance(6 * time.Minute)
+	assert.True(t, isStale(gh, cachedAt))
+	assert.True(t, canServeStale(gh, cachedAt))
+
+	clock.Advance(time.Hour)
+	assert.False(t, canServeStale(gh, cachedAt))
+}
+
+func TestDetachKeepsValues(t *testing.T) {
+	t.Parallel()
+
+	status := &CacheStatus{}
+	ctx, cancel := context.WithTimeout(WithCacheStatus(context.Background(), status), time.Minute)
+	detached := detach(ctx)
+	cancel()
+
+	assert.Error(t, ctx.Err())
+	assert.NoError(t, detached.Err())
+	assert.Nil(t, detached.Done())
+	_, hasDeadline := detached.Deadline()
+	assert.False(t, hasDeadline)
+	assert.Equal(t, status, detached.Value(cacheStatusKey{}))
+}
@@ -0,0 +1,203 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+)
+
+// WeeklyStats is how much one contributor changed a repo in the week
+// starting at Week.
+type WeeklyStats struct {
+	Additions int
+	Commits   int
+	Deletions int
+	Week      time.Time
+}
+
+func (stats *WeeklyStats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"additions": stats.Additions,
+		"commits":   stats.Commits,
+		"deletions": stats.Deletions,
+		"week":      stats.Week,
+	})
+}
+
+// ContributorStats holds the weeks in which a contributor changed a repo.
+// Weeks without any changes are left out.
+type ContributorStats struct {
+	Author       string
+	TotalCommits int
+	Weeks        []WeeklyStats
+}
+
+func (stats *ContributorStats) MarshalJSON() ([]byte, error) {
+	weeks := stats.Weeks
+	if weeks == nil {
+		weeks = []WeeklyStats{}
+	}
+	return json.Marshal(map[string]interface{}{
+		"author":        stats.Author,
+		"total_commits": stats.TotalCommits,
+		"weeks":         weeks,
+	})
+}
+
+// CodeFrequency is how many lines were added to and deleted from a repo in
+// the week starting at Week.
+type CodeFrequency struct {
+	Additions int
+	Deletions int
+	Week      time.Time
+}
+
+func (frequency *CodeFrequency) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"additions": frequency.Additions,
+		"deletions": frequency.Deletions,
+		"week":      frequency.Week,
+	})
+}
+
+type ListContributorStatser interface {
+	ListContributorStats(context.Context, *log.Logger, string, string) ([]ContributorStats, *errors.HttpError)
+}
+
+type ListCodeFrequencyer interface {
+	ListCodeFrequency(context.Context, *log.Logger, string, string) ([]CodeFrequency, *errors.HttpError)
+}
+
+func contributorStatsKey(owner, repo string) string {
+	return fmt.Sprintf("github:repo:%s:%s:contributor_stats", owner, repo)
+}
+
+func codeFrequencyKey(owner, repo string) string {
+	return fmt.Sprintf("github:repo:%s:%s:code_frequency", owner, repo)
+}
+
+// fetchStats fetches one of the statistics endpoints. They are not paginated,
+// and Github answers 204 No Content for repos without any commits.
+func (gh *Client) fetchStats(
+	ctx context.Context,
+	logger *log.Logger,
+	url string,
+	stats interface{},
+) *errors.HttpError {
+	contents, _, httpErr := gh.fetchGithubPage(ctx, logger, url, "application/vnd.github.v3+json")
+	if httpErr != nil {
+		return httpErr
+	}
+	if len(bytes.TrimSpace(contents)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(contents, stats); err != nil {
+		return schemaError(logger, err)
+	}
+	return nil
+}
+
+func cleanContributorStatsJsons(contributors []map[string]interface{}) {
+	for _, contributor := range contributors {
+		keepKeys(contributor, "author", "total", "weeks")
+		keepKeys(contributor["author"], "login")
+		weeks, ok := contributor["weeks"].([]interface{})
+		if !ok {
+			continue
+		}
+		// Github reports every week since the repo was created for every
+		// contributor, so most of them are empty.
+		activeWeeks := make([]interface{}, 0)
+		for _, week := range weeks {
+			counts, ok := week.(map[string]interface{})
+			if ok && counts["a"] == 0.0 && counts["d"] == 0.0 && counts["c"] == 0.0 {
+				continue
+			}
+			activeWeeks = append(activeWeeks, week)
+		}
+		contributor["weeks"] = activeWeeks
+	}
+}
+
+func (gh *Client) ListContributorStats(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]ContributorStats, *errors.HttpError) {
+	ctx, cancel := gh.withDeadline(ctx)
+	defer cancel()
+	rawContributors, httpErr := redisWrap(
+		ctx,
+		gh,
+		contributorStatsKey(owner, repo),
+		"contributor stats",
+		logger,
+		func(ctx context.Context, _ []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			contributors := make([]map[string]interface{}, 0)
+			if httpErr := gh.fetchStats(
+				ctx,
+				logger,
+				fmt.Sprintf("%s/repos/%s/%s/stats/contributors", gh.baseUrl, owner, repo),
+				&contributors,
+			); httpErr != nil {
+				return nil, httpErr
+			}
+			cleanContributorStatsJsons(contributors)
+			return contributors, nil
+		},
+	)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return parseContributorStats(logger, rawContributors)
+}
+
+func (gh *Client) ListCodeFrequency(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]CodeFrequency, *errors.HttpError) {
+	ctx, cancel := gh.withDeadline(ctx)
+	defer cancel()
+	rawFrequencies, httpErr := redisWrap(
+		ctx,
+		gh,
+		codeFrequencyKey(owner, repo),
+		"code frequencies",
+		logger,
+		func(ctx context.Context, _ []map[string]interface{}) ([]map[string]interface{}, *errors.HttpError) {
+			var weeks [][]int64
+			if httpErr := gh.fetchStats(
+				ctx,
+				logger,
+				fmt.Sprintf("%s/repos/%s/%s/stats/code_frequency", gh.baseUrl, owner, repo),
+				&weeks,
+			); httpErr != nil {
+				return nil, httpErr
+			}
+			// Github sends each week as a [week, additions, deletions] tuple,
+			// which we turn into an object so it can be cached like the rest.
+			frequencies := make([]map[string]interface{}, len(weeks))
+			for i, week := range weeks {
+				if len(week) != 3 {
+					return nil, schemaError(logger, fmt.Errorf("code frequency week %d has %d fields", i, len(week)))
+				}
+				frequencies[i] = map[string]interface{}{
+					"additions": float64(week[1]),
+					"deletions": float64(week[2]),
+					"week":      float64(week[0]),
+				}
+			}
+			return frequencies, nil
+		},
+	)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return parseCodeFrequencies(logger, rawFrequencies)
+}
@@ -0,0 +1,143 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+	"github.com/ksheedlo/ghviz/mocks"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const contributorStatsJson string = `[{
+	"author":{"login":"tester1","id":1},
+	"total":3,
+	"weeks":[
+		{"w":1457222400,"a":10,"d":2,"c":2},
+		{"w":1457827200,"a":0,"d":0,"c":0},
+		{"w":1458432000,"a":5,"d":0,"c":1}
+	]
+}, {
+	"author":null,
+	"total":1,
+	"weeks":[{"w":1457222400,"a":1,"d":1,"c":1}]
+}]`
+
+func TestListContributorStatsPollsUntilReady(t *testing.T) {
+	t.Parallel()
+
+	var mutex sync.Mutex
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/lodash/lodash/stats/contributors", r.URL.String())
+		mutex.Lock()
+		requests++
+		attempt := requests
+		mutex.Unlock()
+		if attempt < 3 {
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprintln(w, `{}`)
+			return
+		}
+		fmt.Fprintln(w, contributorStatsJson)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl:        ts.URL,
+		RetryBaseDelay: time.Millisecond,
+		Token:          "deadbeef",
+	})
+	contributors, err := gh.ListContributorStats(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, requests)
+	assert.Len(t, contributors, 2)
+
+	assert.Equal(t, "tester1", contributors[0].Author)
+	assert.Equal(t, 3, contributors[0].TotalCommits)
+	assert.Equal(t, []WeeklyStats{
+		WeeklyStats{Additions: 10, Commits: 2, Deletions: 2, Week: time.Date(2016, 3, 6, 0, 0, 0, 0, time.UTC)},
+		WeeklyStats{Additions: 5, Commits: 1, Deletions: 0, Week: time.Date(2016, 3, 20, 0, 0, 0, 0, time.UTC)},
+	}, contributors[0].Weeks)
+	assert.Equal(t, "ghost", contributors[1].Author)
+}
+
+func TestListContributorStatsGivesUpPolling(t *testing.T) {
+	t.Parallel()
+
+	var mutex sync.Mutex
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests++
+		mutex.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl:        ts.URL,
+		MaxStatsPolls:  2,
+		RetryBaseDelay: time.Millisecond,
+		Token:          "deadbeef",
+	})
+	_, err := gh.ListContributorStats(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.Error(t, err)
+	assert.Equal(t, 3, requests)
+	assert.Equal(t, http.StatusServiceUnavailable, err.Status)
+	assert.Equal(t, errors.CodeNotReady, err.Code)
+	assert.True(t, err.RetryAfter > 0)
+}
+
+func TestListContributorStatsEmptyRepo(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	contributors, err := gh.ListContributorStats(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Len(t, contributors, 0)
+}
+
+func TestListCodeFrequency(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/lodash/lodash/stats/code_frequency", r.URL.String())
+		fmt.Fprintln(w, `[[1457222400,1124,-435],[1457827200,0,0]]`)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	frequencies, err := gh.ListCodeFrequency(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Equal(t, []CodeFrequency{
+		CodeFrequency{Additions: 1124, Deletions: 435, Week: time.Date(2016, 3, 6, 0, 0, 0, 0, time.UTC)},
+		CodeFrequency{Additions: 0, Deletions: 0, Week: time.Date(2016, 3, 13, 0, 0, 0, 0, time.UTC)},
+	}, frequencies)
+}
+
+func TestMarshalContributorStats(t *testing.T) {
+	t.Parallel()
+
+	jsonBytes := mocks.MarshalJSON(t, &ContributorStats{Author: "tester1", TotalCommits: 3})
+	assert.Contains(t, string(jsonBytes), `"author":"tester1"`)
+	assert.Contains(t, string(jsonBytes), `"total_commits":3`)
+	assert.Contains(t, string(jsonBytes), `"weeks":[]`)
+}
@@ -0,0 +1,166 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+)
+
+// TrafficCount is the number of views or clones of a repo on one day.
+type TrafficCount struct {
+	Count     int
+	Timestamp time.Time
+	Uniques   int
+}
+
+func (tc *TrafficCount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"count":     tc.Count,
+		"timestamp": tc.Timestamp,
+		"uniques":   tc.Uniques,
+	})
+}
+
+type Referrer struct {
+	Count    int
+	Referrer string
+	Uniques  int
+}
+
+func (referrer *Referrer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"count":    referrer.Count,
+		"referrer": referrer.Referrer,
+		"uniques":  referrer.Uniques,
+	})
+}
+
+type PopularPath struct {
+	Count   int
+	Path    string
+	Title   string
+	Uniques int
+}
+
+func (path *PopularPath) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"count":   path.Count,
+		"path":    path.Path,
+		"title":   path.Title,
+		"uniques": path.Uniques,
+	})
+}
+
+// Traffic is what Github reports about the traffic to a repo. Views and
+// clones are daily counts over the last 14 days, while referrers and paths
+// are totals over the same 14 days.
+type Traffic struct {
+	Clones    []TrafficCount
+	Paths     []PopularPath
+	Referrers []Referrer
+	Views     []TrafficCount
+}
+
+type GetTrafficer interface {
+	GetTraffic(context.Context, *log.Logger, string, string) (*Traffic, *errors.HttpError)
+}
+
+type trafficCountPayload struct {
+	Count     int    `json:"count"`
+	Timestamp string `json:"timestamp"`
+	Uniques   int    `json:"uniques"`
+}
+
+func parseTrafficCounts(logger *log.Logger, payloads []trafficCountPayload) ([]TrafficCount, *errors.HttpError) {
+	counts := make([]TrafficCount, len(payloads))
+	for i, payload := range payloads {
+		timestamp, httpErr := parseTimestamp(logger, "timestamp", payload.Timestamp)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+		counts[i].Count = payload.Count
+		counts[i].Timestamp = timestamp
+		counts[i].Uniques = payload.Uniques
+	}
+	return counts, nil
+}
+
+// GetTraffic fetches the traffic Github has kept for a repo. It needs push
+// access to the repo, so Github forbids it for most tokens.
+func (gh *Client) GetTraffic(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) (*Traffic, *errors.HttpError) {
+	ctx, cancel := gh.withDeadline(ctx)
+	defer cancel()
+	trafficUrl := fmt.Sprintf("%s/repos/%s/%s/traffic", gh.baseUrl, owner, repo)
+	var views struct {
+		Views []trafficCountPayload `json:"views"`
+	}
+	var clones struct {
+		Clones []trafficCountPayload `json:"clones"`
+	}
+	var referrers []struct {
+		Count    int    `json:"count"`
+		Referrer string `json:"referrer"`
+		Uniques  int    `json:"uniques"`
+	}
+	var paths []struct {
+		Count   int    `json:"count"`
+		Path    string `json:"path"`
+		Title   string `json:"title"`
+		Uniques int    `json:"uniques"`
+	}
+	payloads := []interface{}{&views, &clones, &referrers, &paths}
+	httpErr := gh.fetchEach(
+		ctx,
+		logger,
+		[]string{
+			fmt.Sprintf("%s/views?per=day", trafficUrl),
+			fmt.Sprintf("%s/clones?per=day", trafficUrl),
+			fmt.Sprintf("%s/popular/referrers", trafficUrl),
+			fmt.Sprintf("%s/popular/paths", trafficUrl),
+		},
+		"application/vnd.github.v3+json",
+		func(i int, contents []byte) *errors.HttpError {
+			if err := json.Unmarshal(contents, payloads[i]); err != nil {
+				return schemaError(logger, err)
+			}
+			return nil
+		},
+	)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	traffic := &Traffic{}
+	if traffic.Views, httpErr = parseTrafficCounts(logger, views.Views); httpErr != nil {
+		return nil, httpErr
+	}
+	if traffic.Clones, httpErr = parseTrafficCounts(logger, clones.Clones); httpErr != nil {
+		return nil, httpErr
+	}
+	traffic.Referrers = make([]Referrer, len(referrers))
+	for i, referrer := range referrers {
+		traffic.Referrers[i] = Referrer{
+			Count:    referrer.Count,
+			Referrer: referrer.Referrer,
+			Uniques:  referrer.Uniques,
+		}
+	}
+	traffic.Paths = make([]PopularPath, len(paths))
+	for i, path := range paths {
+		traffic.Paths[i] = PopularPath{
+			Count:   path.Count,
+			Path:    path.Path,
+			Title:   path.Title,
+			Uniques: path.Uniques,
+		}
+	}
+	return traffic, nil
+}
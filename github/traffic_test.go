@@ -0,0 +1,75 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+	"github.com/ksheedlo/ghviz/mocks"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTraffic(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.String() {
+		case "/repos/lodash/lodash/traffic/views?per=day":
+			fmt.Fprintln(w, `{"count":15,"uniques":4,"views":[
+				{"timestamp":"2016-03-07T00:00:00Z","count":10,"uniques":3},
+				{"timestamp":"2016-03-08T00:00:00Z","count":5,"uniques":2}]}`)
+		case "/repos/lodash/lodash/traffic/clones?per=day":
+			fmt.Fprintln(w, `{"count":2,"uniques":1,"clones":[
+				{"timestamp":"2016-03-08T00:00:00Z","count":2,"uniques":1}]}`)
+		case "/repos/lodash/lodash/traffic/popular/referrers":
+			fmt.Fprintln(w, `[{"referrer":"google.com","count":8,"uniques":3}]`)
+		case "/repos/lodash/lodash/traffic/popular/paths":
+			fmt.Fprintln(w, `[{"path":"/lodash/lodash","title":"lodash/lodash","count":12,"uniques":4}]`)
+		default:
+			assert.FailNow(t, fmt.Sprintf("Unexpected request for %s", r.URL.String()))
+		}
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	traffic, err := gh.GetTraffic(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.NoError(t, err)
+	assert.Equal(t, []TrafficCount{
+		TrafficCount{Count: 10, Timestamp: time.Date(2016, 3, 7, 0, 0, 0, 0, time.UTC), Uniques: 3},
+		TrafficCount{Count: 5, Timestamp: time.Date(2016, 3, 8, 0, 0, 0, 0, time.UTC), Uniques: 2},
+	}, traffic.Views)
+	assert.Equal(t, []TrafficCount{
+		TrafficCount{Count: 2, Timestamp: time.Date(2016, 3, 8, 0, 0, 0, 0, time.UTC), Uniques: 1},
+	}, traffic.Clones)
+	assert.Equal(t, []Referrer{Referrer{Count: 8, Referrer: "google.com", Uniques: 3}}, traffic.Referrers)
+	assert.Equal(t, []PopularPath{
+		PopularPath{Count: 12, Path: "/lodash/lodash", Title: "lodash/lodash", Uniques: 4},
+	}, traffic.Paths)
+}
+
+func TestGetTrafficForbidden(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintln(w, `{"message":"Must have push access to repository"}`)
+	}))
+	defer ts.Close()
+
+	gh := NewClient(&Options{
+		BaseUrl: ts.URL,
+		Token:   "deadbeef",
+	})
+	_, err := gh.GetTraffic(context.Background(), mocks.DummyLogger(t), "lodash", "lodash")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, err.Status)
+	assert.Equal(t, errors.CodeForbidden, err.Code)
+}
@@ -0,0 +1,349 @@
+package interfaces
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltStringsBucket = []byte("strings")
+	boltZsetsBucket   = []byte("zsets")
+	boltMembersBucket = []byte("members")
+	boltScoresBucket  = []byte("scores")
+)
+
+// boltLockTimeout bounds how long opening the file waits for another process
+// to let go of it.
+const boltLockTimeout time.Duration = 5 * time.Second
+
+// BoltRedis is a Rediser that keeps everything in a bbolt file, so the cache
+// survives restarts without running Redis. Strings are stored along with when
+// they expire and are dropped lazily. Each sorted set is a bucket holding its
+// members' scores, and an index of its members ordered by score.
+//
+// The file belongs to a single process, which keeps it open until Close.
+// bbolt locks it in the meantime, so other processes cannot open it. Use
+// Redis to share a cache between services.
+type BoltRedis struct {
+	clock clockwork.Clock
+	db    *bolt.DB
+}
+
+func OpenBoltRedis(clock clockwork.Clock, path string) (*BoltRedis, error) {
+	return openBoltRedis(clock, path, boltLockTimeout)
+}
+
+func openBoltRedis(clock clockwork.Clock, path string, lockTimeout time.Duration) (*BoltRedis, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: lockTimeout})
+	if err == bolt.ErrTimeout {
+		return nil, fmt.Errorf("%s is in use by another process", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(boltStringsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(boltZsetsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltRedis{clock: clock, db: db}, nil
+}
+
+func (br *BoltRedis) Close() error {
+	return br.db.Close()
+}
+
+// encodeBoltString prefixes the value with when it expires, in nanoseconds
+// since the epoch, or 0 if it never does.
+func encodeBoltString(value string, expiresAt time.Time) []byte {
+	encoded := make([]byte, 8+len(value))
+	if !expiresAt.IsZero() {
+		binary.BigEndian.PutUint64(encoded, uint64(expiresAt.UnixNano()))
+	}
+	copy(encoded[8:], value)
+	return encoded
+}
+
+func (br *BoltRedis) decodeBoltString(encoded []byte) (string, bool) {
+	if len(encoded) < 8 {
+		return "", false
+	}
+	expiresAt := int64(binary.BigEndian.Uint64(encoded))
+	if expiresAt != 0 && br.clock.Now().UnixNano() >= expiresAt {
+		return "", false
+	}
+	return string(encoded[8:]), true
+}
+
+// encodeScore encodes a score so that encoded scores sort bytewise in the
+// same order as the scores themselves.
+func encodeScore(score float64) []byte {
+	bits := math.Float64bits(score)
+	if score < 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, bits)
+	return encoded
+}
+
+func decodeScore(encoded []byte) float64 {
+	bits := binary.BigEndian.Uint64(encoded)
+	if bits&(1<<63) != 0 {
+		bits &^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return math.Float64frombits(bits)
+}
+
+func (br *BoltRedis) Del(ctx context.Context, key string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	var deleted int64
+	err := br.db.Update(func(tx *bolt.Tx) error {
+		strings := tx.Bucket(boltStringsBucket)
+		if encoded := strings.Get([]byte(key)); encoded != nil {
+			if _, ok := br.decodeBoltString(encoded); ok {
+				deleted = 1
+			}
+			return strings.Delete([]byte(key))
+		}
+		zsets := tx.Bucket(boltZsetsBucket)
+		if zsets.Bucket([]byte(key)) != nil {
+			deleted = 1
+			return zsets.DeleteBucket([]byte(key))
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+func (br *BoltRedis) DelIfEqual(ctx context.Context, key, value string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	var deleted bool
+	err := br.db.Update(func(tx *bolt.Tx) error {
+		strings := tx.Bucket(boltStringsBucket)
+		encoded := strings.Get([]byte(key))
+		if encoded == nil {
+			return nil
+		}
+		if current, ok := br.decodeBoltString(encoded); !ok || current != value {
+			return nil
+		}
+		deleted = true
+		return strings.Delete([]byte(key))
+	})
+	if err != nil {
+		return false, err
+	}
+	return deleted, nil
+}
+
+func (br *BoltRedis) Get(ctx context.Context, key string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	var value string
+	var found, expired, isZset bool
+	err := br.db.View(func(tx *bolt.Tx) error {
+		encoded := tx.Bucket(boltStringsBucket).Get([]byte(key))
+		if encoded == nil {
+			isZset = tx.Bucket(boltZsetsBucket).Bucket([]byte(key)) != nil
+			return nil
+		}
+		value, found = br.decodeBoltString(encoded)
+		expired = !found
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if isZset {
+		return "", ErrWrongType
+	}
+	if expired {
+		// Drop the expired value, unless someone replaced it in the meantime.
+		err = br.db.Update(func(tx *bolt.Tx) error {
+			strings := tx.Bucket(boltStringsBucket)
+			if encoded := strings.Get([]byte(key)); encoded != nil {
+				if _, ok := br.decodeBoltString(encoded); !ok {
+					return strings.Delete([]byte(key))
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	if !found {
+		return "", ErrNil
+	}
+	return value, nil
+}
+
+func (br *BoltRedis) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = br.clock.Now().Add(ttl)
+	}
+	return br.db.Update(func(tx *bolt.Tx) error {
+		// Like Redis, setting a key replaces whatever it held before.
+		zsets := tx.Bucket(boltZsetsBucket)
+		if zsets.Bucket([]byte(key)) != nil {
+			if err := zsets.DeleteBucket([]byte(key)); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(boltStringsBucket).Put([]byte(key), encodeBoltString(value, expiresAt))
+	})
+}
+
+func (br *BoltRedis) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = br.clock.Now().Add(ttl)
+	}
+	var isSet bool
+	err := br.db.Update(func(tx *bolt.Tx) error {
+		strings := tx.Bucket(boltStringsBucket)
+		if encoded := strings.Get([]byte(key)); encoded != nil {
+			if _, ok := br.decodeBoltString(encoded); ok {
+				return nil
+			}
+		}
+		if tx.Bucket(boltZsetsBucket).Bucket([]byte(key)) != nil {
+			return nil
+		}
+		isSet = true
+		return strings.Put([]byte(key), encodeBoltString(value, expiresAt))
+	})
+	if err != nil {
+		return false, err
+	}
+	return isSet, nil
+}
+
+func (br *BoltRedis) ZAdd(ctx context.Context, key string, members ...ZZ) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	var added int64
+	err := br.db.Update(func(tx *bolt.Tx) error {
+		strings := tx.Bucket(boltStringsBucket)
+		if encoded := strings.Get([]byte(key)); encoded != nil {
+			if _, ok := br.decodeBoltString(encoded); ok {
+				return ErrWrongType
+			}
+			if err := strings.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		zset, err := tx.Bucket(boltZsetsBucket).CreateBucketIfNotExists([]byte(key))
+		if err != nil {
+			return err
+		}
+		memberScores, err := zset.CreateBucketIfNotExists(boltMembersBucket)
+		if err != nil {
+			return err
+		}
+		scoreIndex, err := zset.CreateBucketIfNotExists(boltScoresBucket)
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			memberBytes := []byte(memberString(member.Member))
+			if oldScore := memberScores.Get(memberBytes); oldScore != nil {
+				if err := scoreIndex.Delete(append(append([]byte{}, oldScore...), memberBytes...)); err != nil {
+					return err
+				}
+			} else {
+				added++
+			}
+			score := encodeScore(member.Score)
+			if err := memberScores.Put(memberBytes, score); err != nil {
+				return err
+			}
+			if err := scoreIndex.Put(append(score, memberBytes...), []byte{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+func (br *BoltRedis) ZRangeByScore(
+	ctx context.Context,
+	key string,
+	opts *ZRangeByScoreOpts,
+) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	min, minExclusive, err := parseScoreBound(opts.Min)
+	if err != nil {
+		return nil, err
+	}
+	max, maxExclusive, err := parseScoreBound(opts.Max)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]string, 0)
+	err = br.db.View(func(tx *bolt.Tx) error {
+		zset := tx.Bucket(boltZsetsBucket).Bucket([]byte(key))
+		if zset == nil {
+			if encoded := tx.Bucket(boltStringsBucket).Get([]byte(key)); encoded != nil {
+				if _, ok := br.decodeBoltString(encoded); ok {
+					return ErrWrongType
+				}
+			}
+			return nil
+		}
+		// The index is ordered by score and then by member, like Redis orders
+		// the results.
+		cursor := zset.Bucket(boltScoresBucket).Cursor()
+		for k, _ := cursor.Seek(encodeScore(min)); k != nil; k, _ = cursor.Next() {
+			score := decodeScore(k[:8])
+			if minExclusive && score == min {
+				continue
+			}
+			if score > max || (maxExclusive && score == max) {
+				break
+			}
+			members = append(members, string(k[8:]))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
@@ -0,0 +1,224 @@
+package interfaces
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func openTestBoltRedis(t *testing.T, clock clockwork.Clock) *BoltRedis {
+	br, err := OpenBoltRedis(clock, filepath.Join(t.TempDir(), "ghviz.db"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { br.Close() })
+	return br
+}
+
+func TestBoltRedisGetSet(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	br := openTestBoltRedis(t, clockwork.NewFakeClock())
+
+	_, err := br.Get(ctx, "missing")
+	assert.Equal(t, ErrNil, err)
+
+	assert.NoError(t, br.Set(ctx, "key", "value", 0))
+	value, err := br.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+
+	deleted, err := br.Del(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	_, err = br.Get(ctx, "key")
+	assert.Equal(t, ErrNil, err)
+}
+
+func TestBoltRedisExpiry(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clock := clockwork.NewFakeClock()
+	br := openTestBoltRedis(t, clock)
+	assert.NoError(t, br.Set(ctx, "expiring", "value", time.Minute))
+	assert.NoError(t, br.Set(ctx, "forever", "value", 0))
+
+	clock.Advance(59 * time.Second)
+	_, err := br.Get(ctx, "expiring")
+	assert.NoError(t, err)
+
+	clock.Advance(time.Second)
+	_, err = br.Get(ctx, "expiring")
+	assert.Equal(t, ErrNil, err)
+	deleted, err := br.Del(ctx, "expiring")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+	_, err = br.Get(ctx, "forever")
+	assert.NoError(t, err)
+}
+
+func TestBoltRedisSurvivesReopening(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ghviz.db")
+	br, err := OpenBoltRedis(clockwork.NewFakeClock(), path)
+	assert.NoError(t, err)
+	assert.NoError(t, br.Set(ctx, "key", "value", 0))
+	_, err = br.ZAdd(ctx, "zset", ZZ{Score: 1, Member: "a"})
+	assert.NoError(t, err)
+	assert.NoError(t, br.Close())
+
+	br, err = OpenBoltRedis(clockwork.NewFakeClock(), path)
+	assert.NoError(t, err)
+	defer br.Close()
+	value, err := br.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+	members, err := br.ZRangeByScore(ctx, "zset", &ZRangeByScoreOpts{Min: "-inf", Max: "+inf"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, members)
+}
+
+func TestBoltRedisFileInUse(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "ghviz.db")
+	br, err := OpenBoltRedis(clockwork.NewFakeClock(), path)
+	assert.NoError(t, err)
+	defer br.Close()
+
+	_, err = openBoltRedis(clockwork.NewFakeClock(), path, 50*time.Millisecond)
+	assert.EqualError(t, err, path+" is in use by another process")
+}
+
+func TestBoltRedisSetNX(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clock := clockwork.NewFakeClock()
+	br := openTestBoltRedis(t, clock)
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	acquired := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			isSet, err := br.SetNX(ctx, "lock", "token", time.Minute)
+			assert.NoError(t, err)
+			if isSet {
+				mutex.Lock()
+				acquired++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, acquired)
+
+	clock.Advance(time.Minute)
+	isSet, err := br.SetNX(ctx, "lock", "other", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, isSet)
+	value, err := br.Get(ctx, "lock")
+	assert.NoError(t, err)
+	assert.Equal(t, "other", value)
+}
+
+func TestBoltRedisZAddBytes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	br := openTestBoltRedis(t, clockwork.NewFakeClock())
+	_, err := br.ZAdd(ctx, "zset", ZZ{Score: 1, Member: []byte(`{"score":1}`)}, ZZ{Score: 2, Member: 5})
+	assert.NoError(t, err)
+
+	members, err := br.ZRangeByScore(ctx, "zset", &ZRangeByScoreOpts{Min: "-inf", Max: "+inf"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`{"score":1}`, "5"}, members)
+}
+
+func TestBoltRedisZRangeByScore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	br := openTestBoltRedis(t, clockwork.NewFakeClock())
+	added, err := br.ZAdd(ctx, "zset", ZZ{Score: 3, Member: "c"}, ZZ{Score: -1, Member: "a"}, ZZ{Score: 2, Member: "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), added)
+	added, err = br.ZAdd(ctx, "zset", ZZ{Score: 2, Member: "a"}, ZZ{Score: 4, Member: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), added)
+
+	members, err := br.ZRangeByScore(ctx, "zset", &ZRangeByScoreOpts{Min: "-inf", Max: "+inf"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "5"}, members)
+
+	members, err = br.ZRangeByScore(ctx, "zset", &ZRangeByScoreOpts{Min: "(2", Max: "(4"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c"}, members)
+
+	members, err = br.ZRangeByScore(ctx, "missing", &ZRangeByScoreOpts{Min: "-inf", Max: "+inf"})
+	assert.NoError(t, err)
+	assert.Len(t, members, 0)
+}
+
+func TestBoltRedisWrongType(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	br := openTestBoltRedis(t, clockwork.NewFakeClock())
+	assert.NoError(t, br.Set(ctx, "string", "value", 0))
+	_, err := br.ZAdd(ctx, "zset", ZZ{Score: 1, Member: "a"})
+	assert.NoError(t, err)
+
+	_, err = br.Get(ctx, "zset")
+	assert.Equal(t, ErrWrongType, err)
+	_, err = br.ZAdd(ctx, "string", ZZ{Score: 1, Member: "a"})
+	assert.Equal(t, ErrWrongType, err)
+	_, err = br.ZRangeByScore(ctx, "string", &ZRangeByScoreOpts{Min: "-inf", Max: "+inf"})
+	assert.Equal(t, ErrWrongType, err)
+
+	// Setting a key replaces a sorted set, like it does in Redis.
+	assert.NoError(t, br.Set(ctx, "zset", "value", 0))
+	value, err := br.Get(ctx, "zset")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+}
+
+func TestEncodeScoreSorts(t *testing.T) {
+	t.Parallel()
+
+	scores := []float64{math.Inf(-1), -1e9, -1.5, 0, 0.5, 1, 1e9, math.Inf(1)}
+	for i, score := range scores {
+		assert.Equal(t, score, decodeScore(encodeScore(score)))
+		if i > 0 {
+			assert.True(t, string(encodeScore(scores[i-1])) < string(encodeScore(score)))
+		}
+	}
+}
+
+func TestBoltRedisDelIfEqual(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	br := openTestBoltRedis(t, clockwork.NewFakeClock())
+	assert.NoError(t, br.Set(ctx, "lock", "b", 0))
+
+	deleted, err := br.DelIfEqual(ctx, "lock", "a")
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+	deleted, err = br.DelIfEqual(ctx, "lock", "b")
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	_, err = br.Get(ctx, "lock")
+	assert.Equal(t, ErrNil, err)
+}
@@ -0,0 +1,286 @@
+package interfaces
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+// ErrWrongType is what the in-memory Rediser returns when a command is used on
+// a key holding the wrong kind of value, like Redis' WRONGTYPE error.
+var ErrWrongType error = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+type memoryEntry struct {
+	expiresAt time.Time
+	key       string
+	size      int
+	value     string
+	zset      map[string]float64
+}
+
+func (entry *memoryEntry) isZset() bool {
+	return entry.zset != nil
+}
+
+func (entry *memoryEntry) expired(now time.Time) bool {
+	return !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt)
+}
+
+// MemoryRedis is a Rediser that keeps everything in process, for running
+// without a Redis server. Keys expire lazily, and once the values take up more
+// than maxBytes the least recently used keys are evicted.
+type MemoryRedis struct {
+	sync.Mutex
+	clock     clockwork.Clock
+	entries   map[string]*list.Element
+	lru       *list.List
+	maxBytes  int
+	usedBytes int
+}
+
+func NewMemoryRedis(clock clockwork.Clock, maxBytes int) *MemoryRedis {
+	return &MemoryRedis{
+		clock:    clock,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		maxBytes: maxBytes,
+	}
+}
+
+// lookup finds a live entry and marks it as recently used. The caller must
+// hold the lock.
+func (mr *MemoryRedis) lookup(key string) *memoryEntry {
+	element, ok := mr.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := element.Value.(*memoryEntry)
+	if entry.expired(mr.clock.Now()) {
+		mr.remove(element)
+		return nil
+	}
+	mr.lru.MoveToFront(element)
+	return entry
+}
+
+func (mr *MemoryRedis) remove(element *list.Element) {
+	entry := mr.lru.Remove(element).(*memoryEntry)
+	delete(mr.entries, entry.key)
+	mr.usedBytes -= entry.size
+}
+
+// resize records that an entry changed size and evicts the least recently
+// used entries until everything fits again. The entry itself is never
+// evicted, even if it does not fit on its own.
+func (mr *MemoryRedis) resize(entry *memoryEntry, size int) {
+	mr.usedBytes += size - entry.size
+	entry.size = size
+	for mr.usedBytes > mr.maxBytes && mr.lru.Len() > 1 {
+		mr.remove(mr.lru.Back())
+	}
+}
+
+func (mr *MemoryRedis) insert(entry *memoryEntry) {
+	if element, ok := mr.entries[entry.key]; ok {
+		mr.remove(element)
+	}
+	mr.entries[entry.key] = mr.lru.PushFront(entry)
+}
+
+func (mr *MemoryRedis) Del(ctx context.Context, key string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	mr.Lock()
+	defer mr.Unlock()
+	if mr.lookup(key) == nil {
+		return 0, nil
+	}
+	mr.remove(mr.entries[key])
+	return 1, nil
+}
+
+func (mr *MemoryRedis) DelIfEqual(ctx context.Context, key, value string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	mr.Lock()
+	defer mr.Unlock()
+	entry := mr.lookup(key)
+	if entry == nil || entry.isZset() || entry.value != value {
+		return false, nil
+	}
+	mr.remove(mr.entries[key])
+	return true, nil
+}
+
+func (mr *MemoryRedis) Get(ctx context.Context, key string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	mr.Lock()
+	defer mr.Unlock()
+	entry := mr.lookup(key)
+	if entry == nil {
+		return "", ErrNil
+	}
+	if entry.isZset() {
+		return "", ErrWrongType
+	}
+	return entry.value, nil
+}
+
+func (mr *MemoryRedis) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mr.Lock()
+	defer mr.Unlock()
+	entry := &memoryEntry{key: key, value: value}
+	if ttl > 0 {
+		entry.expiresAt = mr.clock.Now().Add(ttl)
+	}
+	mr.insert(entry)
+	mr.resize(entry, len(key)+len(value))
+	return nil
+}
+
+func (mr *MemoryRedis) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	mr.Lock()
+	defer mr.Unlock()
+	if mr.lookup(key) != nil {
+		return false, nil
+	}
+	entry := &memoryEntry{key: key, value: value}
+	if ttl > 0 {
+		entry.expiresAt = mr.clock.Now().Add(ttl)
+	}
+	mr.insert(entry)
+	mr.resize(entry, len(key)+len(value))
+	return true, nil
+}
+
+func (mr *MemoryRedis) ZAdd(ctx context.Context, key string, members ...ZZ) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	mr.Lock()
+	defer mr.Unlock()
+	entry := mr.lookup(key)
+	if entry == nil {
+		entry = &memoryEntry{key: key, zset: make(map[string]float64)}
+		mr.insert(entry)
+	} else if !entry.isZset() {
+		return 0, ErrWrongType
+	}
+	size := entry.size
+	if size == 0 {
+		size = len(key)
+	}
+	var added int64
+	for _, member := range members {
+		memberStr := memberString(member.Member)
+		if _, ok := entry.zset[memberStr]; !ok {
+			added++
+			size += len(memberStr) + 8
+		}
+		entry.zset[memberStr] = member.Score
+	}
+	mr.resize(entry, size)
+	return added, nil
+}
+
+// memberString converts a sorted set member to the string Redis would store
+// for it, encoding it the way the Redis client sends it.
+func memberString(member interface{}) string {
+	switch m := member.(type) {
+	case nil:
+		return ""
+	case string:
+		return m
+	case []byte:
+		return string(m)
+	case bool:
+		if m {
+			return "1"
+		}
+		return "0"
+	}
+	return fmt.Sprint(member)
+}
+
+// parseScoreBound parses a ZRANGEBYSCORE bound such as "-inf", "(5" or "1.5".
+func parseScoreBound(bound string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(bound, "(")
+	bound = strings.TrimPrefix(bound, "(")
+	switch bound {
+	case "-inf":
+		return math.Inf(-1), exclusive, nil
+	case "+inf", "inf":
+		return math.Inf(1), exclusive, nil
+	}
+	score, err := strconv.ParseFloat(bound, 64)
+	if err != nil {
+		return 0, false, errors.New("ERR min or max is not a float")
+	}
+	return score, exclusive, nil
+}
+
+func (mr *MemoryRedis) ZRangeByScore(
+	ctx context.Context,
+	key string,
+	opts *ZRangeByScoreOpts,
+) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	min, minExclusive, err := parseScoreBound(opts.Min)
+	if err != nil {
+		return nil, err
+	}
+	max, maxExclusive, err := parseScoreBound(opts.Max)
+	if err != nil {
+		return nil, err
+	}
+	mr.Lock()
+	defer mr.Unlock()
+	entry := mr.lookup(key)
+	if entry == nil {
+		return []string{}, nil
+	}
+	if !entry.isZset() {
+		return nil, ErrWrongType
+	}
+	members := make([]string, 0)
+	for member, score := range entry.zset {
+		if score < min || (minExclusive && score == min) {
+			continue
+		}
+		if score > max || (maxExclusive && score == max) {
+			continue
+		}
+		members = append(members, member)
+	}
+	// Redis orders members by score, and members with the same score
+	// lexicographically.
+	sort.Slice(members, func(i, j int) bool {
+		scoreI, scoreJ := entry.zset[members[i]], entry.zset[members[j]]
+		if scoreI != scoreJ {
+			return scoreI < scoreJ
+		}
+		return members[i] < members[j]
+	})
+	return members, nil
+}
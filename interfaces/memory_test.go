@@ -0,0 +1,214 @@
+package interfaces
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryRedisGetSet(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mr := NewMemoryRedis(clockwork.NewFakeClock(), 1<<20)
+
+	_, err := mr.Get(ctx, "missing")
+	assert.Equal(t, ErrNil, err)
+
+	assert.NoError(t, mr.Set(ctx, "key", "value", 0))
+	value, err := mr.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+
+	assert.NoError(t, mr.Set(ctx, "key", "other", 0))
+	value, err = mr.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "other", value)
+	assert.Equal(t, len("key")+len("other"), mr.usedBytes)
+}
+
+func TestMemoryRedisDel(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mr := NewMemoryRedis(clockwork.NewFakeClock(), 1<<20)
+	assert.NoError(t, mr.Set(ctx, "key", "value", 0))
+
+	deleted, err := mr.Del(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	deleted, err = mr.Del(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+	_, err = mr.Get(ctx, "key")
+	assert.Equal(t, ErrNil, err)
+	assert.Equal(t, 0, mr.usedBytes)
+}
+
+func TestMemoryRedisExpiry(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clock := clockwork.NewFakeClock()
+	mr := NewMemoryRedis(clock, 1<<20)
+	assert.NoError(t, mr.Set(ctx, "expiring", "value", time.Minute))
+	assert.NoError(t, mr.Set(ctx, "forever", "value", 0))
+
+	clock.Advance(59 * time.Second)
+	_, err := mr.Get(ctx, "expiring")
+	assert.NoError(t, err)
+
+	clock.Advance(time.Second)
+	_, err = mr.Get(ctx, "expiring")
+	assert.Equal(t, ErrNil, err)
+	_, err = mr.Get(ctx, "forever")
+	assert.NoError(t, err)
+}
+
+func TestMemoryRedisEvictsLeastRecentlyUsed(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mr := NewMemoryRedis(clockwork.NewFakeClock(), 20)
+	assert.NoError(t, mr.Set(ctx, "a", "123456789", 0))
+	assert.NoError(t, mr.Set(ctx, "b", "123456789", 0))
+	// Reading a makes b the least recently used key.
+	_, err := mr.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.NoError(t, mr.Set(ctx, "c", "123456789", 0))
+
+	_, err = mr.Get(ctx, "a")
+	assert.NoError(t, err)
+	_, err = mr.Get(ctx, "b")
+	assert.Equal(t, ErrNil, err)
+	_, err = mr.Get(ctx, "c")
+	assert.NoError(t, err)
+	assert.Equal(t, 20, mr.usedBytes)
+}
+
+func TestMemoryRedisKeepsOversizedValue(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mr := NewMemoryRedis(clockwork.NewFakeClock(), 4)
+	assert.NoError(t, mr.Set(ctx, "a", "1", 0))
+	assert.NoError(t, mr.Set(ctx, "big", "123456789", 0))
+
+	_, err := mr.Get(ctx, "a")
+	assert.Equal(t, ErrNil, err)
+	value, err := mr.Get(ctx, "big")
+	assert.NoError(t, err)
+	assert.Equal(t, "123456789", value)
+}
+
+func TestMemoryRedisZRangeByScore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mr := NewMemoryRedis(clockwork.NewFakeClock(), 1<<20)
+	added, err := mr.ZAdd(ctx, "zset", ZZ{Score: 3, Member: "c"}, ZZ{Score: 1, Member: "a"}, ZZ{Score: 2, Member: "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), added)
+	added, err = mr.ZAdd(ctx, "zset", ZZ{Score: 2, Member: "a"}, ZZ{Score: 4, Member: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), added)
+
+	members, err := mr.ZRangeByScore(ctx, "zset", &ZRangeByScoreOpts{Min: "-inf", Max: "+inf"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "5"}, members)
+
+	members, err = mr.ZRangeByScore(ctx, "zset", &ZRangeByScoreOpts{Min: "(2", Max: "4"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "5"}, members)
+
+	members, err = mr.ZRangeByScore(ctx, "missing", &ZRangeByScoreOpts{Min: "-inf", Max: "+inf"})
+	assert.NoError(t, err)
+	assert.Len(t, members, 0)
+
+	_, err = mr.ZRangeByScore(ctx, "zset", &ZRangeByScoreOpts{Min: "low", Max: "+inf"})
+	assert.Error(t, err)
+}
+
+func TestMemoryRedisZAddBytes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mr := NewMemoryRedis(clockwork.NewFakeClock(), 1<<20)
+	_, err := mr.ZAdd(ctx, "zset", ZZ{Score: 1, Member: []byte(`{"score":1}`)}, ZZ{Score: 2, Member: true})
+	assert.NoError(t, err)
+
+	members, err := mr.ZRangeByScore(ctx, "zset", &ZRangeByScoreOpts{Min: "-inf", Max: "+inf"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`{"score":1}`, "1"}, members)
+}
+
+func TestMemoryRedisWrongType(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mr := NewMemoryRedis(clockwork.NewFakeClock(), 1<<20)
+	assert.NoError(t, mr.Set(ctx, "string", "value", 0))
+	_, err := mr.ZAdd(ctx, "zset", ZZ{Score: 1, Member: "a"})
+	assert.NoError(t, err)
+
+	_, err = mr.Get(ctx, "zset")
+	assert.Equal(t, ErrWrongType, err)
+	_, err = mr.ZAdd(ctx, "string", ZZ{Score: 1, Member: "a"})
+	assert.Equal(t, ErrWrongType, err)
+	_, err = mr.ZRangeByScore(ctx, "string", &ZRangeByScoreOpts{Min: "-inf", Max: "+inf"})
+	assert.Equal(t, ErrWrongType, err)
+}
+
+func TestMemoryRedisCanceledContext(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	mr := NewMemoryRedis(clockwork.NewFakeClock(), 1<<20)
+	assert.Equal(t, context.Canceled, mr.Set(ctx, "key", "value", 0))
+	_, err := mr.Get(ctx, "key")
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestMemoryRedisSetNX(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clock := clockwork.NewFakeClock()
+	mr := NewMemoryRedis(clock, 1<<20)
+
+	set, err := mr.SetNX(ctx, "lock", "a", time.Second)
+	assert.NoError(t, err)
+	assert.True(t, set)
+	set, err = mr.SetNX(ctx, "lock", "b", time.Second)
+	assert.NoError(t, err)
+	assert.False(t, set)
+	value, err := mr.Get(ctx, "lock")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", value)
+
+	clock.Advance(time.Second)
+	set, err = mr.SetNX(ctx, "lock", "b", time.Second)
+	assert.NoError(t, err)
+	assert.True(t, set)
+}
+
+func TestMemoryRedisDelIfEqual(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	mr := NewMemoryRedis(clockwork.NewFakeClock(), 1<<20)
+	assert.NoError(t, mr.Set(ctx, "lock", "b", 0))
+
+	deleted, err := mr.DelIfEqual(ctx, "lock", "a")
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+	deleted, err = mr.DelIfEqual(ctx, "lock", "b")
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	_, err = mr.Get(ctx, "lock")
+	assert.Equal(t, ErrNil, err)
+}
@@ -1,11 +1,16 @@
 package interfaces
 
 import (
+	"context"
 	"time"
 
 	"gopkg.in/redis.v3"
 )
 
+// ErrNil is what Get returns when a key does not exist, so callers can tell a
+// missing key apart from Redis being unreachable.
+var ErrNil error = redis.Nil
+
 type ZZ struct {
 	Score  float64
 	Member interface{}
@@ -16,13 +21,30 @@ type ZRangeByScoreOpts struct {
 }
 
 type Rediser interface {
-	Del(string) (int64, error)
-	Get(string) (string, error)
-	Set(string, string, time.Duration) error
-	ZAdd(string, ...ZZ) (int64, error)
-	ZRangeByScore(string, *ZRangeByScoreOpts) ([]string, error)
+	Del(context.Context, string) (int64, error)
+	Get(context.Context, string) (string, error)
+	Set(context.Context, string, string, time.Duration) error
+	ZAdd(context.Context, string, ...ZZ) (int64, error)
+	ZRangeByScore(context.Context, string, *ZRangeByScoreOpts) ([]string, error)
+}
+
+// Locker is implemented by Redisers that can take a lock shared between
+// processes: SetNX sets a key only if it does not exist yet, and DelIfEqual
+// deletes a key only while it still holds the given value. Both are atomic.
+type Locker interface {
+	DelIfEqual(context.Context, string, string) (bool, error)
+	SetNX(context.Context, string, string, time.Duration) (bool, error)
 }
 
+// delIfEqualScript deletes a key only while it holds the given value.
+var delIfEqualScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// GoRedisAdapter adapts a redis.v3 client, which has no notion of contexts.
+// It refuses to start commands once the context is done, and relies on the
+// client's own read and write timeouts to bound commands in flight.
 type GoRedisAdapter struct {
 	redisClient *redis.Client
 }
@@ -33,19 +55,50 @@ func NewGoRedis(redisClient *redis.Client) Rediser {
 	return gr
 }
 
-func (gr *GoRedisAdapter) Del(key string) (int64, error) {
+func (gr *GoRedisAdapter) Del(ctx context.Context, key string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
 	return gr.redisClient.Del(key).Result()
 }
 
-func (gr *GoRedisAdapter) Get(key string) (string, error) {
+func (gr *GoRedisAdapter) DelIfEqual(ctx context.Context, key, value string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	deleted, err := delIfEqualScript.Run(gr.redisClient, []string{key}, []string{value}).Result()
+	if err != nil {
+		return false, err
+	}
+	count, _ := deleted.(int64)
+	return count > 0, nil
+}
+
+func (gr *GoRedisAdapter) Get(ctx context.Context, key string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	return gr.redisClient.Get(key).Result()
 }
 
-func (gr *GoRedisAdapter) Set(key, value string, ttl time.Duration) error {
+func (gr *GoRedisAdapter) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return gr.redisClient.Set(key, value, ttl).Err()
 }
 
-func (gr *GoRedisAdapter) ZAdd(key string, members ...ZZ) (int64, error) {
+func (gr *GoRedisAdapter) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return gr.redisClient.SetNX(key, value, ttl).Result()
+}
+
+func (gr *GoRedisAdapter) ZAdd(ctx context.Context, key string, members ...ZZ) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
 
 	var zmembers []redis.Z
 	for _, member := range members {
@@ -58,9 +111,13 @@ func (gr *GoRedisAdapter) ZAdd(key string, members ...ZZ) (int64, error) {
 }
 
 func (gr *GoRedisAdapter) ZRangeByScore(
+	ctx context.Context,
 	key string,
 	opts *ZRangeByScoreOpts,
 ) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	return gr.redisClient.ZRangeByScore(key, redis.ZRangeByScore{
 		Min: opts.Min,
 		Max: opts.Max,
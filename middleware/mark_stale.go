@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/context"
+	"github.com/jonboulle/clockwork"
+
+	"github.com/ksheedlo/ghviz/github"
+)
+
+type staleMarkingResponseWriter struct {
+	clock  clockwork.Clock
+	marked bool
+	status *github.CacheStatus
+	w      http.ResponseWriter
+}
+
+func (smrw *staleMarkingResponseWriter) Header() http.Header {
+	return smrw.w.Header()
+}
+
+// mark sets the stale headers right before the response starts, once the
+// handler is done talking to Github.
+func (smrw *staleMarkingResponseWriter) mark() {
+	if smrw.marked {
+		return
+	}
+	smrw.marked = true
+	if stale, cachedAt := smrw.status.Stale(); stale {
+		smrw.w.Header().Set("X-Cache-Stale", "true")
+		smrw.w.Header().Set("Age", strconv.Itoa(int(smrw.clock.Now().Sub(cachedAt).Seconds())))
+	}
+}
+
+func (smrw *staleMarkingResponseWriter) Write(b []byte) (int, error) {
+	smrw.mark()
+	return smrw.w.Write(b)
+}
+
+func (smrw *staleMarkingResponseWriter) WriteHeader(status int) {
+	smrw.mark()
+	smrw.w.WriteHeader(status)
+}
+
+// MarkStale marks responses built from stale cache entries with an
+// X-Cache-Stale header, along with the Age of the oldest such entry. The age
+// is measured with the same clock the Github client uses.
+func MarkStale(clock clockwork.Clock) Middleware {
+	return func(handler http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			status := &github.CacheStatus{}
+			context.Set(r, CtxCacheStatus, status)
+			handler(&staleMarkingResponseWriter{clock: clock, status: status, w: w}, r)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/github"
+	"github.com/ksheedlo/ghviz/mocks"
+
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkStale(t *testing.T) {
+	t.Parallel()
+
+	clock := clockwork.NewFakeClockAt(time.Unix(1458966366, 0))
+	r := mux.NewRouter()
+	r.HandleFunc("/foof", MarkStale(clock)(
+		func(w http.ResponseWriter, r *http.Request) {
+			status := context.Get(r, CtxCacheStatus).(*github.CacheStatus)
+			status.MarkStale(time.Unix(1458966366, 0).Add(-time.Hour))
+			clock.Advance(time.Minute)
+			w.Write([]byte("Test Response\n"))
+		},
+	))
+
+	req := mocks.NewHttpRequest(t, "GET", "/foof", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "true", w.Header().Get("X-Cache-Stale"))
+	assert.Equal(t, "3660", w.Header().Get("Age"))
+}
+
+func TestMarkStaleFresh(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	r.HandleFunc("/foof", MarkStale(clockwork.NewFakeClock())(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		},
+	))
+
+	req := mocks.NewHttpRequest(t, "GET", "/foof", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "", w.Header().Get("X-Cache-Stale"))
+	assert.Equal(t, "", w.Header().Get("Age"))
+}
@@ -7,6 +7,7 @@ import (
 const (
 	CtxResponseId = iota
 	CtxLog
+	CtxCacheStatus
 )
 
 type Middleware func(http.HandlerFunc) http.HandlerFunc
@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"log"
@@ -38,29 +39,29 @@ type MockRediser struct {
 	mock.Mock
 }
 
-func (m *MockRediser) Del(key string) (int64, error) {
+func (m *MockRediser) Del(ctx context.Context, key string) (int64, error) {
 	args := m.Called(key)
 	return args.Get(0).(int64), args.Error(1)
 }
 
-func (m *MockRediser) Get(key string) (string, error) {
+func (m *MockRediser) Get(ctx context.Context, key string) (string, error) {
 	args := m.Called(key)
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockRediser) Set(key, value string, ttl time.Duration) error {
+func (m *MockRediser) Set(ctx context.Context, key, value string, ttl time.Duration) error {
 	// Set the value to "" because it's used to set JSON, which serializes
 	// in an unpredictable order.
 	args := m.Called(key, "", ttl)
 	return args.Error(0)
 }
 
-func (m *MockRediser) ZAdd(key string, members ...interfaces.ZZ) (int64, error) {
+func (m *MockRediser) ZAdd(ctx context.Context, key string, members ...interfaces.ZZ) (int64, error) {
 	args := m.Called(key)
 	return args.Get(0).(int64), args.Error(1)
 }
 
-func (m *MockRediser) ZRangeByScore(key string, opts *interfaces.ZRangeByScoreOpts) ([]string, error) {
+func (m *MockRediser) ZRangeByScore(ctx context.Context, key string, opts *interfaces.ZRangeByScoreOpts) ([]string, error) {
 	args := m.Called(key, opts)
 	resultsArg := args.Get(0)
 	if resultsArg != nil {
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/ksheedlo/ghviz/github"
+)
+
+// ReferrerSnapshot holds the top referrers as Github reported them on one day.
+type ReferrerSnapshot struct {
+	Date      time.Time
+	Referrers []github.Referrer
+}
+
+func (snapshot *ReferrerSnapshot) MarshalJSON() ([]byte, error) {
+	referrers := snapshot.Referrers
+	if referrers == nil {
+		referrers = []github.Referrer{}
+	}
+	return json.Marshal(map[string]interface{}{
+		"date":      snapshot.Date,
+		"referrers": referrers,
+	})
+}
+
+// PathSnapshot holds the popular paths as Github reported them on one day.
+type PathSnapshot struct {
+	Date  time.Time
+	Paths []github.PopularPath
+}
+
+func (snapshot *PathSnapshot) MarshalJSON() ([]byte, error) {
+	paths := snapshot.Paths
+	if paths == nil {
+		paths = []github.PopularPath{}
+	}
+	return json.Marshal(map[string]interface{}{
+		"date":  snapshot.Date,
+		"paths": paths,
+	})
+}
+
+// TrafficHistory accumulates the traffic Github reports for a repo, since
+// Github only keeps the last 14 days of it. Views and clones are kept per
+// day. Referrers and paths are only ever reported as 14 day totals, so we keep
+// one snapshot of them per day.
+type TrafficHistory struct {
+	Clones    []github.TrafficCount
+	Paths     []PathSnapshot
+	Referrers []ReferrerSnapshot
+	Views     []github.TrafficCount
+}
+
+func (history *TrafficHistory) MarshalJSON() ([]byte, error) {
+	clones := history.Clones
+	if clones == nil {
+		clones = []github.TrafficCount{}
+	}
+	paths := history.Paths
+	if paths == nil {
+		paths = []PathSnapshot{}
+	}
+	referrers := history.Referrers
+	if referrers == nil {
+		referrers = []ReferrerSnapshot{}
+	}
+	views := history.Views
+	if views == nil {
+		views = []github.TrafficCount{}
+	}
+	return json.Marshal(map[string]interface{}{
+		"clones":    clones,
+		"paths":     paths,
+		"referrers": referrers,
+		"views":     views,
+	})
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// mergeTrafficCounts merges newer counts into older ones by day. Github keeps
+// counting today's traffic as the day goes on, so the newer count for a day
+// always wins.
+func mergeTrafficCounts(older, newer []github.TrafficCount) []github.TrafficCount {
+	byDay := make(map[time.Time]github.TrafficCount)
+	for _, counts := range [][]github.TrafficCount{older, newer} {
+		for _, count := range counts {
+			count.Timestamp = startOfDay(count.Timestamp)
+			byDay[count.Timestamp] = count
+		}
+	}
+	merged := make([]github.TrafficCount, 0, len(byDay))
+	for _, count := range byDay {
+		merged = append(merged, count)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Timestamp.Before(merged[j].Timestamp) })
+	return merged
+}
+
+// Merge adds traffic fetched at the given time to the history. Merging the
+// same traffic again leaves the history as it was.
+func (history *TrafficHistory) Merge(traffic *github.Traffic, fetchedAt time.Time) {
+	history.Clones = mergeTrafficCounts(history.Clones, traffic.Clones)
+	history.Views = mergeTrafficCounts(history.Views, traffic.Views)
+
+	day := startOfDay(fetchedAt)
+	referrers := ReferrerSnapshot{Date: day, Referrers: traffic.Referrers}
+	if last := len(history.Referrers) - 1; last >= 0 && history.Referrers[last].Date.Equal(day) {
+		history.Referrers[last] = referrers
+	} else {
+		history.Referrers = append(history.Referrers, referrers)
+	}
+	paths := PathSnapshot{Date: day, Paths: traffic.Paths}
+	if last := len(history.Paths) - 1; last >= 0 && history.Paths[last].Date.Equal(day) {
+		history.Paths[last] = paths
+	} else {
+		history.Paths = append(history.Paths, paths)
+	}
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func day(d int) time.Time {
+	return time.Date(2016, 3, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestTrafficHistoryMerge(t *testing.T) {
+	t.Parallel()
+
+	history := &TrafficHistory{}
+	history.Merge(&github.Traffic{
+		Views: []github.TrafficCount{
+			github.TrafficCount{Count: 10, Timestamp: day(7), Uniques: 3},
+			github.TrafficCount{Count: 2, Timestamp: day(8), Uniques: 1},
+		},
+		Referrers: []github.Referrer{github.Referrer{Count: 8, Referrer: "google.com", Uniques: 3}},
+	}, day(8).Add(6*time.Hour))
+	history.Merge(&github.Traffic{
+		Views: []github.TrafficCount{
+			github.TrafficCount{Count: 5, Timestamp: day(8), Uniques: 2},
+			github.TrafficCount{Count: 1, Timestamp: day(9), Uniques: 1},
+		},
+		Referrers: []github.Referrer{github.Referrer{Count: 9, Referrer: "google.com", Uniques: 4}},
+	}, day(9).Add(6*time.Hour))
+
+	assert.Equal(t, []github.TrafficCount{
+		github.TrafficCount{Count: 10, Timestamp: day(7), Uniques: 3},
+		github.TrafficCount{Count: 5, Timestamp: day(8), Uniques: 2},
+		github.TrafficCount{Count: 1, Timestamp: day(9), Uniques: 1},
+	}, history.Views)
+	assert.Len(t, history.Referrers, 2)
+	assert.Equal(t, day(9), history.Referrers[1].Date)
+	assert.Equal(t, 9, history.Referrers[1].Referrers[0].Count)
+}
+
+func TestTrafficHistoryMergeIsIdempotent(t *testing.T) {
+	t.Parallel()
+
+	traffic := &github.Traffic{
+		Clones: []github.TrafficCount{github.TrafficCount{Count: 2, Timestamp: day(8), Uniques: 1}},
+		Paths:  []github.PopularPath{github.PopularPath{Count: 12, Path: "/lodash/lodash"}},
+		Views:  []github.TrafficCount{github.TrafficCount{Count: 10, Timestamp: day(7), Uniques: 3}},
+	}
+	history := &TrafficHistory{}
+	history.Merge(traffic, day(8))
+	once, err := json.Marshal(history)
+	assert.NoError(t, err)
+
+	history.Merge(traffic, day(8).Add(time.Hour))
+	twice, err := json.Marshal(history)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(once), string(twice))
+}
+
+func TestTrafficHistoryRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	history := &TrafficHistory{}
+	history.Merge(&github.Traffic{
+		Paths:     []github.PopularPath{github.PopularPath{Count: 12, Path: "/lodash/lodash", Title: "lodash", Uniques: 4}},
+		Referrers: []github.Referrer{github.Referrer{Count: 8, Referrer: "google.com", Uniques: 3}},
+		Views:     []github.TrafficCount{github.TrafficCount{Count: 10, Timestamp: day(7), Uniques: 3}},
+	}, day(8))
+	jsonBlob, err := json.Marshal(history)
+	assert.NoError(t, err)
+
+	decoded := &TrafficHistory{}
+	assert.NoError(t, json.Unmarshal(jsonBlob, decoded))
+	assert.Equal(t, history, decoded)
+}
+
+func TestMarshalEmptyTrafficHistory(t *testing.T) {
+	t.Parallel()
+
+	jsonBlob, err := json.Marshal(&TrafficHistory{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"clones":[],"paths":[],"referrers":[],"views":[]}`, string(jsonBlob))
+}
@@ -1,6 +1,7 @@
 package prewarm
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -9,12 +10,14 @@ import (
 
 	"github.com/ksheedlo/ghviz/github"
 	"github.com/ksheedlo/ghviz/interfaces"
+	"github.com/ksheedlo/ghviz/models"
 	"github.com/ksheedlo/ghviz/simulate"
 
 	"github.com/jonboulle/clockwork"
 )
 
 func PrewarmHighScores(
+	ctx context.Context,
 	logger *log.Logger,
 	gh github.ListAllPrEventser,
 	redis interfaces.Rediser,
@@ -22,7 +25,7 @@ func PrewarmHighScores(
 	randomTagger interfaces.RandomTagger,
 	owner, repo string,
 ) error {
-	allPrEvents, httpErr := gh.ListAllPrEvents(logger, owner, repo)
+	allPrEvents, httpErr := gh.ListAllPrEvents(ctx, logger, owner, repo)
 	if httpErr != nil {
 		return httpErr
 	}
@@ -46,17 +49,18 @@ func PrewarmHighScores(
 		return err
 	}
 	eventSetCacheKey := fmt.Sprintf("gh:repos:%s:%s:issue_events:%s", owner, repo, nextEventSetId)
-	if _, err := redis.ZAdd(eventSetCacheKey, members...); err != nil {
+	if _, err := redis.ZAdd(ctx, eventSetCacheKey, members...); err != nil {
 		return err
 	}
 	eventSetIdPtr := fmt.Sprintf("gh:repos:%s:%s:issue_event_setid", owner, repo)
-	currentEventSetId, currentEventSetErr := redis.Get(eventSetIdPtr)
-	if err := redis.Set(eventSetIdPtr, nextEventSetId, time.Duration(0)); err != nil {
+	currentEventSetId, currentEventSetErr := redis.Get(ctx, eventSetIdPtr)
+	if err := redis.Set(ctx, eventSetIdPtr, nextEventSetId, time.Duration(0)); err != nil {
 		return err
 	}
 	if currentEventSetErr == nil && currentEventSetId != "" {
 		clock.Sleep(5 * time.Second)
 		if _, err := redis.Del(
+			ctx,
 			fmt.Sprintf("gh:repos:%s:%s:issue_events:%s", owner, repo, currentEventSetId),
 		); err != nil {
 			logger.Printf("ERROR: %s; recovered\n", err.Error())
@@ -64,3 +68,101 @@ func PrewarmHighScores(
 	}
 	return nil
 }
+
+// SnapshotWatchers records how many people watch a repo right now. Github
+// keeps no history of watchers, so the watcher chart is built from these
+// snapshots.
+func SnapshotWatchers(
+	ctx context.Context,
+	logger *log.Logger,
+	gh github.GetWatcherCounter,
+	redis interfaces.Rediser,
+	clock clockwork.Clock,
+	owner, repo string,
+) error {
+	watchers, httpErr := gh.GetWatcherCount(ctx, logger, owner, repo)
+	if httpErr != nil {
+		return httpErr
+	}
+	now := clock.Now().UTC()
+	// Ignore errors from json.Marshal because we control the serializing
+	// routine for WatcherCounts.
+	jsonBlob, _ := json.Marshal(&simulate.WatcherCount{Timestamp: now, Watchers: watchers})
+	if _, err := redis.ZAdd(
+		ctx,
+		fmt.Sprintf("gh:repos:%s:%s:watcher_counts", owner, repo),
+		interfaces.ZZ{Score: float64(now.Unix()), Member: jsonBlob},
+	); err != nil {
+		return err
+	}
+	logger.Printf("%s/%s has %d watchers.\n", owner, repo, watchers)
+	return nil
+}
+
+// SnapshotWatchersEvery takes a watcher snapshot right away and then once per
+// interval until the context is done.
+func SnapshotWatchersEvery(
+	ctx context.Context,
+	logger *log.Logger,
+	gh github.GetWatcherCounter,
+	redis interfaces.Rediser,
+	clock clockwork.Clock,
+	interval time.Duration,
+	owner, repo string,
+) error {
+	for {
+		if err := SnapshotWatchers(ctx, logger, gh, redis, clock, owner, repo); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			// A missed snapshot only leaves a gap in the chart, so keep going.
+			logger.Printf("ERROR: %s; recovered\n", err.Error())
+		}
+		select {
+		case <-clock.After(interval):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func trafficKey(owner, repo string) string {
+	return fmt.Sprintf("gh:repos:%s:%s:traffic", owner, repo)
+}
+
+// PrewarmTraffic merges the traffic Github has kept for the last 14 days into
+// the traffic history we keep in Redis, which never expires.
+func PrewarmTraffic(
+	ctx context.Context,
+	logger *log.Logger,
+	gh github.GetTrafficer,
+	redis interfaces.Rediser,
+	clock clockwork.Clock,
+	owner, repo string,
+) error {
+	traffic, httpErr := gh.GetTraffic(ctx, logger, owner, repo)
+	if httpErr != nil {
+		return httpErr
+	}
+	history := &models.TrafficHistory{}
+	historyJson, err := redis.Get(ctx, trafficKey(owner, repo))
+	// Anything but a missing key has to stop us here, or we would overwrite
+	// the history with only the last 14 days.
+	if err != nil && err != interfaces.ErrNil {
+		return err
+	}
+	if historyJson != "" {
+		if err := json.Unmarshal([]byte(historyJson), history); err != nil {
+			return fmt.Errorf("the traffic history for %s/%s could not be decoded: %s", owner, repo, err.Error())
+		}
+	}
+	history.Merge(traffic, clock.Now())
+	// Ignore errors from json.Marshal because we control the serializing
+	// routine for TrafficHistories.
+	jsonBlob, _ := json.Marshal(history)
+	if err := redis.Set(ctx, trafficKey(owner, repo), string(jsonBlob), time.Duration(0)); err != nil {
+		return err
+	}
+	logger.Printf("The traffic history for %s/%s now covers %d days.\n", owner, repo, len(history.Views))
+	return nil
+}
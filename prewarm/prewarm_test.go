@@ -1,6 +1,7 @@
 package prewarm
 
 import (
+	"context"
 	"log"
 	"sync"
 	"testing"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/ksheedlo/ghviz/errors"
 	"github.com/ksheedlo/ghviz/github"
+	"github.com/ksheedlo/ghviz/interfaces"
 	"github.com/ksheedlo/ghviz/mocks"
 
 	"github.com/jonboulle/clockwork"
@@ -20,6 +22,7 @@ type MockListAllPrEventser struct {
 }
 
 func (m *MockListAllPrEventser) ListAllPrEvents(
+	ctx context.Context,
 	logger *log.Logger,
 	owner, repo string,
 ) ([]github.DetailedIssueEvent, *errors.HttpError) {
@@ -97,6 +100,7 @@ func TestPrewarmHighScores(t *testing.T) {
 	wg.Add(1)
 	go func() {
 		err := PrewarmHighScores(
+			context.Background(),
 			logger,
 			ghMock,
 			redisMock,
@@ -131,7 +135,7 @@ func TestPrewarmPropagatesGithubError(t *testing.T) {
 			[]github.DetailedIssueEvent{},
 			&errors.HttpError{Message: "Server Error", Status: 500},
 		)
-	err := PrewarmHighScores(logger, ghMock, nil, nil, nil, "tester1", "coolrepo")
+	err := PrewarmHighScores(context.Background(), logger, ghMock, nil, nil, nil, "tester1", "coolrepo")
 
 	assert.Error(t, err)
 	ghMock.AssertExpectations(t)
@@ -159,6 +163,7 @@ func TestPrewarmPropagatesRandomIOError(t *testing.T) {
 	randomTagger.On("RandomTag").Return("", mocks.ConstantError("I/O Error"))
 
 	err := PrewarmHighScores(
+		context.Background(),
 		logger,
 		ghMock,
 		nil,
@@ -200,6 +205,7 @@ func TestPrewarmPropagatesRedisZAddError(t *testing.T) {
 		Return(int64(0), mocks.ConstantError("ZAdd Error"))
 
 	err := PrewarmHighScores(
+		context.Background(),
 		logger,
 		ghMock,
 		redisMock,
@@ -250,6 +256,7 @@ func TestPrewarmPropagatesRedisSetError(t *testing.T) {
 		Return(mocks.ConstantError("Redis Error"))
 
 	err := PrewarmHighScores(
+		context.Background(),
 		logger,
 		ghMock,
 		redisMock,
@@ -308,6 +315,7 @@ func TestPrewarmRecoversDeleteOldIssueEventset(t *testing.T) {
 	wg.Add(1)
 	go func() {
 		err := PrewarmHighScores(
+			context.Background(),
 			logger,
 			ghMock,
 			redisMock,
@@ -329,3 +337,190 @@ func TestPrewarmRecoversDeleteOldIssueEventset(t *testing.T) {
 	randomTagger.AssertExpectations(t)
 	redisMock.AssertExpectations(t)
 }
+
+type MockGetWatcherCounter struct {
+	mock.Mock
+}
+
+func (m *MockGetWatcherCounter) GetWatcherCount(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) (int, *errors.HttpError) {
+	args := m.Called(logger, owner, repo)
+	errArg := args.Get(1)
+	if errArg == nil {
+		return args.Int(0), nil
+	}
+	return args.Int(0), errArg.(*errors.HttpError)
+}
+
+func TestSnapshotWatchers(t *testing.T) {
+	t.Parallel()
+
+	redisMock := &mocks.MockRediser{}
+	ghMock := &MockGetWatcherCounter{}
+	clock := clockwork.NewFakeClock()
+	logger := mocks.DummyLogger(t)
+
+	ghMock.On("GetWatcherCount", logger, "tester1", "coolrepo").Return(7, nil)
+	redisMock.On("ZAdd", "gh:repos:tester1:coolrepo:watcher_counts").Return(int64(1), nil)
+
+	assert.NoError(t, SnapshotWatchers(
+		context.Background(),
+		logger,
+		ghMock,
+		redisMock,
+		clock,
+		"tester1",
+		"coolrepo",
+	))
+	ghMock.AssertExpectations(t)
+	redisMock.AssertExpectations(t)
+}
+
+func TestSnapshotWatchersGithubError(t *testing.T) {
+	t.Parallel()
+
+	redisMock := &mocks.MockRediser{}
+	ghMock := &MockGetWatcherCounter{}
+	logger := mocks.DummyLogger(t)
+
+	ghMock.On("GetWatcherCount", logger, "tester1", "coolrepo").Return(0, &errors.HttpError{
+		Message: "Github API Error",
+		Status:  502,
+	})
+
+	err := SnapshotWatchers(
+		context.Background(),
+		logger,
+		ghMock,
+		redisMock,
+		clockwork.NewFakeClock(),
+		"tester1",
+		"coolrepo",
+	)
+	assert.Error(t, err)
+	redisMock.AssertNotCalled(t, "ZAdd", "gh:repos:tester1:coolrepo:watcher_counts")
+}
+
+func TestSnapshotWatchersEvery(t *testing.T) {
+	t.Parallel()
+
+	redisMock := &mocks.MockRediser{}
+	ghMock := &MockGetWatcherCounter{}
+	clock := clockwork.NewFakeClock()
+	logger := mocks.DummyLogger(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ghMock.On("GetWatcherCount", logger, "tester1", "coolrepo").Return(7, nil)
+	redisMock.On("ZAdd", "gh:repos:tester1:coolrepo:watcher_counts").Return(int64(1), nil)
+
+	done := make(chan error)
+	go func() {
+		done <- SnapshotWatchersEvery(ctx, logger, ghMock, redisMock, clock, time.Hour, "tester1", "coolrepo")
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	clock.BlockUntil(1)
+	cancel()
+
+	assert.NoError(t, <-done)
+	ghMock.AssertNumberOfCalls(t, "GetWatcherCount", 2)
+	redisMock.AssertNumberOfCalls(t, "ZAdd", 2)
+}
+
+type MockGetTrafficer struct {
+	mock.Mock
+}
+
+func (m *MockGetTrafficer) GetTraffic(
+	ctx context.Context,
+	logger *log.Logger,
+	owner, repo string,
+) (*github.Traffic, *errors.HttpError) {
+	args := m.Called(logger, owner, repo)
+	errArg := args.Get(1)
+	if errArg == nil {
+		return args.Get(0).(*github.Traffic), nil
+	}
+	return nil, errArg.(*errors.HttpError)
+}
+
+func TestPrewarmTraffic(t *testing.T) {
+	t.Parallel()
+
+	redisMock := &mocks.MockRediser{}
+	ghMock := &MockGetTrafficer{}
+	logger := mocks.DummyLogger(t)
+
+	ghMock.On("GetTraffic", logger, "tester1", "coolrepo").Return(&github.Traffic{
+		Views: []github.TrafficCount{
+			github.TrafficCount{Count: 5, Timestamp: time.Date(2016, 3, 8, 0, 0, 0, 0, time.UTC), Uniques: 2},
+		},
+	}, nil)
+	redisMock.
+		On("Get", "gh:repos:tester1:coolrepo:traffic").
+		Return(`{"views":[{"count":10,"timestamp":"2016-03-07T00:00:00Z","uniques":3}]}`, nil)
+	redisMock.On("Set", "gh:repos:tester1:coolrepo:traffic", "", time.Duration(0)).Return(nil)
+
+	assert.NoError(t, PrewarmTraffic(
+		context.Background(),
+		logger,
+		ghMock,
+		redisMock,
+		clockwork.NewFakeClock(),
+		"tester1",
+		"coolrepo",
+	))
+	ghMock.AssertExpectations(t)
+	redisMock.AssertExpectations(t)
+}
+
+func TestPrewarmTrafficKeepsHistoryOnRedisError(t *testing.T) {
+	t.Parallel()
+
+	redisMock := &mocks.MockRediser{}
+	ghMock := &MockGetTrafficer{}
+	logger := mocks.DummyLogger(t)
+
+	ghMock.On("GetTraffic", logger, "tester1", "coolrepo").Return(&github.Traffic{}, nil)
+	redisMock.
+		On("Get", "gh:repos:tester1:coolrepo:traffic").
+		Return("", mocks.ConstantError("connection refused"))
+
+	err := PrewarmTraffic(
+		context.Background(),
+		logger,
+		ghMock,
+		redisMock,
+		clockwork.NewFakeClock(),
+		"tester1",
+		"coolrepo",
+	)
+	assert.Error(t, err)
+	redisMock.AssertNotCalled(t, "Set", "gh:repos:tester1:coolrepo:traffic", "", time.Duration(0))
+}
+
+func TestPrewarmTrafficMissingHistory(t *testing.T) {
+	t.Parallel()
+
+	redisMock := &mocks.MockRediser{}
+	ghMock := &MockGetTrafficer{}
+	logger := mocks.DummyLogger(t)
+
+	ghMock.On("GetTraffic", logger, "tester1", "coolrepo").Return(&github.Traffic{}, nil)
+	redisMock.On("Get", "gh:repos:tester1:coolrepo:traffic").Return("", interfaces.ErrNil)
+	redisMock.On("Set", "gh:repos:tester1:coolrepo:traffic", "", time.Duration(0)).Return(nil)
+
+	assert.NoError(t, PrewarmTraffic(
+		context.Background(),
+		logger,
+		ghMock,
+		redisMock,
+		clockwork.NewFakeClock(),
+		"tester1",
+		"coolrepo",
+	))
+	redisMock.AssertExpectations(t)
+}
@@ -0,0 +1,185 @@
+package routes
+
+import (
+	stdcontext "context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+
+	"github.com/ksheedlo/ghviz/errors"
+	"github.com/ksheedlo/ghviz/github"
+	"github.com/ksheedlo/ghviz/middleware"
+	"github.com/ksheedlo/ghviz/models"
+	"github.com/ksheedlo/ghviz/simulate"
+)
+
+// orgConcurrency caps how many repos of an org are fetched at a time, so
+// that one org request does not hog the Github rate limit.
+const orgConcurrency int = 4
+
+type orgStarEventser interface {
+	github.ListRepositorieser
+	github.ListStarEventser
+}
+
+type orgIssueser interface {
+	github.ListRepositorieser
+	github.ListIssueser
+}
+
+type orgTopIssueser interface {
+	github.ListRepositorieser
+	github.ListTopIssueser
+}
+
+func boolParam(r *http.Request, name string) (bool, *errors.HttpError) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, &errors.HttpError{
+			Code:    errors.CodeBadRequest,
+			Message: fmt.Sprintf("%s is not a valid value for %s, expected true or false", value, name),
+			Status:  http.StatusBadRequest,
+		}
+	}
+	return parsed, nil
+}
+
+func repositoryFilter(r *http.Request) (github.RepositoryFilter, *errors.HttpError) {
+	includeArchived, httpErr := boolParam(r, "include_archived")
+	if httpErr != nil {
+		return github.RepositoryFilter{}, httpErr
+	}
+	includeForks, httpErr := boolParam(r, "include_forks")
+	if httpErr != nil {
+		return github.RepositoryFilter{}, httpErr
+	}
+	return github.RepositoryFilter{IncludeArchived: includeArchived, IncludeForks: includeForks}, nil
+}
+
+// orgRepositories lists the org's repos that match the request's filter.
+func orgRepositories(
+	r *http.Request,
+	logger *log.Logger,
+	gh github.ListRepositorieser,
+) ([]github.Repository, *errors.HttpError) {
+	filter, httpErr := repositoryFilter(r)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	repositories, httpErr := gh.ListRepositories(requestContext(r), logger, mux.Vars(r)["org"])
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return github.FilterRepositories(repositories, filter), nil
+}
+
+// eachRepository calls handle for each repo concurrently, along with the
+// repo's index. Once a call fails or ctx is done, no further calls are made.
+func eachRepository(
+	ctx stdcontext.Context,
+	repositories []github.Repository,
+	handle func(int, *github.Repository) *errors.HttpError,
+) *errors.HttpError {
+	return github.ForEach(ctx, len(repositories), orgConcurrency, func(i int) *errors.HttpError {
+		return handle(i, &repositories[i])
+	})
+}
+
+func OrgStarCounts(gh orgStarEventser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := context.Get(r, middleware.CtxLog).(*log.Logger)
+		repositories, httpErr := orgRepositories(r, logger, gh)
+		if httpErr != nil {
+			writeHttpError(w, httpErr)
+			return
+		}
+		series := make([][]simulate.StarCount, len(repositories))
+		httpErr = eachRepository(requestContext(r), repositories, func(i int, repository *github.Repository) *errors.HttpError {
+			starEvents, httpErr := gh.ListStarEvents(requestContext(r), logger, repository.Owner, repository.Name)
+			if httpErr != nil {
+				return httpErr
+			}
+			series[i] = simulate.StarCounts(starEvents)
+			return nil
+		})
+		if httpErr != nil {
+			writeHttpError(w, httpErr)
+			return
+		}
+		// Suppress JSON marshaling errors because we know we can always
+		// marshal `simulate.StarCount`s.
+		jsonBlob, _ := json.Marshal(simulate.MergeStarCounts(series))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonBlob)
+	}
+}
+
+func OrgOpenIssuesAndPrs(gh orgIssueser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := context.Get(r, middleware.CtxLog).(*log.Logger)
+		filter := issueFilter(r)
+		repositories, httpErr := orgRepositories(r, logger, gh)
+		if httpErr != nil {
+			writeHttpError(w, httpErr)
+			return
+		}
+		series := make([][]simulate.OpenIssueAndPrCount, len(repositories))
+		httpErr = eachRepository(requestContext(r), repositories, func(i int, repository *github.Repository) *errors.HttpError {
+			allIssues, httpErr := gh.ListIssues(requestContext(r), logger, repository.Owner, repository.Name)
+			if httpErr != nil {
+				return httpErr
+			}
+			events := models.IssueEventsFromApi(github.FilterIssues(allIssues, filter))
+			series[i] = simulate.OpenIssueAndPrCounts(events)
+			return nil
+		})
+		if httpErr != nil {
+			writeHttpError(w, httpErr)
+			return
+		}
+		// Suppress JSON marshaling errors because we know we can always
+		// marshal `simulate.OpenIssueAndPrCount`s.
+		jsonBlob, _ := json.Marshal(simulate.MergeOpenIssueAndPrCounts(series))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonBlob)
+	}
+}
+
+func OrgTopIssues(gh orgTopIssueser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := context.Get(r, middleware.CtxLog).(*log.Logger)
+		filter := issueFilter(r)
+		repositories, httpErr := orgRepositories(r, logger, gh)
+		if httpErr != nil {
+			writeHttpError(w, httpErr)
+			return
+		}
+		series := make([][]github.Issue, len(repositories))
+		httpErr = eachRepository(requestContext(r), repositories, func(i int, repository *github.Repository) *errors.HttpError {
+			issues, httpErr := gh.ListTopIssues(requestContext(r), logger, repository.Owner, repository.Name, 5, filter)
+			if httpErr != nil {
+				return httpErr
+			}
+			series[i] = issues
+			return nil
+		})
+		if httpErr != nil {
+			writeHttpError(w, httpErr)
+			return
+		}
+		// Suppress JSON marshaling errors because we know we can always
+		// marshal `github.Issue`s.
+		jsonBlob, _ := json.Marshal(simulate.MergeTopIssues(series, 5))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonBlob)
+	}
+}
@@ -0,0 +1,249 @@
+package routes
+
+import (
+	stdcontext "context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/errors"
+	"github.com/ksheedlo/ghviz/github"
+	"github.com/ksheedlo/ghviz/middleware"
+	"github.com/ksheedlo/ghviz/mocks"
+
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockListRepositorieser struct {
+	mock.Mock
+}
+
+func (m *MockListRepositorieser) ListRepositories(
+	ctx stdcontext.Context,
+	logger *log.Logger,
+	owner string,
+) ([]github.Repository, *errors.HttpError) {
+	args := m.Called(logger, owner)
+	var repositories []github.Repository = nil
+	var err *errors.HttpError = nil
+	repositoriesArg := args.Get(0)
+	if repositoriesArg != nil {
+		repositories = repositoriesArg.([]github.Repository)
+	}
+	errArg := args.Get(1)
+	if errArg != nil {
+		err = errArg.(*errors.HttpError)
+	}
+	return repositories, err
+}
+
+var coolOrgRepositories []github.Repository = []github.Repository{
+	github.Repository{Name: "coolrepo", Owner: "coolorg"},
+	github.Repository{Name: "oldrepo", Owner: "coolorg", IsArchived: true},
+	github.Repository{Name: "forkedrepo", Owner: "coolorg", IsFork: true},
+	github.Repository{Name: "otherrepo", Owner: "coolorg"},
+}
+
+func TestOrgStarCounts(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	reposMock := &MockListRepositorieser{}
+	starsMock := &MockListStarEventser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/orgs/{org}/star_counts", OrgStarCounts(struct {
+		*MockListRepositorieser
+		*MockListStarEventser
+	}{reposMock, starsMock}))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/orgs/coolorg/star_counts", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	reposMock.On("ListRepositories", logger, "coolorg").Return(coolOrgRepositories, nil)
+	starsMock.
+		On("ListStarEvents", logger, "coolorg", "coolrepo").
+		Return([]github.StarEvent{
+			github.StarEvent{StarredAt: time.Unix(1, 0)},
+			github.StarEvent{StarredAt: time.Unix(3, 0)},
+		}, nil)
+	starsMock.
+		On("ListStarEvents", logger, "coolorg", "otherrepo").
+		Return([]github.StarEvent{github.StarEvent{StarredAt: time.Unix(2, 0)}}, nil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	reposMock.AssertExpectations(t)
+	starsMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var bodyContents []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &bodyContents))
+	assert.Len(t, bodyContents, 3)
+	for i, count := range bodyContents {
+		assert.Equal(t, float64(i+1), count["stars"].(float64))
+	}
+}
+
+func TestOrgStarCountsIncludeForks(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	reposMock := &MockListRepositorieser{}
+	starsMock := &MockListStarEventser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/orgs/{org}/star_counts", OrgStarCounts(struct {
+		*MockListRepositorieser
+		*MockListStarEventser
+	}{reposMock, starsMock}))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/orgs/coolorg/star_counts?include_forks=true", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	reposMock.On("ListRepositories", logger, "coolorg").Return(coolOrgRepositories, nil)
+	for _, repo := range []string{"coolrepo", "forkedrepo", "otherrepo"} {
+		starsMock.
+			On("ListStarEvents", logger, "coolorg", repo).
+			Return([]github.StarEvent{github.StarEvent{StarredAt: time.Unix(1, 0)}}, nil)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	reposMock.AssertExpectations(t)
+	starsMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var bodyContents []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &bodyContents))
+	assert.Len(t, bodyContents, 3)
+}
+
+func TestOrgStarCountsBadFilter(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	reposMock := &MockListRepositorieser{}
+	starsMock := &MockListStarEventser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/orgs/{org}/star_counts", OrgStarCounts(struct {
+		*MockListRepositorieser
+		*MockListStarEventser
+	}{reposMock, starsMock}))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/orgs/coolorg/star_counts?include_archived=maybe", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	reposMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, errors.CodeBadRequest, w.Header().Get("X-Error-Code"))
+}
+
+func TestOrgStarCountsError(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	reposMock := &MockListRepositorieser{}
+	starsMock := &MockListStarEventser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/orgs/{org}/star_counts", OrgStarCounts(struct {
+		*MockListRepositorieser
+		*MockListStarEventser
+	}{reposMock, starsMock}))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/orgs/coolorg/star_counts", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	reposMock.On("ListRepositories", logger, "coolorg").Return(coolOrgRepositories, nil)
+	starsMock.
+		On("ListStarEvents", logger, "coolorg", mock.AnythingOfType("string")).
+		Return(nil, &errors.HttpError{
+			Message: "Github API Error",
+			Status:  http.StatusBadGateway,
+		})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	reposMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, "Github API Error\n", w.Body.String())
+}
+
+func TestOrgOpenIssuesAndPrs(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	reposMock := &MockListRepositorieser{}
+	issuesMock := &MockListIssueser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/orgs/{org}/issue_counts", OrgOpenIssuesAndPrs(struct {
+		*MockListRepositorieser
+		*MockListIssueser
+	}{reposMock, issuesMock}))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/orgs/coolorg/issue_counts", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	reposMock.On("ListRepositories", logger, "coolorg").Return(coolOrgRepositories, nil)
+	issuesMock.
+		On("ListIssues", logger, "coolorg", "coolrepo").
+		Return([]github.Issue{github.Issue{CreatedAt: time.Unix(1, 0)}}, nil)
+	issuesMock.
+		On("ListIssues", logger, "coolorg", "otherrepo").
+		Return([]github.Issue{github.Issue{CreatedAt: time.Unix(2, 0), IsPr: true}}, nil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	reposMock.AssertExpectations(t)
+	issuesMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var bodyContents []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &bodyContents))
+	assert.Len(t, bodyContents, 2)
+	assert.Equal(t, 1.0, bodyContents[1]["open_issues"].(float64))
+	assert.Equal(t, 1.0, bodyContents[1]["open_prs"].(float64))
+}
+
+func TestOrgTopIssues(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	reposMock := &MockListRepositorieser{}
+	topIssuesMock := &MockListTopIssueser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/orgs/{org}/top_issues", OrgTopIssues(struct {
+		*MockListRepositorieser
+		*MockListTopIssueser
+	}{reposMock, topIssuesMock}))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/orgs/coolorg/top_issues", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	reposMock.On("ListRepositories", logger, "coolorg").Return(coolOrgRepositories, nil)
+	topIssuesMock.
+		On("ListTopIssues", logger, "coolorg", "coolrepo", 5, github.IssueFilter{}).
+		Return([]github.Issue{
+			github.Issue{Number: 2, CreatedAt: time.Unix(2, 0)},
+			github.Issue{Number: 1, CreatedAt: time.Unix(1, 0)},
+		}, nil)
+	topIssuesMock.
+		On("ListTopIssues", logger, "coolorg", "otherrepo", 5, github.IssueFilter{}).
+		Return([]github.Issue{github.Issue{Number: 7, CreatedAt: time.Unix(3, 0)}}, nil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	reposMock.AssertExpectations(t)
+	topIssuesMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var bodyContents []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &bodyContents))
+	assert.Len(t, bodyContents, 3)
+	assert.Equal(t, 7.0, bodyContents[0]["number"].(float64))
+	assert.Equal(t, 2.0, bodyContents[1]["number"].(float64))
+	assert.Equal(t, 1.0, bodyContents[2]["number"].(float64))
+}
@@ -1,9 +1,11 @@
 package routes
 
 import (
+	stdcontext "context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"sort"
 	"strconv"
@@ -12,6 +14,7 @@ import (
 	"github.com/gorilla/context"
 	"github.com/gorilla/mux"
 
+	"github.com/ksheedlo/ghviz/errors"
 	"github.com/ksheedlo/ghviz/github"
 	"github.com/ksheedlo/ghviz/interfaces"
 	"github.com/ksheedlo/ghviz/middleware"
@@ -19,14 +22,45 @@ import (
 	"github.com/ksheedlo/ghviz/simulate"
 )
 
+func writeHttpError(w http.ResponseWriter, err *errors.HttpError) {
+	if err.Code != "" {
+		w.Header().Set("X-Error-Code", err.Code)
+	}
+	if err.RetryAfter > 0 {
+		w.Header().Set(
+			"Retry-After",
+			strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))),
+		)
+	}
+	w.WriteHeader(err.Status)
+	fmt.Fprintf(w, "%s\n", err.Message)
+}
+
+// requestContext is the context for calls to Github. It records stale cache
+// hits for the MarkStale middleware, when that is in use.
+func requestContext(r *http.Request) stdcontext.Context {
+	if status, ok := context.Get(r, middleware.CtxCacheStatus).(*github.CacheStatus); ok {
+		return github.WithCacheStatus(r.Context(), status)
+	}
+	return r.Context()
+}
+
+func issueFilter(r *http.Request) github.IssueFilter {
+	query := r.URL.Query()
+	return github.IssueFilter{
+		Assignee:  query.Get("assignee"),
+		Label:     query.Get("label"),
+		Milestone: query.Get("milestone"),
+	}
+}
+
 func ListStarCounts(gh github.ListStarEventser) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := context.Get(r, middleware.CtxLog).(*log.Logger)
 		vars := mux.Vars(r)
-		starEvents, err := gh.ListStarEvents(logger, vars["owner"], vars["repo"])
+		starEvents, err := gh.ListStarEvents(requestContext(r), logger, vars["owner"], vars["repo"])
 		if err != nil {
-			w.WriteHeader(err.Status)
-			fmt.Fprintf(w, "%s\n", err.Message)
+			writeHttpError(w, err)
 			return
 		}
 		// Suppress JSON marshaling errors because we know we can always
@@ -37,17 +71,67 @@ func ListStarCounts(gh github.ListStarEventser) http.HandlerFunc {
 	}
 }
 
+func ListForkCounts(gh github.ListForkser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := context.Get(r, middleware.CtxLog).(*log.Logger)
+		vars := mux.Vars(r)
+		forkEvents, err := gh.ListForks(requestContext(r), logger, vars["owner"], vars["repo"])
+		if err != nil {
+			writeHttpError(w, err)
+			return
+		}
+		// Suppress JSON marshaling errors because we know we can always
+		// marshal `simulate.ForkCount`s.
+		jsonBlob, _ := json.Marshal(simulate.ForkCounts(forkEvents))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonBlob)
+	}
+}
+
+// WatcherCounts serves the watcher snapshots taken by the prewarm service.
+func WatcherCounts(redis interfaces.Rediser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := context.Get(r, middleware.CtxLog).(*log.Logger)
+		vars := mux.Vars(r)
+		snapshotJsons, redisErr := redis.ZRangeByScore(
+			r.Context(),
+			fmt.Sprintf("gh:repos:%s:%s:watcher_counts", vars["owner"], vars["repo"]),
+			&interfaces.ZRangeByScoreOpts{Min: "-inf", Max: "+inf"},
+		)
+		if redisErr != nil {
+			logger.Printf("ERROR: %s\n", redisErr.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"type":"error","code":500,"message":"Internal Server Error"}`))
+			return
+		}
+		watcherCounts := make([]simulate.WatcherCount, 0, len(snapshotJsons))
+		for _, snapshotJson := range snapshotJsons {
+			var watcherCount simulate.WatcherCount
+			if err := json.Unmarshal([]byte(snapshotJson), &watcherCount); err != nil {
+				logger.Printf("ERROR: %s; skipping watcher snapshot\n", err.Error())
+				continue
+			}
+			watcherCounts = append(watcherCounts, watcherCount)
+		}
+		// Suppress JSON marshaling errors because we know we can always
+		// marshal `simulate.WatcherCount`s.
+		jsonBlob, _ := json.Marshal(watcherCounts)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonBlob)
+	}
+}
+
 func ListOpenIssuesAndPrs(gh github.ListIssueser) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := context.Get(r, middleware.CtxLog).(*log.Logger)
 		vars := mux.Vars(r)
-		allIssues, err := gh.ListIssues(logger, vars["owner"], vars["repo"])
+		allIssues, err := gh.ListIssues(requestContext(r), logger, vars["owner"], vars["repo"])
 		if err != nil {
-			w.WriteHeader(err.Status)
-			w.Write([]byte(fmt.Sprintf("%s\n", err.Message)))
+			writeHttpError(w, err)
 			return
 		}
-		events := models.IssueEventsFromApi(allIssues)
+		events := models.IssueEventsFromApi(github.FilterIssues(allIssues, issueFilter(r)))
 		// Suppress JSON marshaling errors because we know we can always
 		// marshal `simulate.OpenIssueAndPrCount`s.
 		jsonBlob, _ := json.Marshal(simulate.OpenIssueAndPrCounts(events))
@@ -60,10 +144,16 @@ func TopIssues(gh github.ListTopIssueser) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := context.Get(r, middleware.CtxLog).(*log.Logger)
 		vars := mux.Vars(r)
-		allItems, httpErr := gh.ListTopIssues(logger, vars["owner"], vars["repo"], 5)
+		allItems, httpErr := gh.ListTopIssues(
+			requestContext(r),
+			logger,
+			vars["owner"],
+			vars["repo"],
+			5,
+			issueFilter(r),
+		)
 		if httpErr != nil {
-			w.WriteHeader(httpErr.Status)
-			w.Write([]byte(fmt.Sprintf("%s\n", httpErr.Message)))
+			writeHttpError(w, httpErr)
 			return
 		}
 		// Suppress JSON marshaling errors because we know we can always
@@ -78,10 +168,16 @@ func TopPrs(gh github.ListTopPrser) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := context.Get(r, middleware.CtxLog).(*log.Logger)
 		vars := mux.Vars(r)
-		allItems, httpErr := gh.ListTopPrs(logger, vars["owner"], vars["repo"], 5)
+		allItems, httpErr := gh.ListTopPrs(
+			requestContext(r),
+			logger,
+			vars["owner"],
+			vars["repo"],
+			5,
+			issueFilter(r),
+		)
 		if httpErr != nil {
-			w.WriteHeader(httpErr.Status)
-			w.Write([]byte(fmt.Sprintf("%s\n", httpErr.Message)))
+			writeHttpError(w, httpErr)
 			return
 		}
 		// Suppress JSON marshaling errors because we know we can always
@@ -92,6 +188,168 @@ func TopPrs(gh github.ListTopPrser) http.HandlerFunc {
 	}
 }
 
+func ListPullRequests(gh github.ListPullRequestser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := context.Get(r, middleware.CtxLog).(*log.Logger)
+		vars := mux.Vars(r)
+		pulls, httpErr := gh.ListPullRequests(requestContext(r), logger, vars["owner"], vars["repo"])
+		if httpErr != nil {
+			writeHttpError(w, httpErr)
+			return
+		}
+		// Suppress JSON marshaling errors because we know we can always
+		// marshal `github.PullRequest`s.
+		jsonBlob, _ := json.Marshal(pulls)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonBlob)
+	}
+}
+
+func commitPeriod(r *http.Request) (simulate.Period, *errors.HttpError) {
+	switch period := r.URL.Query().Get("period"); period {
+	case "", "day":
+		return simulate.Daily, nil
+	case "week":
+		return simulate.Weekly, nil
+	default:
+		return 0, &errors.HttpError{
+			Code:    errors.CodeBadRequest,
+			Message: fmt.Sprintf("%s is not a valid period, expected day or week", period),
+			Status:  http.StatusBadRequest,
+		}
+	}
+}
+
+func CommitCounts(gh github.ListCommitser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := context.Get(r, middleware.CtxLog).(*log.Logger)
+		vars := mux.Vars(r)
+		period, httpErr := commitPeriod(r)
+		if httpErr != nil {
+			writeHttpError(w, httpErr)
+			return
+		}
+		commits, httpErr := gh.ListCommits(requestContext(r), logger, vars["owner"], vars["repo"])
+		if httpErr != nil {
+			writeHttpError(w, httpErr)
+			return
+		}
+		// Suppress JSON marshaling errors because we know we can always
+		// marshal `simulate.CommitCount`s.
+		jsonBlob, _ := json.Marshal(simulate.CommitCounts(commits, period))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonBlob)
+	}
+}
+
+func CommitterCounts(gh github.ListCommitser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := context.Get(r, middleware.CtxLog).(*log.Logger)
+		vars := mux.Vars(r)
+		commits, httpErr := gh.ListCommits(requestContext(r), logger, vars["owner"], vars["repo"])
+		if httpErr != nil {
+			writeHttpError(w, httpErr)
+			return
+		}
+		// Suppress JSON marshaling errors because we know we can always
+		// marshal `simulate.CommitterCount`s.
+		jsonBlob, _ := json.Marshal(simulate.CommitterCounts(commits))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonBlob)
+	}
+}
+
+func ListReleases(gh github.ListReleaseser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := context.Get(r, middleware.CtxLog).(*log.Logger)
+		vars := mux.Vars(r)
+		releases, httpErr := gh.ListReleases(requestContext(r), logger, vars["owner"], vars["repo"])
+		if httpErr != nil {
+			writeHttpError(w, httpErr)
+			return
+		}
+		// Suppress JSON marshaling errors because we know we can always
+		// marshal `simulate.ReleaseStats`.
+		jsonBlob, _ := json.Marshal(simulate.ReleaseTimeline(releases))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonBlob)
+	}
+}
+
+// Traffic serves the traffic history accumulated by the prewarm service.
+func Traffic(redis interfaces.Rediser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := context.Get(r, middleware.CtxLog).(*log.Logger)
+		vars := mux.Vars(r)
+		owner := vars["owner"]
+		repo := vars["repo"]
+		historyJson, err := redis.Get(r.Context(), fmt.Sprintf("gh:repos:%s:%s:traffic", owner, repo))
+		if err != nil && err != interfaces.ErrNil {
+			logger.Printf("ERROR: %s\n", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"type":"error","code":500,"message":"Internal Server Error"}`))
+			return
+		}
+		if historyJson == "" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(fmt.Sprintf(
+				`{"type":"error","code":404,"message":"Traffic for %s/%s was not found."}`,
+				owner,
+				repo,
+			)))
+			return
+		}
+		history := &models.TrafficHistory{}
+		if err := json.Unmarshal([]byte(historyJson), history); err != nil {
+			logger.Printf("ERROR: %s\n", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"type":"error","code":500,"message":"Internal Server Error"}`))
+			return
+		}
+		// Suppress JSON marshaling errors because we know we can always
+		// marshal `models.TrafficHistory`s.
+		jsonBlob, _ := json.Marshal(history)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonBlob)
+	}
+}
+
+func ContributorStats(gh github.ListContributorStatser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := context.Get(r, middleware.CtxLog).(*log.Logger)
+		vars := mux.Vars(r)
+		contributors, httpErr := gh.ListContributorStats(requestContext(r), logger, vars["owner"], vars["repo"])
+		if httpErr != nil {
+			writeHttpError(w, httpErr)
+			return
+		}
+		// Suppress JSON marshaling errors because we know we can always
+		// marshal `github.ContributorStats`.
+		jsonBlob, _ := json.Marshal(contributors)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonBlob)
+	}
+}
+
+func RateLimit(gh github.GetRateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := context.Get(r, middleware.CtxLog).(*log.Logger)
+		rateLimit, err := gh.GetRateLimit(requestContext(r), logger)
+		if err != nil {
+			writeHttpError(w, err)
+			return
+		}
+		// Suppress JSON marshaling errors because we know we can always
+		// marshal `github.RateLimit`s.
+		jsonBlob, _ := json.Marshal(rateLimit)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonBlob)
+	}
+}
+
 func HighScores(redis interfaces.Rediser) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := context.Get(r, middleware.CtxLog).(*log.Logger)
@@ -135,6 +393,7 @@ func HighScores(redis interfaces.Rediser) http.HandlerFunc {
 			10,
 		)
 		eventSetId, err := redis.Get(
+			r.Context(),
 			fmt.Sprintf("gh:repos:%s:%s:issue_event_setid", owner, repo),
 		)
 		if err != nil || eventSetId == "" {
@@ -149,6 +408,7 @@ func HighScores(redis interfaces.Rediser) http.HandlerFunc {
 		}
 
 		scoringEventJsons, redisErr := redis.ZRangeByScore(
+			r.Context(),
 			fmt.Sprintf("gh:repos:%s:%s:issue_events:%s", owner, repo, eventSetId),
 			&interfaces.ZRangeByScoreOpts{Min: startDate, Max: endDate},
 		)
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	stdcontext "context"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -27,6 +28,7 @@ type MockListStarEventser struct {
 }
 
 func (m *MockListStarEventser) ListStarEvents(
+	ctx stdcontext.Context,
 	logger *log.Logger,
 	owner, repo string,
 ) ([]github.StarEvent, *errors.HttpError) {
@@ -104,6 +106,7 @@ type MockListIssueser struct {
 }
 
 func (m *MockListIssueser) ListIssues(
+	ctx stdcontext.Context,
 	logger *log.Logger,
 	owner, repo string,
 ) ([]github.Issue, *errors.HttpError) {
@@ -163,6 +166,38 @@ func TestListOpenIssuesAndPrs(t *testing.T) {
 	assert.Equal(t, 1.0, bodyContents[len(bodyContents)-1]["open_issues"].(float64))
 }
 
+func TestListOpenIssuesAndPrsFilter(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	ghMock := &MockListIssueser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}", ListOpenIssuesAndPrs(ghMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/coolrepo?label=bug", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	ghMock.
+		On("ListIssues", logger, "tester1", "coolrepo").
+		Return([]github.Issue{
+			github.Issue{
+				CreatedAt: time.Unix(1, 0),
+				Labels:    []github.Label{github.Label{Name: "bug"}},
+			},
+			github.Issue{CreatedAt: time.Unix(2, 0)},
+			github.Issue{CreatedAt: time.Unix(3, 0), IsPr: true},
+		}, nil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ghMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var bodyContents []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &bodyContents))
+	assert.Len(t, bodyContents, 1)
+	assert.Equal(t, 1.0, bodyContents[0]["open_issues"].(float64))
+}
+
 func TestListIssuesError(t *testing.T) {
 	t.Parallel()
 
@@ -193,11 +228,13 @@ type MockListTopIssueser struct {
 }
 
 func (m *MockListTopIssueser) ListTopIssues(
+	ctx stdcontext.Context,
 	logger *log.Logger,
 	owner, repo string,
 	limit int,
+	filter github.IssueFilter,
 ) ([]github.Issue, *errors.HttpError) {
-	args := m.Called(logger, owner, repo, limit)
+	args := m.Called(logger, owner, repo, limit, filter)
 	var issues []github.Issue = nil
 	var err *errors.HttpError = nil
 	issuesArg := args.Get(0)
@@ -222,7 +259,7 @@ func TestTopIssues(t *testing.T) {
 	context.Set(req, middleware.CtxLog, logger)
 
 	ghMock.
-		On("ListTopIssues", logger, "tester1", "coolrepo", 5).
+		On("ListTopIssues", logger, "tester1", "coolrepo", 5, github.IssueFilter{}).
 		Return([]github.Issue{
 			github.Issue{Title: "Test Issue 1"},
 			github.Issue{Title: "Test Issue 2"},
@@ -253,7 +290,7 @@ func TestTopIssuesError(t *testing.T) {
 	context.Set(req, middleware.CtxLog, logger)
 
 	ghMock.
-		On("ListTopIssues", logger, "tester1", "coolrepo", 5).
+		On("ListTopIssues", logger, "tester1", "coolrepo", 5, github.IssueFilter{}).
 		Return(nil, &errors.HttpError{
 			Message: "Github API Error",
 			Status:  http.StatusInternalServerError,
@@ -267,16 +304,45 @@ func TestTopIssuesError(t *testing.T) {
 	assert.Equal(t, "Github API Error\n", w.Body.String())
 }
 
+func TestTopIssuesFilter(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	ghMock := &MockListTopIssueser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}", TopIssues(ghMock))
+	req := mocks.NewHttpRequest(
+		t,
+		"GET",
+		"http://example.com/tester1/coolrepo?label=bug&assignee=tester2&milestone=v1.0",
+		nil,
+	)
+	context.Set(req, middleware.CtxLog, logger)
+
+	filter := github.IssueFilter{Assignee: "tester2", Label: "bug", Milestone: "v1.0"}
+	ghMock.
+		On("ListTopIssues", logger, "tester1", "coolrepo", 5, filter).
+		Return([]github.Issue{github.Issue{Title: "Test Issue 1"}}, nil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ghMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
 type MockListTopPrser struct {
 	mock.Mock
 }
 
 func (m *MockListTopPrser) ListTopPrs(
+	ctx stdcontext.Context,
 	logger *log.Logger,
 	owner, repo string,
 	limit int,
+	filter github.IssueFilter,
 ) ([]github.Issue, *errors.HttpError) {
-	args := m.Called(logger, owner, repo, limit)
+	args := m.Called(logger, owner, repo, limit, filter)
 	var issues []github.Issue = nil
 	var err *errors.HttpError = nil
 	issuesArg := args.Get(0)
@@ -301,7 +367,7 @@ func TestTopPrs(t *testing.T) {
 	context.Set(req, middleware.CtxLog, logger)
 
 	ghMock.
-		On("ListTopPrs", logger, "tester1", "coolrepo", 5).
+		On("ListTopPrs", logger, "tester1", "coolrepo", 5, github.IssueFilter{}).
 		Return([]github.Issue{
 			github.Issue{Title: "Test PR 1", IsPr: true},
 			github.Issue{Title: "Test PR 2", IsPr: true},
@@ -332,7 +398,7 @@ func TestTopPrsError(t *testing.T) {
 	context.Set(req, middleware.CtxLog, logger)
 
 	ghMock.
-		On("ListTopPrs", logger, "tester1", "coolrepo", 5).
+		On("ListTopPrs", logger, "tester1", "coolrepo", 5, github.IssueFilter{}).
 		Return(nil, &errors.HttpError{
 			Message: "Github API Error",
 			Status:  http.StatusInternalServerError,
@@ -636,3 +702,694 @@ func TestHighScoresYearWraparound(t *testing.T) {
 	assert.Equal(t, "tester2", bodyContents[1]["actor_id"].(string))
 	assert.Equal(t, 1000, int(bodyContents[1]["score"].(float64)))
 }
+
+func TestRateLimitedErrorSetsRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	ghMock := &MockListStarEventser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}", ListStarCounts(ghMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/coolrepo", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	ghMock.
+		On("ListStarEvents", logger, "tester1", "coolrepo").
+		Return(nil, &errors.HttpError{
+			Message:    "Github Rate Limit Exceeded",
+			RetryAfter: 1500 * time.Millisecond,
+			Status:     http.StatusServiceUnavailable,
+		})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ghMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Equal(t, "Github Rate Limit Exceeded\n", w.Body.String())
+}
+
+func TestRepositoryNotFound(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	ghMock := &MockListIssueser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}", ListOpenIssuesAndPrs(ghMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/nope", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	ghMock.
+		On("ListIssues", logger, "tester1", "nope").
+		Return(nil, &errors.HttpError{
+			Code:           errors.CodeNotFound,
+			Message:        "Repository Not Found",
+			Status:         http.StatusNotFound,
+			UpstreamStatus: http.StatusNotFound,
+		})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ghMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, errors.CodeNotFound, w.Header().Get("X-Error-Code"))
+	assert.Equal(t, "Repository Not Found\n", w.Body.String())
+}
+
+type MockListPullRequestser struct {
+	mock.Mock
+}
+
+func (m *MockListPullRequestser) ListPullRequests(
+	ctx stdcontext.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]github.PullRequest, *errors.HttpError) {
+	args := m.Called(logger, owner, repo)
+	var pulls []github.PullRequest = nil
+	var err *errors.HttpError = nil
+	pullsArg := args.Get(0)
+	if pullsArg != nil {
+		pulls = pullsArg.([]github.PullRequest)
+	}
+	errArg := args.Get(1)
+	if errArg != nil {
+		err = errArg.(*errors.HttpError)
+	}
+	return pulls, err
+}
+
+func TestListPullRequests(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	ghMock := &MockListPullRequestser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}/pulls", ListPullRequests(ghMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/coolrepo/pulls", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	ghMock.
+		On("ListPullRequests", logger, "tester1", "coolrepo").
+		Return([]github.PullRequest{
+			github.PullRequest{Additions: 10, Deletions: 2, Number: 1},
+		}, nil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ghMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var bodyContents []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &bodyContents))
+	assert.Len(t, bodyContents, 1)
+	assert.Equal(t, 10.0, bodyContents[0]["additions"].(float64))
+	assert.Equal(t, 2.0, bodyContents[0]["deletions"].(float64))
+}
+
+func TestListPullRequestsError(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	ghMock := &MockListPullRequestser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}/pulls", ListPullRequests(ghMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/coolrepo/pulls", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	ghMock.
+		On("ListPullRequests", logger, "tester1", "coolrepo").
+		Return(nil, &errors.HttpError{
+			Message: "Github API Error",
+			Status:  http.StatusBadGateway,
+		})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ghMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, "Github API Error\n", w.Body.String())
+}
+
+type MockListCommitser struct {
+	mock.Mock
+}
+
+func (m *MockListCommitser) ListCommits(
+	ctx stdcontext.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]github.Commit, *errors.HttpError) {
+	args := m.Called(logger, owner, repo)
+	var commits []github.Commit = nil
+	var err *errors.HttpError = nil
+	commitsArg := args.Get(0)
+	if commitsArg != nil {
+		commits = commitsArg.([]github.Commit)
+	}
+	errArg := args.Get(1)
+	if errArg != nil {
+		err = errArg.(*errors.HttpError)
+	}
+	return commits, err
+}
+
+func TestCommitCountsWeekly(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	ghMock := &MockListCommitser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}/commit_counts", CommitCounts(ghMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/coolrepo/commit_counts?period=week", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	ghMock.
+		On("ListCommits", logger, "tester1", "coolrepo").
+		Return([]github.Commit{
+			github.Commit{Author: "tester1", CommittedAt: time.Date(2016, 3, 9, 0, 0, 0, 0, time.UTC)},
+			github.Commit{Author: "tester2", CommittedAt: time.Date(2016, 3, 7, 0, 0, 0, 0, time.UTC)},
+		}, nil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ghMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var bodyContents []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &bodyContents))
+	assert.Len(t, bodyContents, 1)
+	assert.Equal(t, 2.0, bodyContents[0]["commits"].(float64))
+	assert.Equal(t, "2016-03-07T00:00:00Z", bodyContents[0]["timestamp"].(string))
+}
+
+func TestCommitCountsInvalidPeriod(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	ghMock := &MockListCommitser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}/commit_counts", CommitCounts(ghMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/coolrepo/commit_counts?period=fortnight", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ghMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, errors.CodeBadRequest, w.Header().Get("X-Error-Code"))
+}
+
+func TestCommitterCounts(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	ghMock := &MockListCommitser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}/committer_counts", CommitterCounts(ghMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/coolrepo/committer_counts", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	ghMock.
+		On("ListCommits", logger, "tester1", "coolrepo").
+		Return([]github.Commit{
+			github.Commit{Author: "tester1", CommittedAt: time.Date(2016, 3, 9, 0, 0, 0, 0, time.UTC)},
+			github.Commit{Author: "tester2", CommittedAt: time.Date(2016, 3, 7, 0, 0, 0, 0, time.UTC)},
+			github.Commit{Author: "tester1", CommittedAt: time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC)},
+		}, nil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ghMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var bodyContents []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &bodyContents))
+	assert.Len(t, bodyContents, 1)
+	assert.Equal(t, 2.0, bodyContents[0]["committers"].(float64))
+}
+
+func TestCommitterCountsError(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	ghMock := &MockListCommitser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}/committer_counts", CommitterCounts(ghMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/coolrepo/committer_counts", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	ghMock.
+		On("ListCommits", logger, "tester1", "coolrepo").
+		Return(nil, &errors.HttpError{
+			Message: "Github API Error",
+			Status:  http.StatusBadGateway,
+		})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ghMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, "Github API Error\n", w.Body.String())
+}
+
+type MockListReleaseser struct {
+	mock.Mock
+}
+
+func (m *MockListReleaseser) ListReleases(
+	ctx stdcontext.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]github.Release, *errors.HttpError) {
+	args := m.Called(logger, owner, repo)
+	var releases []github.Release = nil
+	var err *errors.HttpError = nil
+	releasesArg := args.Get(0)
+	if releasesArg != nil {
+		releases = releasesArg.([]github.Release)
+	}
+	errArg := args.Get(1)
+	if errArg != nil {
+		err = errArg.(*errors.HttpError)
+	}
+	return releases, err
+}
+
+func TestListReleases(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	ghMock := &MockListReleaseser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}/releases", ListReleases(ghMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/coolrepo/releases", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	ghMock.
+		On("ListReleases", logger, "tester1", "coolrepo").
+		Return([]github.Release{
+			github.Release{
+				AssetDownloads: 5,
+				PublishedAt:    time.Date(2016, 3, 3, 0, 0, 0, 0, time.UTC),
+				TagName:        "v1.1.0",
+			},
+			github.Release{
+				AssetDownloads: 3,
+				PublishedAt:    time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC),
+				TagName:        "v1.0.0",
+			},
+		}, nil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ghMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var bodyContents []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &bodyContents))
+	assert.Len(t, bodyContents, 2)
+	assert.Equal(t, "v1.0.0", bodyContents[0]["tag_name"].(string))
+	assert.Equal(t, "v1.1.0", bodyContents[1]["tag_name"].(string))
+	assert.Equal(t, 2.0, bodyContents[1]["days_since_previous"].(float64))
+	assert.Equal(t, 8.0, bodyContents[1]["cumulative_downloads"].(float64))
+}
+
+func TestListReleasesError(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	ghMock := &MockListReleaseser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}/releases", ListReleases(ghMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/coolrepo/releases", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	ghMock.
+		On("ListReleases", logger, "tester1", "coolrepo").
+		Return(nil, &errors.HttpError{
+			Message: "Github API Error",
+			Status:  http.StatusBadGateway,
+		})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ghMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, "Github API Error\n", w.Body.String())
+}
+
+type MockListForkser struct {
+	mock.Mock
+}
+
+func (m *MockListForkser) ListForks(
+	ctx stdcontext.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]github.ForkEvent, *errors.HttpError) {
+	args := m.Called(logger, owner, repo)
+	var forkEvents []github.ForkEvent = nil
+	var err *errors.HttpError = nil
+	forkEventsArg := args.Get(0)
+	if forkEventsArg != nil {
+		forkEvents = forkEventsArg.([]github.ForkEvent)
+	}
+	errArg := args.Get(1)
+	if errArg != nil {
+		err = errArg.(*errors.HttpError)
+	}
+	return forkEvents, err
+}
+
+func TestListForkCounts(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	ghMock := &MockListForkser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}/fork_counts", ListForkCounts(ghMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/coolrepo/fork_counts", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	ghMock.
+		On("ListForks", logger, "tester1", "coolrepo").
+		Return([]github.ForkEvent{
+			github.ForkEvent{ForkedAt: time.Unix(1, 0)},
+			github.ForkEvent{ForkedAt: time.Unix(2, 0)},
+		}, nil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ghMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var bodyContents []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &bodyContents))
+	assert.Len(t, bodyContents, 2)
+	assert.Equal(t, 2.0, bodyContents[1]["forks"].(float64))
+}
+
+func TestListForkCountsError(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	ghMock := &MockListForkser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}/fork_counts", ListForkCounts(ghMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/coolrepo/fork_counts", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	ghMock.
+		On("ListForks", logger, "tester1", "coolrepo").
+		Return(nil, &errors.HttpError{
+			Message: "Github API Error",
+			Status:  http.StatusBadGateway,
+		})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ghMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, "Github API Error\n", w.Body.String())
+}
+
+func TestWatcherCounts(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	redisMock := &mocks.MockRediser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}/watcher_counts", WatcherCounts(redisMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/coolrepo/watcher_counts", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	redisMock.
+		On(
+			"ZRangeByScore",
+			"gh:repos:tester1:coolrepo:watcher_counts",
+			&interfaces.ZRangeByScoreOpts{Min: "-inf", Max: "+inf"},
+		).
+		Return([]string{
+			`{"timestamp":"2016-03-07T00:00:00Z","watchers":5}`,
+			`not json`,
+			`{"timestamp":"2016-03-08T00:00:00Z","watchers":7}`,
+		}, nil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	redisMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var bodyContents []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &bodyContents))
+	assert.Len(t, bodyContents, 2)
+	assert.Equal(t, 5.0, bodyContents[0]["watchers"].(float64))
+	assert.Equal(t, "2016-03-08T00:00:00Z", bodyContents[1]["timestamp"].(string))
+}
+
+func TestWatcherCountsEmpty(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	redisMock := &mocks.MockRediser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}/watcher_counts", WatcherCounts(redisMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/coolrepo/watcher_counts", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	redisMock.
+		On(
+			"ZRangeByScore",
+			"gh:repos:tester1:coolrepo:watcher_counts",
+			&interfaces.ZRangeByScoreOpts{Min: "-inf", Max: "+inf"},
+		).
+		Return([]string{}, nil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]", w.Body.String())
+}
+
+func TestTraffic(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	redisMock := &mocks.MockRediser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}/traffic", Traffic(redisMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/coolrepo/traffic", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	redisMock.
+		On("Get", "gh:repos:tester1:coolrepo:traffic").
+		Return(`{"views":[{"count":10,"timestamp":"2016-03-07T00:00:00Z","uniques":3}],
+			"referrers":[{"date":"2016-03-08T00:00:00Z","referrers":[{"referrer":"google.com","count":8,"uniques":3}]}]}`, nil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	redisMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var bodyContents map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &bodyContents))
+	views := bodyContents["views"].([]interface{})
+	assert.Len(t, views, 1)
+	assert.Equal(t, 10.0, views[0].(map[string]interface{})["count"].(float64))
+	assert.Len(t, bodyContents["clones"].([]interface{}), 0)
+	referrers := bodyContents["referrers"].([]interface{})
+	assert.Len(t, referrers, 1)
+	assert.Equal(t, "2016-03-08T00:00:00Z", referrers[0].(map[string]interface{})["date"].(string))
+}
+
+func TestTrafficNotFound(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	redisMock := &mocks.MockRediser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}/traffic", Traffic(redisMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/coolrepo/traffic", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	redisMock.On("Get", "gh:repos:tester1:coolrepo:traffic").Return("", interfaces.ErrNil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	var bodyContents map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &bodyContents))
+	assert.Equal(t, "Traffic for tester1/coolrepo was not found.", bodyContents["message"].(string))
+}
+
+func TestTrafficRedisError(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	redisMock := &mocks.MockRediser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}/traffic", Traffic(redisMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/coolrepo/traffic", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	redisMock.On("Get", "gh:repos:tester1:coolrepo:traffic").Return("", mocks.ConstantError("Redis Error"))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+type MockListContributorStatser struct {
+	mock.Mock
+}
+
+func (m *MockListContributorStatser) ListContributorStats(
+	ctx stdcontext.Context,
+	logger *log.Logger,
+	owner, repo string,
+) ([]github.ContributorStats, *errors.HttpError) {
+	args := m.Called(logger, owner, repo)
+	var contributors []github.ContributorStats = nil
+	var err *errors.HttpError = nil
+	contributorsArg := args.Get(0)
+	if contributorsArg != nil {
+		contributors = contributorsArg.([]github.ContributorStats)
+	}
+	errArg := args.Get(1)
+	if errArg != nil {
+		err = errArg.(*errors.HttpError)
+	}
+	return contributors, err
+}
+
+func TestContributorStats(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	ghMock := &MockListContributorStatser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}/contributor_stats", ContributorStats(ghMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/coolrepo/contributor_stats", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	ghMock.
+		On("ListContributorStats", logger, "tester1", "coolrepo").
+		Return([]github.ContributorStats{
+			github.ContributorStats{
+				Author:       "tester1",
+				TotalCommits: 2,
+				Weeks: []github.WeeklyStats{
+					github.WeeklyStats{Additions: 10, Commits: 2, Deletions: 3, Week: time.Unix(1457222400, 0).UTC()},
+				},
+			},
+		}, nil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ghMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var bodyContents []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &bodyContents))
+	assert.Len(t, bodyContents, 1)
+	assert.Equal(t, "tester1", bodyContents[0]["author"].(string))
+	week := bodyContents[0]["weeks"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, 10.0, week["additions"].(float64))
+	assert.Equal(t, 3.0, week["deletions"].(float64))
+	assert.Equal(t, "2016-03-06T00:00:00Z", week["week"].(string))
+}
+
+func TestContributorStatsNotReady(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	ghMock := &MockListContributorStatser{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/{owner}/{repo}/contributor_stats", ContributorStats(ghMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/tester1/coolrepo/contributor_stats", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	ghMock.
+		On("ListContributorStats", logger, "tester1", "coolrepo").
+		Return(nil, &errors.HttpError{
+			Code:       errors.CodeNotReady,
+			Message:    "Github Is Still Computing Statistics",
+			RetryAfter: 8 * time.Second,
+			Status:     http.StatusServiceUnavailable,
+		})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ghMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "8", w.Header().Get("Retry-After"))
+	assert.Equal(t, errors.CodeNotReady, w.Header().Get("X-Error-Code"))
+}
+
+type MockGetRateLimiter struct {
+	mock.Mock
+}
+
+func (m *MockGetRateLimiter) GetRateLimit(ctx stdcontext.Context, logger *log.Logger) (*github.RateLimit, *errors.HttpError) {
+	args := m.Called(logger)
+	var rateLimit *github.RateLimit = nil
+	var err *errors.HttpError = nil
+	rateLimitArg := args.Get(0)
+	if rateLimitArg != nil {
+		rateLimit = rateLimitArg.(*github.RateLimit)
+	}
+	errArg := args.Get(1)
+	if errArg != nil {
+		err = errArg.(*errors.HttpError)
+	}
+	return rateLimit, err
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	r := mux.NewRouter()
+	ghMock := &MockGetRateLimiter{}
+	logger := mocks.DummyLogger(t)
+	r.HandleFunc("/rate_limit", RateLimit(ghMock))
+	req := mocks.NewHttpRequest(t, "GET", "http://example.com/rate_limit", nil)
+	context.Set(req, middleware.CtxLog, logger)
+
+	ghMock.
+		On("GetRateLimit", logger).
+		Return(&github.RateLimit{
+			Limit:     5000,
+			Remaining: 4321,
+			Reset:     time.Unix(1458966366, 0),
+		}, nil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	ghMock.AssertExpectations(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var bodyContents map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &bodyContents))
+	assert.Equal(t, 5000.0, bodyContents["limit"].(float64))
+	assert.Equal(t, 4321.0, bodyContents["remaining"].(float64))
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/ksheedlo/ghviz/github"
 	"github.com/ksheedlo/ghviz/interfaces"
@@ -21,51 +25,143 @@ func withDefaultStr(config, default_ string) string {
 	return config
 }
 
+const COMMITS_USAGE string = `Prewarm the commit history of the default branch into the cache.`
+
+const FORKS_USAGE string = `Prewarm fork events into the cache.`
+
 const HIGH_SCORES_USAGE string = `Prewarm the list of high scores (i.e., all time monthly top contributors)
         into the cache. Recommended, as this is an expensive operation.`
 
 const ISSUES_USAGE string = `Prewarm Github issues into the cache.`
 
+const PULLS_USAGE string = `Prewarm pull requests, along with their sizes, into the cache.`
+
+const RELEASES_USAGE string = `Prewarm releases and tags into the cache.`
+
 const STARGAZERS_USAGE string = `Prewarm star events into the cache.`
 
+const STATS_USAGE string = `Prewarm contributor statistics and code frequency into the cache. Github
+        computes these in the background, so this may take a while on a cold repo.`
+
 const TOP_ISSUES_USAGE string = `Specify the number of top issues to prewarm into the cache. Set to 0 or
         leave empty to not fetch top issues.`
 
 const TOP_PRS_USAGE string = `Specify the number of top PRs to prewarm into the cache. Set to 0 or leave
         empty to not fetch top issues.`
 
+const TRAFFIC_USAGE string = `Merge the last 14 days of views, clones, referrers and popular paths into
+        the traffic history. Run at least weekly to keep the history complete.`
+
+const WATCHERS_USAGE string = `Record a snapshot of the watcher count. Github keeps no watcher history,
+        so run this on a schedule, or pair it with -watchers-interval, to chart
+        watchers over time.`
+
+const WATCHERS_INTERVAL_USAGE string = `Keep recording watcher snapshots at this interval (e.g. 1h) until
+        interrupted. Requires -watchers.`
+
 func main() {
-	redisPort := withDefaultStr(os.Getenv("GHVIZ_REDIS_PORT"), "6379")
-	redisHost := os.Getenv("GHVIZ_REDIS_HOST")
-	redisClient := interfaces.NewGoRedis(redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%s", redisHost, redisPort),
-		Password: os.Getenv("GHVIZ_REDIS_PASSWORD"),
-		DB:       0,
-	}))
-
-	gh := github.NewClient(&github.Options{
-		MaxStaleness: -1,
-		RedisClient:  redisClient,
-		Token:        os.Getenv("GITHUB_TOKEN"),
+	// Pick the store the same way the web service does, so that both agree
+	// when several are configured. Without a bolt file, there is nothing to
+	// prewarm but Redis.
+	var redisClient interfaces.Rediser
+	var boltRedis *interfaces.BoltRedis
+	boltPath := os.Getenv("GHVIZ_BOLT_PATH")
+	if redisHost := os.Getenv("GHVIZ_REDIS_HOST"); redisHost != "" || boltPath == "" {
+		redisPort := withDefaultStr(os.Getenv("GHVIZ_REDIS_PORT"), "6379")
+		redisClient = interfaces.NewGoRedis(redis.NewClient(&redis.Options{
+			Addr:     fmt.Sprintf("%s:%s", redisHost, redisPort),
+			Password: os.Getenv("GHVIZ_REDIS_PASSWORD"),
+			DB:       0,
+		}))
+	} else {
+		// bbolt locks the file, so the web service has to be stopped or
+		// pointed at another file while we prewarm this one.
+		var err error
+		boltRedis, err = interfaces.OpenBoltRedis(clockwork.NewRealClock(), boltPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open %s: %s\n", boltPath, err.Error())
+			os.Exit(2)
+		}
+		redisClient = boltRedis
+	}
+
+	options, err := github.OptionsFromEnv(&github.Options{
+		BreakerCoolOff:   time.Minute,
+		BreakerThreshold: 10,
+		MaxRateLimitWait: time.Hour,
+		MaxRetries:       5,
+		MaxStaleness:     -1,
+		RedisClient:      redisClient,
+		RequestTimeout:   time.Minute,
 	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(2)
+	}
+	gh, err := github.NewBackend(os.Getenv("GHVIZ_GITHUB_API"), options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(2)
+	}
 	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime|log.Lshortfile|log.LUTC)
 	owner := os.Getenv("GHVIZ_OWNER")
 	repo := os.Getenv("GHVIZ_REPO")
 
+	prewarmCommits := flag.Bool("commits", false, COMMITS_USAGE)
+	prewarmForks := flag.Bool("forks", false, FORKS_USAGE)
 	prewarmHighScores := flag.Bool("high-scores", false, HIGH_SCORES_USAGE)
 	prewarmIssues := flag.Bool("issues", false, ISSUES_USAGE)
+	prewarmPulls := flag.Bool("pulls", false, PULLS_USAGE)
+	prewarmReleases := flag.Bool("releases", false, RELEASES_USAGE)
 	prewarmStarEvents := flag.Bool("star-events", false, STARGAZERS_USAGE)
+	prewarmStats := flag.Bool("stats", false, STATS_USAGE)
 	prewarmTopIssues := flag.Int("top-issues", 0, TOP_ISSUES_USAGE)
 	prewarmTopPrs := flag.Int("top-prs", 0, TOP_PRS_USAGE)
+	prewarmTraffic := flag.Bool("traffic", false, TRAFFIC_USAGE)
+	snapshotWatchers := flag.Bool("watchers", false, WATCHERS_USAGE)
+	watchersInterval := flag.Duration("watchers-interval", 0, WATCHERS_INTERVAL_USAGE)
 
 	flag.Parse()
 
+	// Abandon in-flight requests to Github and Redis when we are asked to stop.
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		logger.Printf("Received %s, canceling prewarm tasks.\n", sig.String())
+		cancel()
+	}()
+
 	errChan := make(chan int)
 	pendingTasks := 0
+	if *prewarmCommits {
+		pendingTasks++
+		go func() {
+			if _, err := gh.ListCommits(ctx, logger, owner, repo); err != nil {
+				logger.Printf("ERROR: %s\n", err.Error())
+				errChan <- 1
+			} else {
+				errChan <- 0
+			}
+		}()
+	}
+	if *prewarmForks {
+		pendingTasks++
+		go func() {
+			if _, err := gh.ListForks(ctx, logger, owner, repo); err != nil {
+				logger.Printf("ERROR: %s\n", err.Error())
+				errChan <- 1
+			} else {
+				errChan <- 0
+			}
+		}()
+	}
 	if *prewarmHighScores {
 		pendingTasks++
 		go func() {
 			if err := prewarm.PrewarmHighScores(
+				ctx,
 				logger,
 				gh,
 				redisClient,
@@ -84,7 +180,37 @@ func main() {
 	if *prewarmIssues {
 		pendingTasks++
 		go func() {
-			if _, err := gh.ListIssues(logger, owner, repo); err != nil {
+			if _, err := gh.ListIssues(ctx, logger, owner, repo); err != nil {
+				logger.Printf("ERROR: %s\n", err.Error())
+				errChan <- 1
+			} else {
+				errChan <- 0
+			}
+		}()
+	}
+	if *prewarmPulls {
+		pendingTasks++
+		go func() {
+			if _, err := gh.ListPullRequests(ctx, logger, owner, repo); err != nil {
+				logger.Printf("ERROR: %s\n", err.Error())
+				errChan <- 1
+			} else {
+				errChan <- 0
+			}
+		}()
+	}
+	if *prewarmReleases {
+		pendingTasks += 2
+		go func() {
+			if _, err := gh.ListReleases(ctx, logger, owner, repo); err != nil {
+				logger.Printf("ERROR: %s\n", err.Error())
+				errChan <- 1
+			} else {
+				errChan <- 0
+			}
+		}()
+		go func() {
+			if _, err := gh.ListTags(ctx, logger, owner, repo); err != nil {
 				logger.Printf("ERROR: %s\n", err.Error())
 				errChan <- 1
 			} else {
@@ -95,7 +221,26 @@ func main() {
 	if *prewarmStarEvents {
 		pendingTasks++
 		go func() {
-			if _, err := gh.ListStarEvents(logger, owner, repo); err != nil {
+			if _, err := gh.ListStarEvents(ctx, logger, owner, repo); err != nil {
+				logger.Printf("ERROR: %s\n", err.Error())
+				errChan <- 1
+			} else {
+				errChan <- 0
+			}
+		}()
+	}
+	if *prewarmStats {
+		pendingTasks += 2
+		go func() {
+			if _, err := gh.ListContributorStats(ctx, logger, owner, repo); err != nil {
+				logger.Printf("ERROR: %s\n", err.Error())
+				errChan <- 1
+			} else {
+				errChan <- 0
+			}
+		}()
+		go func() {
+			if _, err := gh.ListCodeFrequency(ctx, logger, owner, repo); err != nil {
 				logger.Printf("ERROR: %s\n", err.Error())
 				errChan <- 1
 			} else {
@@ -106,7 +251,7 @@ func main() {
 	if *prewarmTopIssues > 0 {
 		pendingTasks++
 		go func() {
-			if _, err := gh.ListTopIssues(logger, owner, repo, *prewarmTopIssues); err != nil {
+			if _, err := gh.ListTopIssues(ctx, logger, owner, repo, *prewarmTopIssues, github.IssueFilter{}); err != nil {
 				logger.Printf("ERROR: %s\n", err.Error())
 				errChan <- 1
 			} else {
@@ -117,7 +262,52 @@ func main() {
 	if *prewarmTopPrs > 0 {
 		pendingTasks++
 		go func() {
-			if _, err := gh.ListTopPrs(logger, owner, repo, *prewarmTopPrs); err != nil {
+			if _, err := gh.ListTopPrs(ctx, logger, owner, repo, *prewarmTopPrs, github.IssueFilter{}); err != nil {
+				logger.Printf("ERROR: %s\n", err.Error())
+				errChan <- 1
+			} else {
+				errChan <- 0
+			}
+		}()
+	}
+	if *prewarmTraffic {
+		pendingTasks++
+		go func() {
+			if err := prewarm.PrewarmTraffic(
+				ctx,
+				logger,
+				gh,
+				redisClient,
+				clockwork.NewRealClock(),
+				owner,
+				repo,
+			); err != nil {
+				logger.Printf("ERROR: %s\n", err.Error())
+				errChan <- 1
+			} else {
+				errChan <- 0
+			}
+		}()
+	}
+	if *snapshotWatchers {
+		pendingTasks++
+		go func() {
+			var err error
+			if *watchersInterval > 0 {
+				err = prewarm.SnapshotWatchersEvery(
+					ctx,
+					logger,
+					gh,
+					redisClient,
+					clockwork.NewRealClock(),
+					*watchersInterval,
+					owner,
+					repo,
+				)
+			} else {
+				err = prewarm.SnapshotWatchers(ctx, logger, gh, redisClient, clockwork.NewRealClock(), owner, repo)
+			}
+			if err != nil {
 				logger.Printf("ERROR: %s\n", err.Error())
 				errChan <- 1
 			} else {
@@ -138,5 +328,8 @@ func main() {
 			exitCode = 1
 		}
 	}
+	if boltRedis != nil {
+		boltRedis.Close()
+	}
 	os.Exit(exitCode)
 }
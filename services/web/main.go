@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/jonboulle/clockwork"
 	"gopkg.in/redis.v3"
 
 	"github.com/ksheedlo/ghviz/github"
@@ -23,6 +26,7 @@ func withDefaultStr(config, default_ string) string {
 
 func main() {
 	r := mux.NewRouter()
+	clock := clockwork.NewRealClock()
 
 	var redisClient interfaces.Rediser
 	if redisHost := os.Getenv("GHVIZ_REDIS_HOST"); redisHost != "" {
@@ -32,29 +36,75 @@ func main() {
 			Password: os.Getenv("GHVIZ_REDIS_PASSWORD"),
 			DB:       0,
 		}))
+	} else if boltPath := os.Getenv("GHVIZ_BOLT_PATH"); boltPath != "" {
+		boltRedis, err := interfaces.OpenBoltRedis(clock, boltPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open %s: %s\n", boltPath, err.Error())
+			os.Exit(2)
+		}
+		defer boltRedis.Close()
+		redisClient = boltRedis
+	} else {
+		// Without Redis, cache in process so that everything still works.
+		cacheMegabytes, err := strconv.Atoi(withDefaultStr(os.Getenv("GHVIZ_MEMORY_CACHE_MB"), "64"))
+		if err != nil || cacheMegabytes <= 0 {
+			fmt.Fprintf(os.Stderr, "GHVIZ_MEMORY_CACHE_MB must be a positive number\n")
+			os.Exit(2)
+		}
+		redisClient = interfaces.NewMemoryRedis(clock, cacheMegabytes<<20)
 	}
 
-	gh := github.NewClient(&github.Options{
-		MaxStaleness: 5,
-		RedisClient:  redisClient,
-		Token:        os.Getenv("GITHUB_TOKEN"),
+	options, err := github.OptionsFromEnv(&github.Options{
+		BreakerCoolOff:   30 * time.Second,
+		BreakerThreshold: 5,
+		Clock:            clock,
+		MaxRetries:       2,
+		MaxStaleAge:      24 * time.Hour,
+		MaxStaleness:     5,
+		OverallTimeout:   30 * time.Second,
+		RateLimitReserve: 50,
+		RedisClient:      redisClient,
+		RequestTimeout:   10 * time.Second,
 	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(2)
+	}
+	gh, err := github.NewBackend(os.Getenv("GHVIZ_GITHUB_API"), options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(2)
+	}
 	withMiddleware := middleware.Compose(
 		middleware.AddResponseId(interfaces.RandomTag),
 		middleware.AddLogger(os.Stdout),
 		middleware.LogRequest,
+		middleware.MarkStale(clock),
 		middleware.Gzip,
 	)
+	// Org routes come first, since /{owner}/{repo}/... would match them too.
+	r.HandleFunc("/orgs/{org}/star_counts", withMiddleware(routes.OrgStarCounts(gh)))
+	r.HandleFunc("/orgs/{org}/issue_counts", withMiddleware(routes.OrgOpenIssuesAndPrs(gh)))
+	r.HandleFunc("/orgs/{org}/top_issues", withMiddleware(routes.OrgTopIssues(gh)))
 	r.HandleFunc(
 		"/{owner}/{repo}/star_counts",
 		withMiddleware(routes.ListStarCounts(gh)),
 	)
+	r.HandleFunc("/{owner}/{repo}/fork_counts", withMiddleware(routes.ListForkCounts(gh)))
+	r.HandleFunc("/{owner}/{repo}/watcher_counts", withMiddleware(routes.WatcherCounts(redisClient)))
 	r.HandleFunc(
 		"/{owner}/{repo}/issue_counts",
 		withMiddleware(routes.ListOpenIssuesAndPrs(gh)),
 	)
 	r.HandleFunc("/{owner}/{repo}/top_issues", withMiddleware(routes.TopIssues(gh)))
 	r.HandleFunc("/{owner}/{repo}/top_prs", withMiddleware(routes.TopPrs(gh)))
+	r.HandleFunc("/{owner}/{repo}/pulls", withMiddleware(routes.ListPullRequests(gh)))
+	r.HandleFunc("/{owner}/{repo}/commit_counts", withMiddleware(routes.CommitCounts(gh)))
+	r.HandleFunc("/{owner}/{repo}/committer_counts", withMiddleware(routes.CommitterCounts(gh)))
+	r.HandleFunc("/{owner}/{repo}/releases", withMiddleware(routes.ListReleases(gh)))
+	r.HandleFunc("/{owner}/{repo}/traffic", withMiddleware(routes.Traffic(redisClient)))
+	r.HandleFunc("/{owner}/{repo}/contributor_stats", withMiddleware(routes.ContributorStats(gh)))
+	r.HandleFunc("/rate_limit", withMiddleware(routes.RateLimit(gh)))
 	r.HandleFunc(
 		"/{owner}/{repo}/highscores/{year:[0-9]+}/{month:(0[1-9]|1[012])}",
 		withMiddleware(routes.HighScores(redisClient)),
@@ -0,0 +1,117 @@
+package simulate
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ksheedlo/ghviz/github"
+)
+
+// Period is the size of the buckets that commit activity is counted in.
+type Period int
+
+const (
+	Daily Period = iota
+	Weekly
+	Monthly
+)
+
+// start truncates a time to the beginning of the period it falls in, in UTC.
+// Weeks start on Monday.
+func (period Period) start(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case Weekly:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case Monthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+func (period Period) next(t time.Time) time.Time {
+	switch period {
+	case Weekly:
+		return t.AddDate(0, 0, 7)
+	case Monthly:
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+type CommitCount struct {
+	Commits   int
+	Timestamp time.Time
+}
+
+func (cc *CommitCount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"commits":   cc.Commits,
+		"timestamp": cc.Timestamp,
+	})
+}
+
+type CommitterCount struct {
+	Committers int
+	Timestamp  time.Time
+}
+
+func (cc *CommitterCount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"committers": cc.Committers,
+		"timestamp":  cc.Timestamp,
+	})
+}
+
+// bucketCommits groups commits by the period they were made in. It returns
+// the start of every period from the first commit to the last, including
+// periods with no commits, so charts show the quiet spells.
+func bucketCommits(commits []github.Commit, period Period) ([]time.Time, map[time.Time][]github.Commit) {
+	buckets := make(map[time.Time][]github.Commit)
+	if len(commits) == 0 {
+		return []time.Time{}, buckets
+	}
+	first := period.start(commits[0].CommittedAt)
+	last := first
+	for _, commit := range commits {
+		start := period.start(commit.CommittedAt)
+		buckets[start] = append(buckets[start], commit)
+		if start.Before(first) {
+			first = start
+		}
+		if start.After(last) {
+			last = start
+		}
+	}
+	var starts []time.Time
+	for start := first; !start.After(last); start = period.next(start) {
+		starts = append(starts, start)
+	}
+	return starts, buckets
+}
+
+func CommitCounts(commits []github.Commit, period Period) []CommitCount {
+	starts, buckets := bucketCommits(commits, period)
+	commitCounts := make([]CommitCount, len(starts))
+	for i, start := range starts {
+		commitCounts[i].Commits = len(buckets[start])
+		commitCounts[i].Timestamp = start
+	}
+	return commitCounts
+}
+
+// CommitterCounts counts the distinct commit authors in each month.
+func CommitterCounts(commits []github.Commit) []CommitterCount {
+	starts, buckets := bucketCommits(commits, Monthly)
+	committerCounts := make([]CommitterCount, len(starts))
+	for i, start := range starts {
+		authors := make(map[string]bool)
+		for _, commit := range buckets[start] {
+			authors[commit.Author] = true
+		}
+		committerCounts[i].Committers = len(authors)
+		committerCounts[i].Timestamp = start
+	}
+	return committerCounts
+}
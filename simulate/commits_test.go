@@ -0,0 +1,89 @@
+package simulate
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/github"
+	"github.com/ksheedlo/ghviz/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommitCountsDaily(t *testing.T) {
+	t.Parallel()
+
+	commits := []github.Commit{
+		github.Commit{Author: "tester1", CommittedAt: time.Date(2016, 3, 9, 15, 0, 0, 0, time.UTC)},
+		github.Commit{Author: "tester2", CommittedAt: time.Date(2016, 3, 7, 23, 0, 0, 0, time.UTC)},
+		github.Commit{Author: "tester1", CommittedAt: time.Date(2016, 3, 7, 1, 0, 0, 0, time.UTC)},
+	}
+	commitCounts := CommitCounts(commits, Daily)
+
+	assert.Len(t, commitCounts, 3)
+	assert.Equal(t, time.Date(2016, 3, 7, 0, 0, 0, 0, time.UTC), commitCounts[0].Timestamp)
+	assert.Equal(t, 2, commitCounts[0].Commits)
+	assert.Equal(t, time.Date(2016, 3, 8, 0, 0, 0, 0, time.UTC), commitCounts[1].Timestamp)
+	assert.Equal(t, 0, commitCounts[1].Commits)
+	assert.Equal(t, 1, commitCounts[2].Commits)
+}
+
+func TestCommitCountsWeekly(t *testing.T) {
+	t.Parallel()
+
+	// 2016-03-06 was a Sunday, so it belongs to the week starting on the
+	// Monday before.
+	commits := []github.Commit{
+		github.Commit{CommittedAt: time.Date(2016, 3, 14, 0, 0, 0, 0, time.UTC)},
+		github.Commit{CommittedAt: time.Date(2016, 3, 7, 12, 0, 0, 0, time.UTC)},
+		github.Commit{CommittedAt: time.Date(2016, 3, 6, 12, 0, 0, 0, time.UTC)},
+	}
+	commitCounts := CommitCounts(commits, Weekly)
+
+	assert.Len(t, commitCounts, 3)
+	assert.Equal(t, time.Date(2016, 2, 29, 0, 0, 0, 0, time.UTC), commitCounts[0].Timestamp)
+	assert.Equal(t, 1, commitCounts[0].Commits)
+	assert.Equal(t, time.Date(2016, 3, 7, 0, 0, 0, 0, time.UTC), commitCounts[1].Timestamp)
+	assert.Equal(t, 1, commitCounts[1].Commits)
+	assert.Equal(t, time.Date(2016, 3, 14, 0, 0, 0, 0, time.UTC), commitCounts[2].Timestamp)
+	assert.Equal(t, 1, commitCounts[2].Commits)
+}
+
+func TestCommitCountsEmpty(t *testing.T) {
+	t.Parallel()
+
+	assert.Len(t, CommitCounts([]github.Commit{}, Daily), 0)
+	assert.Len(t, CommitterCounts([]github.Commit{}), 0)
+}
+
+func TestCommitterCounts(t *testing.T) {
+	t.Parallel()
+
+	commits := []github.Commit{
+		github.Commit{Author: "tester1", CommittedAt: time.Date(2016, 5, 2, 0, 0, 0, 0, time.UTC)},
+		github.Commit{Author: "tester2", CommittedAt: time.Date(2016, 3, 30, 0, 0, 0, 0, time.UTC)},
+		github.Commit{Author: "tester1", CommittedAt: time.Date(2016, 3, 7, 0, 0, 0, 0, time.UTC)},
+		github.Commit{Author: "tester1", CommittedAt: time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	committerCounts := CommitterCounts(commits)
+
+	assert.Len(t, committerCounts, 3)
+	assert.Equal(t, time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC), committerCounts[0].Timestamp)
+	assert.Equal(t, 2, committerCounts[0].Committers)
+	assert.Equal(t, 0, committerCounts[1].Committers)
+	assert.Equal(t, time.Date(2016, 5, 1, 0, 0, 0, 0, time.UTC), committerCounts[2].Timestamp)
+	assert.Equal(t, 1, committerCounts[2].Committers)
+}
+
+func TestMarshalCommitCount(t *testing.T) {
+	t.Parallel()
+
+	jsonBytes := mocks.MarshalJSON(t, &CommitCount{
+		Commits:   5,
+		Timestamp: time.Date(2016, 3, 7, 0, 0, 0, 0, time.UTC),
+	})
+	var result map[string]interface{}
+	assert.NoError(t, json.Unmarshal(jsonBytes, &result))
+	assert.Equal(t, 5.0, result["commits"].(float64))
+	assert.Equal(t, "2016-03-07T00:00:00Z", result["timestamp"].(string))
+}
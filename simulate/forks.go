@@ -0,0 +1,42 @@
+package simulate
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ksheedlo/ghviz/github"
+)
+
+type ForkCount struct {
+	Forks     int
+	Timestamp time.Time
+}
+
+func (fc *ForkCount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"forks":     fc.Forks,
+		"timestamp": fc.Timestamp,
+	})
+}
+
+func ForkCounts(forkEvents []github.ForkEvent) []ForkCount {
+	forkCounts := make([]ForkCount, len(forkEvents))
+	for i := 0; i < len(forkEvents); i++ {
+		forkCounts[i].Forks = i + 1
+		forkCounts[i].Timestamp = forkEvents[i].ForkedAt
+	}
+	return forkCounts
+}
+
+// WatcherCount is a snapshot of how many people watched a repo at one time.
+type WatcherCount struct {
+	Timestamp time.Time
+	Watchers  int
+}
+
+func (wc *WatcherCount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"timestamp": wc.Timestamp,
+		"watchers":  wc.Watchers,
+	})
+}
@@ -0,0 +1,54 @@
+package simulate
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/github"
+	"github.com/ksheedlo/ghviz/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForkCounts(t *testing.T) {
+	t.Parallel()
+
+	forkEvents := []github.ForkEvent{
+		github.ForkEvent{ForkedAt: time.Unix(1, 0)},
+		github.ForkEvent{ForkedAt: time.Unix(2, 0)},
+		github.ForkEvent{ForkedAt: time.Unix(3, 0)},
+	}
+	forkCounts := ForkCounts(forkEvents)
+
+	assert.Len(t, forkCounts, 3)
+	for i, forkCount := range forkCounts {
+		assert.Equal(t, i+1, forkCount.Forks)
+		assert.Equal(t, forkEvents[i].ForkedAt, forkCount.Timestamp)
+	}
+}
+
+func TestMarshalForkCount(t *testing.T) {
+	t.Parallel()
+
+	jsonBytes := mocks.MarshalJSON(t, &ForkCount{
+		Forks:     5,
+		Timestamp: time.Unix(1458966366, 0).UTC(),
+	})
+	var result map[string]interface{}
+	assert.NoError(t, json.Unmarshal(jsonBytes, &result))
+	assert.Equal(t, 5.0, result["forks"].(float64))
+	assert.Equal(t, "2016-03-26T04:26:06Z", result["timestamp"].(string))
+}
+
+func TestWatcherCountRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	jsonBytes := mocks.MarshalJSON(t, &WatcherCount{
+		Timestamp: time.Unix(1458966366, 0).UTC(),
+		Watchers:  7,
+	})
+	var watcherCount WatcherCount
+	assert.NoError(t, json.Unmarshal(jsonBytes, &watcherCount))
+	assert.Equal(t, 7, watcherCount.Watchers)
+	assert.Equal(t, time.Unix(1458966366, 0).UTC(), watcherCount.Timestamp)
+}
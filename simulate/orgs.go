@@ -0,0 +1,95 @@
+package simulate
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ksheedlo/ghviz/github"
+)
+
+// seriesPoint is the i-th point of one of several series being merged.
+type seriesPoint struct {
+	index     int
+	series    int
+	timestamp time.Time
+}
+
+// mergeOrder interleaves the points of several time series by timestamp.
+// Points with the same timestamp keep their series order.
+func mergeOrder(lengths []int, timestamp func(series, index int) time.Time) []seriesPoint {
+	points := make([]seriesPoint, 0)
+	for series, length := range lengths {
+		for index := 0; index < length; index++ {
+			points = append(points, seriesPoint{
+				index:     index,
+				series:    series,
+				timestamp: timestamp(series, index),
+			})
+		}
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].timestamp.Before(points[j].timestamp)
+	})
+	return points
+}
+
+// MergeStarCounts adds up the star counts of several repos over time.
+func MergeStarCounts(series [][]StarCount) []StarCount {
+	lengths := make([]int, len(series))
+	for i := range series {
+		lengths[i] = len(series[i])
+	}
+	points := mergeOrder(lengths, func(s, i int) time.Time { return series[s][i].Timestamp })
+	latest := make([]int, len(series))
+	stars := 0
+	merged := make([]StarCount, len(points))
+	for i, point := range points {
+		count := series[point.series][point.index]
+		stars += count.Stars - latest[point.series]
+		latest[point.series] = count.Stars
+		merged[i] = StarCount{Stars: stars, Timestamp: count.Timestamp}
+	}
+	return merged
+}
+
+// MergeOpenIssueAndPrCounts adds up the open issue and PR counts of several
+// repos over time.
+func MergeOpenIssueAndPrCounts(series [][]OpenIssueAndPrCount) []OpenIssueAndPrCount {
+	lengths := make([]int, len(series))
+	for i := range series {
+		lengths[i] = len(series[i])
+	}
+	points := mergeOrder(lengths, func(s, i int) time.Time { return series[s][i].Timestamp })
+	latest := make([]OpenIssueAndPrCount, len(series))
+	openIssues := 0
+	openPrs := 0
+	merged := make([]OpenIssueAndPrCount, len(points))
+	for i, point := range points {
+		count := series[point.series][point.index]
+		openIssues += count.OpenIssues - latest[point.series].OpenIssues
+		openPrs += count.OpenPrs - latest[point.series].OpenPrs
+		latest[point.series] = count
+		merged[i] = OpenIssueAndPrCount{
+			OpenIssues: openIssues,
+			OpenPrs:    openPrs,
+			Timestamp:  count.Timestamp,
+		}
+	}
+	return merged
+}
+
+// MergeTopIssues picks the newest issues across several repos' top issues,
+// which Github lists newest first.
+func MergeTopIssues(series [][]github.Issue, limit int) []github.Issue {
+	merged := make([]github.Issue, 0)
+	for _, issues := range series {
+		merged = append(merged, issues...)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].CreatedAt.After(merged[j].CreatedAt)
+	})
+	if len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
@@ -0,0 +1,82 @@
+package simulate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeStarCounts(t *testing.T) {
+	t.Parallel()
+
+	merged := MergeStarCounts([][]StarCount{
+		[]StarCount{
+			StarCount{Stars: 1, Timestamp: time.Unix(1, 0)},
+			StarCount{Stars: 2, Timestamp: time.Unix(4, 0)},
+		},
+		[]StarCount{},
+		[]StarCount{
+			StarCount{Stars: 1, Timestamp: time.Unix(2, 0)},
+			StarCount{Stars: 2, Timestamp: time.Unix(3, 0)},
+			StarCount{Stars: 3, Timestamp: time.Unix(5, 0)},
+		},
+	})
+
+	assert.Equal(t, []StarCount{
+		StarCount{Stars: 1, Timestamp: time.Unix(1, 0)},
+		StarCount{Stars: 2, Timestamp: time.Unix(2, 0)},
+		StarCount{Stars: 3, Timestamp: time.Unix(3, 0)},
+		StarCount{Stars: 4, Timestamp: time.Unix(4, 0)},
+		StarCount{Stars: 5, Timestamp: time.Unix(5, 0)},
+	}, merged)
+}
+
+func TestMergeStarCountsEmpty(t *testing.T) {
+	t.Parallel()
+
+	assert.Len(t, MergeStarCounts(nil), 0)
+}
+
+func TestMergeOpenIssueAndPrCounts(t *testing.T) {
+	t.Parallel()
+
+	merged := MergeOpenIssueAndPrCounts([][]OpenIssueAndPrCount{
+		[]OpenIssueAndPrCount{
+			OpenIssueAndPrCount{OpenIssues: 1, OpenPrs: 0, Timestamp: time.Unix(1, 0)},
+			OpenIssueAndPrCount{OpenIssues: 0, OpenPrs: 0, Timestamp: time.Unix(3, 0)},
+		},
+		[]OpenIssueAndPrCount{
+			OpenIssueAndPrCount{OpenIssues: 0, OpenPrs: 1, Timestamp: time.Unix(2, 0)},
+			OpenIssueAndPrCount{OpenIssues: 1, OpenPrs: 1, Timestamp: time.Unix(4, 0)},
+		},
+	})
+
+	assert.Equal(t, []OpenIssueAndPrCount{
+		OpenIssueAndPrCount{OpenIssues: 1, OpenPrs: 0, Timestamp: time.Unix(1, 0)},
+		OpenIssueAndPrCount{OpenIssues: 1, OpenPrs: 1, Timestamp: time.Unix(2, 0)},
+		OpenIssueAndPrCount{OpenIssues: 0, OpenPrs: 1, Timestamp: time.Unix(3, 0)},
+		OpenIssueAndPrCount{OpenIssues: 1, OpenPrs: 1, Timestamp: time.Unix(4, 0)},
+	}, merged)
+}
+
+func TestMergeTopIssues(t *testing.T) {
+	t.Parallel()
+
+	merged := MergeTopIssues([][]github.Issue{
+		[]github.Issue{
+			github.Issue{Number: 3, CreatedAt: time.Unix(30, 0)},
+			github.Issue{Number: 1, CreatedAt: time.Unix(10, 0)},
+		},
+		[]github.Issue{
+			github.Issue{Number: 4, CreatedAt: time.Unix(40, 0)},
+			github.Issue{Number: 2, CreatedAt: time.Unix(20, 0)},
+		},
+	}, 3)
+
+	assert.Len(t, merged, 3)
+	assert.Equal(t, 4, merged[0].Number)
+	assert.Equal(t, 3, merged[1].Number)
+	assert.Equal(t, 2, merged[2].Number)
+}
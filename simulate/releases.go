@@ -0,0 +1,66 @@
+package simulate
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/ksheedlo/ghviz/github"
+)
+
+// ReleaseStats describes one published release along with the cadence
+// metrics leading up to it.
+type ReleaseStats struct {
+	CumulativeDownloads int
+	DaysSincePrevious   float64
+	Downloads           int
+	HtmlUrl             string
+	IsPrerelease        bool
+	Name                string
+	PublishedAt         time.Time
+	TagName             string
+}
+
+func (rs *ReleaseStats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"cumulative_downloads": rs.CumulativeDownloads,
+		"days_since_previous":  rs.DaysSincePrevious,
+		"downloads":            rs.Downloads,
+		"html_url":             rs.HtmlUrl,
+		"is_prerelease":        rs.IsPrerelease,
+		"name":                 rs.Name,
+		"published_at":         rs.PublishedAt,
+		"tag_name":             rs.TagName,
+	})
+}
+
+// ReleaseTimeline orders the published releases from oldest to newest. Drafts
+// are left out since they have not been released yet.
+func ReleaseTimeline(releases []github.Release) []ReleaseStats {
+	timeline := make([]ReleaseStats, 0, len(releases))
+	for _, release := range releases {
+		if release.IsDraft || release.PublishedAt.IsZero() {
+			continue
+		}
+		timeline = append(timeline, ReleaseStats{
+			Downloads:    release.AssetDownloads,
+			HtmlUrl:      release.HtmlUrl,
+			IsPrerelease: release.IsPrerelease,
+			Name:         release.Name,
+			PublishedAt:  release.PublishedAt,
+			TagName:      release.TagName,
+		})
+	}
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].PublishedAt.Before(timeline[j].PublishedAt)
+	})
+	cumulativeDownloads := 0
+	for i := range timeline {
+		cumulativeDownloads += timeline[i].Downloads
+		timeline[i].CumulativeDownloads = cumulativeDownloads
+		if i > 0 {
+			timeline[i].DaysSincePrevious = timeline[i].PublishedAt.Sub(timeline[i-1].PublishedAt).Hours() / 24
+		}
+	}
+	return timeline
+}
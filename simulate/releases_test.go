@@ -0,0 +1,64 @@
+package simulate
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ksheedlo/ghviz/github"
+	"github.com/ksheedlo/ghviz/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseTimeline(t *testing.T) {
+	t.Parallel()
+
+	releases := []github.Release{
+		github.Release{
+			AssetDownloads: 30,
+			Name:           "v1.1.0",
+			PublishedAt:    time.Date(2016, 3, 11, 12, 0, 0, 0, time.UTC),
+			TagName:        "v1.1.0",
+		},
+		github.Release{IsDraft: true, Name: "v2.0.0", TagName: "v2.0.0"},
+		github.Release{
+			AssetDownloads: 10,
+			Name:           "v1.0.0",
+			PublishedAt:    time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC),
+			TagName:        "v1.0.0",
+		},
+	}
+	timeline := ReleaseTimeline(releases)
+
+	assert.Len(t, timeline, 2)
+	assert.Equal(t, "v1.0.0", timeline[0].TagName)
+	assert.Equal(t, 0.0, timeline[0].DaysSincePrevious)
+	assert.Equal(t, 10, timeline[0].CumulativeDownloads)
+	assert.Equal(t, "v1.1.0", timeline[1].TagName)
+	assert.Equal(t, 10.5, timeline[1].DaysSincePrevious)
+	assert.Equal(t, 30, timeline[1].Downloads)
+	assert.Equal(t, 40, timeline[1].CumulativeDownloads)
+}
+
+func TestReleaseTimelineEmpty(t *testing.T) {
+	t.Parallel()
+
+	assert.Len(t, ReleaseTimeline([]github.Release{}), 0)
+}
+
+func TestMarshalReleaseStats(t *testing.T) {
+	t.Parallel()
+
+	jsonBytes := mocks.MarshalJSON(t, &ReleaseStats{
+		CumulativeDownloads: 40,
+		DaysSincePrevious:   10.5,
+		PublishedAt:         time.Date(2016, 3, 11, 12, 0, 0, 0, time.UTC),
+		TagName:             "v1.1.0",
+	})
+	var result map[string]interface{}
+	assert.NoError(t, json.Unmarshal(jsonBytes, &result))
+	assert.Equal(t, 40.0, result["cumulative_downloads"].(float64))
+	assert.Equal(t, 10.5, result["days_since_previous"].(float64))
+	assert.Equal(t, "2016-03-11T12:00:00Z", result["published_at"].(string))
+	assert.Equal(t, "v1.1.0", result["tag_name"].(string))
+}
@@ -2,6 +2,7 @@ package simulate
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/ksheedlo/ghviz/github"
@@ -44,20 +45,35 @@ func (sev *ScoringEvent) MarshalJSON() ([]byte, error) {
 }
 
 func (sev *ScoringEvent) UnmarshalJSON(bytes []byte) error {
-	item := make(map[string]interface{})
+	var item struct {
+		ActorId   string     `json:"actor_id"`
+		EventType string     `json:"event_type"`
+		Timestamp *time.Time `json:"timestamp"`
+	}
 	if err := json.Unmarshal(bytes, &item); err != nil {
 		return err
 	}
-	timestamp, err := time.Parse(time.RFC3339, item["timestamp"].(string))
-	if err != nil {
-		return err
+	if item.Timestamp == nil {
+		return fmt.Errorf("scoring event is missing its timestamp")
+	}
+	eventType, ok := scoringEventTypesByKey[item.EventType]
+	if !ok {
+		return fmt.Errorf("unknown scoring event type %q", item.EventType)
 	}
-	sev.ActorId = item["actor_id"].(string)
-	sev.EventType = scoringEventTypesByKey[item["event_type"].(string)]
-	sev.Timestamp = timestamp
+	sev.ActorId = item.ActorId
+	sev.EventType = eventType
+	sev.Timestamp = *item.Timestamp
 	return nil
 }
 
+// labelName reads the name of the label from a labeled or unlabeled event.
+// Events for labels that have since been deleted may carry no label at all.
+func labelName(detail interface{}) string {
+	label, _ := detail.(map[string]interface{})
+	name, _ := label["name"].(string)
+	return name
+}
+
 type ByTimestamp []ScoringEvent
 
 func (a ByTimestamp) Len() int           { return len(a) }
@@ -108,15 +124,13 @@ func ScoreIssues(issueEvents []github.DetailedIssueEvent, readyLabel string) []S
 		case github.IssueLabeled:
 			// 2. The submitter should apply the ready label when the PR is ready
 			//    for review.
-			labelName := (event.Detail.(map[string]interface{}))["name"].(string)
-			if labelName == readyLabel {
+			if labelName(event.Detail) == readyLabel {
 				prStates[event.IssueNumber] = PrStateReady
 			}
 		case github.IssueUnlabeled:
 			// 3. When a reviewer removes the ready label from a PR in the ready
 			//    state, that constitutes a review.
-			labelName := (event.Detail.(map[string]interface{}))["name"].(string)
-			if labelName == readyLabel && prStates[event.IssueNumber] == PrStateReady {
+			if labelName(event.Detail) == readyLabel && prStates[event.IssueNumber] == PrStateReady {
 				prStates[event.IssueNumber] = PrStateReviewed
 				scoringEvents = append(scoringEvents, ScoringEvent{
 					ActorId:   event.ActorId,
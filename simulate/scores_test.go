@@ -148,6 +148,40 @@ func TestScoreExtraneousLabel(t *testing.T) {
 	assert.Equal(t, scoringEvents[0].EventType, IssueOpened)
 }
 
+func TestScoreDeletedLabel(t *testing.T) {
+	t.Parallel()
+
+	scoringEvents := ScoreIssues(
+		[]github.DetailedIssueEvent{
+			github.DetailedIssueEvent{
+				ActorId:     "tester1",
+				CreatedAt:   time.Unix(1, 0),
+				Detail:      nil,
+				EventType:   github.IssueCreated,
+				IssueNumber: 1,
+			},
+			github.DetailedIssueEvent{
+				ActorId:     "tester1",
+				CreatedAt:   time.Unix(2, 0),
+				Detail:      nil,
+				EventType:   github.IssueLabeled,
+				IssueNumber: 1,
+			},
+			github.DetailedIssueEvent{
+				ActorId:     "tester2",
+				CreatedAt:   time.Unix(3, 0),
+				Detail:      map[string]interface{}{},
+				EventType:   github.IssueUnlabeled,
+				IssueNumber: 1,
+			},
+		},
+		"ready label",
+	)
+
+	assert.Len(t, scoringEvents, 1)
+	assert.Equal(t, scoringEvents[0].EventType, IssueOpened)
+}
+
 func TestScoreMultipleIssues(t *testing.T) {
 	t.Parallel()
 
@@ -264,6 +298,13 @@ func TestUnmarshalBadTimestamp(t *testing.T) {
 	assert.Error(t, json.Unmarshal([]byte(scoringEventBadTimestamp), &sev))
 }
 
+func TestUnmarshalMissingTimestamp(t *testing.T) {
+	t.Parallel()
+
+	var sev ScoringEvent
+	assert.Error(t, json.Unmarshal([]byte(`{"actor_id":"FooBarson","event_type":"opened"}`), &sev))
+}
+
 func TestMarshalActorScore(t *testing.T) {
 	t.Parallel()
 